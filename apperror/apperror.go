@@ -0,0 +1,145 @@
+// Package apperror defines the errors the API sends to clients. Every handler
+// returns an *AppError (or lets one be derived from its error), and
+// utils.ErrorHandler renders it, so all error responses share one shape.
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/i18n"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AppError is an error with a stable code clients can branch on. The values in
+// the catalog are templates; the With* methods return copies.
+type AppError struct {
+	Code       string // stable and machine-readable, e.g. TOURIST_NOT_FOUND
+	Status     int
+	MessageKey string // key of the localized message, e.g. errors.tourist_not_found
+
+	// Fields holds per-field problems for validation errors: field name to the
+	// message key of the problem (validation.not_empty, ...)
+	Fields map[string]string
+	// Current is the resource as it is now, sent with conflicts so clients can retry
+	Current interface{}
+
+	// cause is the underlying error; it is logged but never sent to the client
+	cause error
+}
+
+// Response is the JSON body of every error response
+type Response struct {
+	Error      string            `json:"error"`
+	Code       string            `json:"code"`
+	MessageKey string            `json:"message_key"`
+	Status     int               `json:"status"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Current    interface{}       `json:"current,omitempty"`
+}
+
+func (e *AppError) Error() string {
+	if e.cause != nil {
+		return e.Code + ": " + e.cause.Error()
+	}
+	return e.Code + ": " + e.Message(i18n.Default)
+}
+
+// Message returns the error message translated into language
+func (e *AppError) Message(language string) string {
+	return i18n.T(language, e.MessageKey)
+}
+
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches any AppError with the same code, so errors.Is(err, apperror.TouristNotFound)
+// holds for copies made with the With* methods
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// Wrap records the internal error behind this one
+func (e *AppError) Wrap(cause error) *AppError {
+	c := *e
+	c.cause = cause
+	return &c
+}
+
+// WithFields attaches per-field validation problems
+func (e *AppError) WithFields(fields map[string]string) *AppError {
+	c := *e
+	c.Fields = fields
+	return &c
+}
+
+// WithCurrent attaches the current state of the resource
+func (e *AppError) WithCurrent(current interface{}) *AppError {
+	c := *e
+	c.Current = current
+	return &c
+}
+
+// Response renders the error for the client, translated into language
+func (e *AppError) Response(language, requestID string) Response {
+	var fields map[string]string
+	if e.Fields != nil {
+		fields = make(map[string]string, len(e.Fields))
+		for field, key := range e.Fields {
+			fields[field] = i18n.T(language, key)
+		}
+	}
+	return Response{
+		Error:      e.Message(language),
+		Code:       e.Code,
+		MessageKey: e.MessageKey,
+		Status:     e.Status,
+		RequestID:  requestID,
+		Fields:     fields,
+		Current:    e.Current,
+	}
+}
+
+// From turns any error returned by a handler into an AppError. Errors that are
+// not AppErrors never expose their text: they become INTERNAL_ERROR.
+func From(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &fiberErr):
+		return fromStatus(fiberErr.Code).Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return RequestCancelled.Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return RequestTimeout.Wrap(err)
+	default:
+		return Internal.Wrap(err)
+	}
+}
+
+// fromStatus maps the errors Fiber raises itself (unknown routes, oversized bodies, ...)
+func fromStatus(status int) *AppError {
+	switch status {
+	case fiber.StatusNotFound:
+		return RouteNotFound
+	case fiber.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case fiber.StatusRequestEntityTooLarge:
+		return RequestTooLarge
+	case fiber.StatusBadRequest, fiber.StatusUnprocessableEntity:
+		return InvalidRequestBody
+	}
+	if status >= fiber.StatusInternalServerError {
+		return Internal
+	}
+	failed := *RequestFailed
+	failed.Status = status
+	return &failed
+}
@@ -0,0 +1,114 @@
+package apperror
+
+import (
+	"net/http"
+	"strings"
+)
+
+// StatusClientClosedRequest is the non-standard status (from nginx) for requests
+// the client abandoned before the response was ready
+const StatusClientClosedRequest = 499
+
+// catalog lists every error the API can return, in the order defined below
+var catalog []*AppError
+
+// define adds an error to the catalog. Codes are part of the API contract: never
+// rename or reuse one, add a new code instead.
+// Its messages live in the i18n catalogs under errors.<lowercase code>.
+func define(code string, status int) *AppError {
+	e := &AppError{
+		Code:       code,
+		Status:     status,
+		MessageKey: "errors." + strings.ToLower(code),
+	}
+	catalog = append(catalog, e)
+	return e
+}
+
+// Generic errors
+var (
+	InvalidRequestBody = define("INVALID_REQUEST_BODY", http.StatusBadRequest)
+	ValidationFailed   = define("VALIDATION_FAILED", http.StatusUnprocessableEntity)
+	InvalidID          = define("INVALID_ID", http.StatusBadRequest)
+	RouteNotFound      = define("ROUTE_NOT_FOUND", http.StatusNotFound)
+	MethodNotAllowed   = define("METHOD_NOT_ALLOWED", http.StatusMethodNotAllowed)
+	RequestTooLarge    = define("REQUEST_TOO_LARGE", http.StatusRequestEntityTooLarge)
+	RequestFailed      = define("REQUEST_FAILED", http.StatusBadRequest)
+	VersionConflict    = define("VERSION_CONFLICT", http.StatusConflict)
+	RequestCancelled   = define("REQUEST_CANCELLED", StatusClientClosedRequest)
+	RequestTimeout     = define("REQUEST_TIMEOUT", http.StatusServiceUnavailable)
+	RateLimited        = define("RATE_LIMITED", http.StatusTooManyRequests)
+	Internal           = define("INTERNAL_ERROR", http.StatusInternalServerError)
+)
+
+// Authentication and accounts
+var (
+	AuthHeaderMissing      = define("AUTH_HEADER_MISSING", http.StatusUnauthorized)
+	AuthHeaderInvalid      = define("AUTH_HEADER_INVALID", http.StatusUnauthorized)
+	TokenInvalid           = define("TOKEN_INVALID", http.StatusUnauthorized)
+	UserNotFound           = define("USER_NOT_FOUND", http.StatusUnauthorized)
+	AdminRequired          = define("ADMIN_REQUIRED", http.StatusForbidden)
+	InvalidCredentials     = define("INVALID_CREDENTIALS", http.StatusUnauthorized)
+	AccountLocked          = define("ACCOUNT_LOCKED", http.StatusTooManyRequests)
+	EmailAlreadyRegistered = define("EMAIL_ALREADY_REGISTERED", http.StatusConflict)
+	InvalidRole            = define("INVALID_ROLE", http.StatusBadRequest)
+	RoleAlreadyAssigned    = define("ROLE_ALREADY_ASSIGNED", http.StatusBadRequest)
+	// Deprecated: invalid registration dates are reported as VALIDATION_FAILED;
+	// the code stays so it is never reused
+	InvalidDate           = define("INVALID_DATE", http.StatusBadRequest)
+	GoogleLoginDisabled   = define("GOOGLE_LOGIN_DISABLED", http.StatusNotFound)
+	OAuthCodeMissing      = define("OAUTH_CODE_MISSING", http.StatusBadRequest)
+	OAuthStateInvalid     = define("OAUTH_STATE_INVALID", http.StatusBadRequest)
+	RedirectURINotAllowed = define("REDIRECT_URI_NOT_ALLOWED", http.StatusBadRequest)
+	LoginCodeInvalid      = define("LOGIN_CODE_INVALID", http.StatusBadRequest)
+	GoogleLoginFailed     = define("GOOGLE_LOGIN_FAILED", http.StatusBadGateway)
+	OIDCProviderNotFound  = define("OIDC_PROVIDER_NOT_FOUND", http.StatusNotFound)
+	OIDCLoginFailed       = define("OIDC_LOGIN_FAILED", http.StatusBadGateway)
+	// The provider account has the email of an existing user but the provider
+	// hasn't verified it, so it isn't linked to that user
+	IdentityEmailUnverified = define("IDENTITY_EMAIL_UNVERIFIED", http.StatusConflict)
+	// The existing user with the provider account's email hasn't verified it,
+	// so it isn't linked to them without signing in
+	AccountEmailUnverified  = define("ACCOUNT_EMAIL_UNVERIFIED", http.StatusConflict)
+	IdentityLinkedElsewhere = define("IDENTITY_LINKED_ELSEWHERE", http.StatusConflict)
+	IdentityNotFound        = define("IDENTITY_NOT_FOUND", http.StatusNotFound)
+	// Unlinking would leave a user without a password no way to sign in
+	LastLoginMethod    = define("LAST_LOGIN_METHOD", http.StatusConflict)
+	PasswordAlreadySet = define("PASSWORD_ALREADY_SET", http.StatusConflict)
+	// The account must verify its email before using this feature
+	EmailUnverified          = define("EMAIL_UNVERIFIED", http.StatusForbidden)
+	EmailAlreadyVerified     = define("EMAIL_ALREADY_VERIFIED", http.StatusConflict)
+	VerificationTokenInvalid = define("VERIFICATION_TOKEN_INVALID", http.StatusBadRequest)
+	ResetTokenInvalid        = define("RESET_TOKEN_INVALID", http.StatusBadRequest)
+	// The current password given to change it is wrong
+	PasswordIncorrect = define("PASSWORD_INCORRECT", http.StatusForbidden)
+	PasswordNotSet    = define("PASSWORD_NOT_SET", http.StatusConflict)
+)
+
+// Tourists, drivers and bookings
+var (
+	TouristNotFound          = define("TOURIST_NOT_FOUND", http.StatusNotFound)
+	DriverNotFound           = define("DRIVER_NOT_FOUND", http.StatusNotFound)
+	BookingNotFound          = define("BOOKING_NOT_FOUND", http.StatusNotFound)
+	DriverUnavailable        = define("DRIVER_UNAVAILABLE", http.StatusBadRequest)
+	DriverBookedConcurrently = define("DRIVER_BOOKED_CONCURRENTLY", http.StatusConflict)
+	// Only the tourist and the driver of a booking may see or change it
+	BookingAccessDenied = define("BOOKING_ACCESS_DENIED", http.StatusForbidden)
+)
+
+// CatalogEntry describes one error code for API clients
+type CatalogEntry struct {
+	Code       string `json:"code"`
+	Status     int    `json:"status"`
+	MessageKey string `json:"message_key"`
+	Message    string `json:"message"`
+}
+
+// Catalog returns every error code the API can return, with messages in language
+func Catalog(language string) []CatalogEntry {
+	entries := make([]CatalogEntry, len(catalog))
+	for i, e := range catalog {
+		entries[i] = CatalogEntry{Code: e.Code, Status: e.Status, MessageKey: e.MessageKey, Message: e.Message(language)}
+	}
+	return entries
+}
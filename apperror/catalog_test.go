@@ -0,0 +1,39 @@
+package apperror
+
+import (
+	"fiber-backend/i18n"
+	"regexp"
+	"testing"
+)
+
+var codePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+func TestCatalogCodesAreUniqueAndWellFormed(t *testing.T) {
+	seen := map[string]bool{}
+	for _, entry := range Catalog(i18n.Default) {
+		if !codePattern.MatchString(entry.Code) {
+			t.Errorf("code %q is not UPPER_SNAKE_CASE", entry.Code)
+		}
+		if seen[entry.Code] {
+			t.Errorf("code %q is defined twice", entry.Code)
+		}
+		seen[entry.Code] = true
+
+		if entry.Status < 400 || entry.Status > 599 {
+			t.Errorf("%s has non-error status %d", entry.Code, entry.Status)
+		}
+		if entry.Message == "" || entry.Message == entry.MessageKey {
+			t.Errorf("%s has no message", entry.Code)
+		}
+	}
+}
+
+func TestCopiesMatchTheirTemplate(t *testing.T) {
+	err := DriverNotFound.WithFields(map[string]string{"id": "unknown"})
+	if !err.Is(DriverNotFound) || err.Is(TouristNotFound) {
+		t.Fatal("copies should match by code")
+	}
+	if DriverNotFound.Fields != nil {
+		t.Fatal("WithFields modified the catalog entry")
+	}
+}
@@ -0,0 +1,828 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiber-backend/utils"
+
+	"golang.org/x/oauth2"
+)
+
+// Config is everything the server reads from its environment. It is loaded
+// once at startup by Load and handed to the pieces that need it.
+type Config struct {
+	Env  string `json:"env"`
+	Port int    `json:"port"`
+	// ShutdownTimeout bounds how long in-flight requests may take to drain on SIGTERM
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	// RequestTimeout is the default deadline of a request's database and outbound calls
+	RequestTimeout Duration `json:"request_timeout"`
+	FrontendURL    string   `json:"frontend_url"`
+	// OAuthRedirectURLs are the frontend pages sign-ins may return to; the
+	// first is the default. Empty means FrontendURL + "/success" (see RedirectURLs).
+	OAuthRedirectURLs []string        `json:"oauth_redirect_urls"`
+	Database          DatabaseConfig  `json:"database"`
+	JWT               JWTConfig       `json:"jwt"`
+	Google            GoogleConfig    `json:"google"`
+	CORS              CORSConfig      `json:"cors"`
+	Log               LogConfig       `json:"log"`
+	Tracing           TracingConfig   `json:"tracing"`
+	RateLimit         RateLimitConfig `json:"rate_limit"`
+	Proxy             ProxyConfig     `json:"proxy"`
+	// OIDCProviders are the OpenID Connect providers users can sign in with
+	OIDCProviders     []OIDCProviderConfig    `json:"oidc_providers"`
+	Mail              MailConfig              `json:"mail"`
+	EmailVerification EmailVerificationConfig `json:"email_verification"`
+	PasswordReset     PasswordResetConfig     `json:"password_reset"`
+}
+
+type DatabaseConfig struct {
+	Driver string `json:"driver"` // postgres or sqlite
+	URL    string `json:"url"`
+
+	// Connection pool; zero leaves database/sql's default in place
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+}
+
+type JWTConfig struct {
+	Secret string   `json:"secret"`
+	TTL    Duration `json:"ttl"`
+}
+
+// GoogleConfig holds the OAuth client. Google login is disabled when ClientID is empty.
+type GoogleConfig struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURL  string `json:"redirect_url"`
+}
+
+// OAuthConfig is the OAuth client for Google's endpoint, asking for the
+// user's email address and profile
+func (g GoogleConfig) OAuthConfig(endpoint oauth2.Endpoint) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     g.ClientID,
+		ClientSecret: g.ClientSecret,
+		RedirectURL:  g.RedirectURL,
+		Endpoint:     endpoint,
+		Scopes:       []string{"email", "profile"},
+	}
+}
+
+// OIDCProviderConfig is a sign-in provider found through OpenID Connect
+// discovery at Issuer, such as Apple, Microsoft or a corporate IdP. Set through
+// the config file or OIDC_PROVIDERS=name,... with OIDC_<NAME>_ISSUER,
+// _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URI, _SCOPES, _RESPONSE_MODE and
+// _CLAIM_SUBJECT, _CLAIM_EMAIL, _CLAIM_EMAIL_VERIFIED, _CLAIM_NAME.
+type OIDCProviderConfig struct {
+	Name         string     `json:"name"` // in URLs, /auth/oidc/<name>
+	Issuer       string     `json:"issuer"`
+	ClientID     string     `json:"client_id"`
+	ClientSecret string     `json:"client_secret"`
+	RedirectURL  string     `json:"redirect_url"`
+	Scopes       []string   `json:"scopes"`        // openid email profile when empty
+	ResponseMode string     `json:"response_mode"` // form_post for providers that require it, such as Apple
+	Claims       OIDCClaims `json:"claims"`
+}
+
+// OIDCClaims names the ID token claims an identity is read from; empty names
+// use the standard claims
+type OIDCClaims struct {
+	Subject       string `json:"subject"`        // sub
+	Email         string `json:"email"`          // email
+	EmailVerified string `json:"email_verified"` // email_verified
+	Name          string `json:"name"`           // name
+}
+
+// oidcProviderName is the form provider names take in URLs and variable names
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// MailConfig is how the API emails users. The log driver only logs who a
+// message went to, for development.
+type MailConfig struct {
+	Driver       string `json:"driver"`        // log or smtp (MAIL_DRIVER)
+	From         string `json:"from"`          // MAIL_FROM
+	SMTPHost     string `json:"smtp_host"`     // SMTP_HOST
+	SMTPPort     int    `json:"smtp_port"`     // SMTP_PORT
+	SMTPUsername string `json:"smtp_username"` // SMTP_USERNAME; no authentication when empty
+	SMTPPassword string `json:"smtp_password"` // SMTP_PASSWORD
+}
+
+// Features unverified accounts can be kept from with EmailVerificationConfig.Restrict
+const (
+	FeatureBookings      = "bookings"       // booking a driver or requesting one
+	FeatureDriverProfile = "driver_profile" // creating a driver profile
+)
+
+// EmailVerificationConfig sets how users confirm their email address and what
+// they can't do until they have
+type EmailVerificationConfig struct {
+	// URL is the frontend page the emailed link opens, with ?token= added;
+	// FrontendURL + "/verify-email" when empty (EMAIL_VERIFICATION_URL)
+	URL      string   `json:"url"`
+	TokenTTL Duration `json:"token_ttl"` // EMAIL_VERIFICATION_TTL
+	Restrict []string `json:"restrict"`  // features, comma-separated in EMAIL_VERIFICATION_RESTRICT
+}
+
+// PasswordResetConfig sets how users who forgot their password get a new one
+type PasswordResetConfig struct {
+	// URL is the frontend page the emailed link opens, with ?token= added;
+	// FrontendURL + "/reset-password" when empty (PASSWORD_RESET_URL)
+	URL      string   `json:"url"`
+	TokenTTL Duration `json:"token_ttl"` // PASSWORD_RESET_TTL
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+type LogConfig struct {
+	Level      string `json:"level"`  // debug, info, warn or error; can be changed at runtime
+	Format     string `json:"format"` // json or text
+	Dir        string `json:"dir"`    // empty logs to stdout only
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxAgeDays int    `json:"max_age_days"`
+}
+
+// TracingConfig selects where OpenTelemetry spans go. The variable names follow
+// the OpenTelemetry conventions where one exists.
+type TracingConfig struct {
+	Exporter     string  `json:"exporter"`      // none, stdout, file or otlp (OTEL_TRACES_EXPORTER)
+	File         string  `json:"file"`          // output path for the file exporter (OTEL_TRACES_FILE)
+	OTLPEndpoint string  `json:"otlp_endpoint"` // host:port or URL of the collector (OTEL_EXPORTER_OTLP_ENDPOINT)
+	ServiceName  string  `json:"service_name"`  // OTEL_SERVICE_NAME
+	SampleRatio  float64 `json:"sample_ratio"`  // fraction of new traces to record (OTEL_TRACES_SAMPLER_ARG)
+}
+
+// RateLimitConfig sets the request budget of each route group and when
+// repeated failed logins lock an account
+type RateLimitConfig struct {
+	Enabled bool  `json:"enabled"`
+	Auth    Limit `json:"auth"`    // per client IP on /auth (RATE_LIMIT_AUTH)
+	Account Limit `json:"account"` // per email address on login, registration and forgotten passwords (RATE_LIMIT_ACCOUNT)
+	API     Limit `json:"api"`     // per client IP on /api (RATE_LIMIT_API)
+	Email   Limit `json:"email"`   // per client IP on the routes that send email (RATE_LIMIT_EMAIL)
+
+	Lockout LockoutConfig `json:"lockout"`
+}
+
+// ProxyConfig says where the client address that rate limits and the audit log
+// key on comes from when the API runs behind a load balancer. Without a Header
+// every request appears to come from the load balancer.
+type ProxyConfig struct {
+	// Header carries the client address, e.g. X-Real-IP (PROXY_HEADER). The
+	// proxy must overwrite it rather than append to it, since clients can send
+	// it too; empty uses the address of the connection.
+	Header string `json:"header"`
+	// TrustedProxies are the addresses and CIDR ranges Header is believed from;
+	// on other requests it is ignored (TRUSTED_PROXIES, comma-separated)
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// Limit allows Requests per Window, written "10/1m" in environment variables
+type Limit struct {
+	Requests int      `json:"requests"`
+	Window   Duration `json:"window"`
+}
+
+// LockoutConfig locks an account after MaxFailures failed logins within Window.
+// The lock lasts Duration, doubling for every further lock within a day up to MaxDuration.
+type LockoutConfig struct {
+	MaxFailures int      `json:"max_failures"`
+	Window      Duration `json:"window"`
+	Duration    Duration `json:"duration"`
+	MaxDuration Duration `json:"max_duration"`
+}
+
+// Duration is a time.Duration written as "24h" in config files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string such as \"24h\"")
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default returns the configuration used for anything not set elsewhere
+func Default() Config {
+	return Config{
+		Env:             "development",
+		Port:            3001,
+		ShutdownTimeout: Duration(30 * time.Second),
+		RequestTimeout:  Duration(10 * time.Second),
+		FrontendURL:     "http://localhost:5173",
+		Database: DatabaseConfig{
+			Driver:          "postgres",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+		},
+		JWT: JWTConfig{TTL: Duration(24 * time.Hour)},
+		CORS: CORSConfig{AllowedOrigins: []string{
+			"http://localhost:5173",
+			"https://tourist-golang.netlify.app",
+		}},
+		Log: LogConfig{Level: "info", Format: "json", Dir: "logs", MaxSizeMB: 100, MaxAgeDays: 14},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			File:        "traces.json",
+			ServiceName: "fiber-backend",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Auth:    Limit{Requests: 30, Window: Duration(time.Minute)},
+			Account: Limit{Requests: 10, Window: Duration(15 * time.Minute)},
+			API:     Limit{Requests: 600, Window: Duration(time.Minute)},
+			Email:   Limit{Requests: 5, Window: Duration(15 * time.Minute)},
+			Lockout: LockoutConfig{
+				MaxFailures: 5,
+				Window:      Duration(15 * time.Minute),
+				Duration:    Duration(time.Minute),
+				MaxDuration: Duration(time.Hour),
+			},
+		},
+		Mail: MailConfig{Driver: "log", SMTPPort: 587},
+		EmailVerification: EmailVerificationConfig{
+			TokenTTL: Duration(48 * time.Hour),
+			Restrict: []string{FeatureBookings},
+		},
+		PasswordReset: PasswordResetConfig{TokenTTL: Duration(time.Hour)},
+	}
+}
+
+// IsProduction reports whether the server runs with ENV=production
+func (c *Config) IsProduction() bool {
+	return c.Env == "production"
+}
+
+// Load builds the configuration from, in increasing order of precedence: the
+// defaults, a JSON file (-config or CONFIG_FILE), environment variables and
+// command-line flags. It does not validate the result. The arguments left after
+// the flags are returned so main can dispatch subcommands.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a JSON config file")
+	port := flags.Int("port", 0, "port to listen on (PORT)")
+	env := flags.String("env", "", "environment name (ENV)")
+	dbDriver := flags.String("db-driver", "", "database driver, postgres or sqlite (DB_DRIVER)")
+	databaseURL := flags.String("database-url", "", "database connection string (DATABASE_URL)")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	if *port != 0 {
+		cfg.Port = *port
+	}
+	if *env != "" {
+		cfg.Env = *env
+	}
+	if *dbDriver != "" {
+		cfg.Database.Driver = *dbDriver
+	}
+	if *databaseURL != "" {
+		cfg.Database.URL = *databaseURL
+	}
+	return &cfg, flags.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.Env, "ENV")
+	setString(&c.FrontendURL, "FRONTEND_URL")
+	setString(&c.Database.Driver, "DB_DRIVER")
+	setString(&c.Database.URL, "DATABASE_URL")
+	setString(&c.JWT.Secret, "JWT_SECRET")
+	setString(&c.Google.ClientID, "GOOGLE_CLIENT_ID")
+	setString(&c.Google.ClientSecret, "GOOGLE_CLIENT_SECRET")
+	setString(&c.Log.Level, "LOG_LEVEL")
+	setString(&c.Log.Format, "LOG_FORMAT")
+	setString(&c.Tracing.Exporter, "OTEL_TRACES_EXPORTER")
+	setString(&c.Tracing.File, "OTEL_TRACES_FILE")
+	setString(&c.Tracing.OTLPEndpoint, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	setString(&c.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+	setString(&c.Mail.Driver, "MAIL_DRIVER")
+	setString(&c.Mail.From, "MAIL_FROM")
+	setString(&c.Mail.SMTPHost, "SMTP_HOST")
+	setString(&c.Mail.SMTPUsername, "SMTP_USERNAME")
+	setString(&c.Mail.SMTPPassword, "SMTP_PASSWORD")
+	setString(&c.EmailVerification.URL, "EMAIL_VERIFICATION_URL")
+	setString(&c.PasswordReset.URL, "PASSWORD_RESET_URL")
+	setString(&c.Proxy.Header, "PROXY_HEADER")
+	if value := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a number between 0 and 1, got %q", value)
+		}
+		c.Tracing.SampleRatio = ratio
+	}
+	if value, ok := os.LookupEnv("LOG_DIR"); ok {
+		c.Log.Dir = value
+	}
+	// Set but empty restricts nothing
+	if value, ok := os.LookupEnv("EMAIL_VERIFICATION_RESTRICT"); ok {
+		c.EmailVerification.Restrict = splitList(value)
+	}
+
+	// GOOGLE_CALLBACK_URL is the old name of GOOGLE_REDIRECT_URI
+	redirectURI, callbackURL := os.Getenv("GOOGLE_REDIRECT_URI"), os.Getenv("GOOGLE_CALLBACK_URL")
+	switch {
+	case redirectURI != "" && callbackURL != "" && redirectURI != callbackURL:
+		return errors.New("GOOGLE_REDIRECT_URI and GOOGLE_CALLBACK_URL are both set and disagree; keep only GOOGLE_REDIRECT_URI")
+	case redirectURI != "":
+		c.Google.RedirectURL = redirectURI
+	case callbackURL != "":
+		utils.LogInfo("GOOGLE_CALLBACK_URL is deprecated, use GOOGLE_REDIRECT_URI")
+		c.Google.RedirectURL = callbackURL
+	}
+
+	if err := setInt(&c.Port, "PORT"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.ShutdownTimeout, "SHUTDOWN_TIMEOUT", "30s"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.RequestTimeout, "REQUEST_TIMEOUT", "10s"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.JWT.TTL, "JWT_TTL", "24h"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Database.MaxOpenConns, "DB_MAX_OPEN_CONNS"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Database.MaxIdleConns, "DB_MAX_IDLE_CONNS"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Database.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME", "30m"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Database.ConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME", "5m"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Log.MaxSizeMB, "LOG_MAX_SIZE_MB"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Log.MaxAgeDays, "LOG_MAX_AGE_DAYS"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Mail.SMTPPort, "SMTP_PORT"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.EmailVerification.TokenTTL, "EMAIL_VERIFICATION_TTL", "48h"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.PasswordReset.TokenTTL, "PASSWORD_RESET_TTL", "1h"); err != nil {
+		return err
+	}
+	if value := os.Getenv("CORS_ALLOWED_ORIGINS"); value != "" {
+		c.CORS.AllowedOrigins = splitList(value)
+	}
+	if value := os.Getenv("OAUTH_REDIRECT_URLS"); value != "" {
+		c.OAuthRedirectURLs = splitList(value)
+	}
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		c.Proxy.TrustedProxies = splitList(value)
+	}
+	if value := os.Getenv("RATE_LIMIT_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_ENABLED must be true or false, got %q", value)
+		}
+		c.RateLimit.Enabled = enabled
+	}
+	for name, target := range map[string]*Limit{
+		"RATE_LIMIT_AUTH":    &c.RateLimit.Auth,
+		"RATE_LIMIT_ACCOUNT": &c.RateLimit.Account,
+		"RATE_LIMIT_API":     &c.RateLimit.API,
+		"RATE_LIMIT_EMAIL":   &c.RateLimit.Email,
+	} {
+		if err := setLimit(target, name); err != nil {
+			return err
+		}
+	}
+	if err := setInt(&c.RateLimit.Lockout.MaxFailures, "LOGIN_LOCKOUT_MAX_FAILURES"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.RateLimit.Lockout.Window, "LOGIN_LOCKOUT_WINDOW", "15m"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.RateLimit.Lockout.Duration, "LOGIN_LOCKOUT_DURATION", "1m"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.RateLimit.Lockout.MaxDuration, "LOGIN_LOCKOUT_MAX_DURATION", "1h"); err != nil {
+		return err
+	}
+	c.loadOIDCEnv()
+	return nil
+}
+
+// loadOIDCEnv applies OIDC_<NAME>_* to the providers in OIDC_PROVIDERS, adding
+// those the config file doesn't have
+func (c *Config) loadOIDCEnv() {
+	for _, name := range splitList(os.Getenv("OIDC_PROVIDERS")) {
+		index := -1
+		for i := range c.OIDCProviders {
+			if c.OIDCProviders[i].Name == name {
+				index = i
+			}
+		}
+		if index < 0 {
+			c.OIDCProviders = append(c.OIDCProviders, OIDCProviderConfig{Name: name})
+			index = len(c.OIDCProviders) - 1
+		}
+
+		p := &c.OIDCProviders[index]
+		prefix := oidcEnvPrefix(name)
+		setString(&p.Issuer, prefix+"ISSUER")
+		setString(&p.ClientID, prefix+"CLIENT_ID")
+		setString(&p.ClientSecret, prefix+"CLIENT_SECRET")
+		setString(&p.RedirectURL, prefix+"REDIRECT_URI")
+		setString(&p.ResponseMode, prefix+"RESPONSE_MODE")
+		if value := os.Getenv(prefix + "SCOPES"); value != "" {
+			p.Scopes = strings.Fields(strings.ReplaceAll(value, ",", " "))
+		}
+		setString(&p.Claims.Subject, prefix+"CLAIM_SUBJECT")
+		setString(&p.Claims.Email, prefix+"CLAIM_EMAIL")
+		setString(&p.Claims.EmailVerified, prefix+"CLAIM_EMAIL_VERIFIED")
+		setString(&p.Claims.Name, prefix+"CLAIM_NAME")
+	}
+}
+
+// oidcEnvPrefix is the prefix of a provider's variables: OIDC_MY_IDP_ for my-idp
+func oidcEnvPrefix(name string) string {
+	return "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+func setString(target *string, name string) {
+	if value := os.Getenv(name); value != "" {
+		*target = value
+	}
+}
+
+func setInt(target *int, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a number, got %q", name, value)
+	}
+	*target = number
+	return nil
+}
+
+func setDuration(target *Duration, name, example string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration such as %s, got %q", name, example, value)
+	}
+	*target = Duration(duration)
+	return nil
+}
+
+// setLimit reads a limit written as requests/window, e.g. "10/1m"
+func setLimit(target *Limit, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	requests, window, _ := strings.Cut(value, "/")
+	count, countErr := strconv.Atoi(strings.TrimSpace(requests))
+	duration, durationErr := time.ParseDuration(strings.TrimSpace(window))
+	if countErr != nil || durationErr != nil {
+		return fmt.Errorf("%s must be requests/window such as 10/1m, got %q", name, value)
+	}
+	*target = Limit{Requests: count, Window: Duration(duration)}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate checks the whole configuration and reports every problem at once
+func (c *Config) Validate() error {
+	problems := c.Database.problems()
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.JWT.Secret == "" {
+		problems = append(problems, "JWT_SECRET is required")
+	} else if c.IsProduction() && len(c.JWT.Secret) < 32 {
+		problems = append(problems, "JWT_SECRET must be at least 32 characters in production")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.RequestTimeout <= 0 {
+		problems = append(problems, "REQUEST_TIMEOUT must be positive")
+	}
+	if c.JWT.TTL <= 0 {
+		problems = append(problems, "JWT_TTL must be positive")
+	}
+	if !isAbsoluteURL(c.FrontendURL) {
+		problems = append(problems, fmt.Sprintf("FRONTEND_URL must be an absolute URL, got %q", c.FrontendURL))
+	}
+	for _, redirect := range c.OAuthRedirectURLs {
+		if parsed, err := url.Parse(redirect); err != nil || !isAbsoluteURL(redirect) || parsed.Fragment != "" {
+			problems = append(problems, fmt.Sprintf("OAUTH_REDIRECT_URLS contains an invalid URL %q", redirect))
+		}
+	}
+	if len(c.CORS.AllowedOrigins) == 0 {
+		problems = append(problems, "CORS_ALLOWED_ORIGINS must list at least one origin")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		switch {
+		case origin == "*":
+			// Browsers refuse credentialed responses to any origin, and the
+			// API always allows credentials for its cookie sessions
+			problems = append(problems, "CORS_ALLOWED_ORIGINS cannot be * because the API allows credentials; list the frontend origins")
+		case !isAbsoluteURL(origin):
+			problems = append(problems, fmt.Sprintf("CORS_ALLOWED_ORIGINS contains an invalid origin %q", origin))
+		}
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT must be json or text, got %q", c.Log.Format))
+	}
+	if c.Log.MaxSizeMB < 0 || c.Log.MaxAgeDays < 0 {
+		problems = append(problems, "LOG_MAX_SIZE_MB and LOG_MAX_AGE_DAYS cannot be negative")
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "file":
+		if c.Tracing.File == "" {
+			problems = append(problems, "OTEL_TRACES_FILE is required when OTEL_TRACES_EXPORTER is file")
+		}
+	case "otlp":
+		if c.Tracing.OTLPEndpoint == "" {
+			problems = append(problems, "OTEL_EXPORTER_OTLP_ENDPOINT is required when OTEL_TRACES_EXPORTER is otlp")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("OTEL_TRACES_EXPORTER must be none, stdout, file or otlp, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+	}
+	if c.Google.ClientID != "" {
+		if c.Google.ClientSecret == "" {
+			problems = append(problems, "GOOGLE_CLIENT_SECRET is required when GOOGLE_CLIENT_ID is set")
+		}
+		if !isAbsoluteURL(c.Google.RedirectURL) {
+			problems = append(problems, "GOOGLE_REDIRECT_URI must be an absolute URL when GOOGLE_CLIENT_ID is set")
+		}
+	}
+
+	problems = append(problems, c.RateLimit.problems()...)
+	problems = append(problems, c.Proxy.problems()...)
+	problems = append(problems, oidcProblems(c.OIDCProviders)...)
+	problems = append(problems, c.mailProblems()...)
+
+	return validationError(problems)
+}
+
+// ValidateDatabase checks only what is needed to reach the database, for the migrate command
+func (c *Config) ValidateDatabase() error {
+	return validationError(c.Database.problems())
+}
+
+func (d DatabaseConfig) problems() []string {
+	var problems []string
+	switch d.Driver {
+	case "postgres":
+		if d.URL == "" {
+			problems = append(problems, "DATABASE_URL is required when DB_DRIVER is postgres")
+		}
+	case "sqlite":
+		// An empty URL selects an in-memory database
+	default:
+		problems = append(problems, fmt.Sprintf("DB_DRIVER must be postgres or sqlite, got %q", d.Driver))
+	}
+
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS cannot be negative")
+	} else if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		problems = append(problems, fmt.Sprintf("DB_MAX_IDLE_CONNS (%d) cannot exceed DB_MAX_OPEN_CONNS (%d)", d.MaxIdleConns, d.MaxOpenConns))
+	}
+	if d.ConnMaxLifetime < 0 || d.ConnMaxIdleTime < 0 {
+		problems = append(problems, "DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME cannot be negative")
+	}
+	return problems
+}
+
+func (r RateLimitConfig) problems() []string {
+	if !r.Enabled {
+		return nil
+	}
+	var problems []string
+	for name, limit := range map[string]Limit{"RATE_LIMIT_AUTH": r.Auth, "RATE_LIMIT_ACCOUNT": r.Account, "RATE_LIMIT_API": r.API, "RATE_LIMIT_EMAIL": r.Email} {
+		if limit.Requests < 1 || limit.Window <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must allow at least one request in a positive window", name))
+		}
+	}
+	lockout := r.Lockout
+	if lockout.MaxFailures < 1 {
+		problems = append(problems, "LOGIN_LOCKOUT_MAX_FAILURES must be at least 1")
+	}
+	if lockout.Window <= 0 || lockout.Duration <= 0 {
+		problems = append(problems, "LOGIN_LOCKOUT_WINDOW and LOGIN_LOCKOUT_DURATION must be positive")
+	}
+	if lockout.MaxDuration < lockout.Duration {
+		problems = append(problems, "LOGIN_LOCKOUT_MAX_DURATION cannot be shorter than LOGIN_LOCKOUT_DURATION")
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func (p ProxyConfig) problems() []string {
+	var problems []string
+	if p.Header != "" && len(p.TrustedProxies) == 0 {
+		problems = append(problems, "TRUSTED_PROXIES is required when PROXY_HEADER is set, or any client could pick its own address")
+	}
+	for _, proxy := range p.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problems = append(problems, fmt.Sprintf("TRUSTED_PROXIES contains an invalid address or CIDR range %q", proxy))
+			}
+		}
+	}
+	return problems
+}
+
+func oidcProblems(providers []OIDCProviderConfig) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for _, p := range providers {
+		if !oidcProviderName.MatchString(p.Name) {
+			problems = append(problems, fmt.Sprintf("OIDC provider name %q must be lowercase letters, digits and dashes", p.Name))
+			continue
+		}
+		if seen[p.Name] {
+			problems = append(problems, fmt.Sprintf("OIDC provider %q is configured twice", p.Name))
+		}
+		seen[p.Name] = true
+
+		prefix := oidcEnvPrefix(p.Name)
+		if !isAbsoluteURL(p.Issuer) {
+			problems = append(problems, prefix+"ISSUER must be an absolute URL")
+		}
+		if p.ClientID == "" {
+			problems = append(problems, prefix+"CLIENT_ID is required")
+		}
+		if !isAbsoluteURL(p.RedirectURL) {
+			problems = append(problems, prefix+"REDIRECT_URI must be an absolute URL")
+		}
+		if p.ResponseMode != "" && p.ResponseMode != "query" && p.ResponseMode != "form_post" {
+			problems = append(problems, prefix+"RESPONSE_MODE must be query or form_post")
+		}
+	}
+	return problems
+}
+
+func (c *Config) mailProblems() []string {
+	var problems []string
+	switch c.Mail.Driver {
+	case "log":
+		if c.IsProduction() {
+			problems = append(problems, "MAIL_DRIVER=log sends no email; use smtp in production")
+		}
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			problems = append(problems, "SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			problems = append(problems, fmt.Sprintf("MAIL_FROM must be an email address when MAIL_DRIVER is smtp, got %q", c.Mail.From))
+		}
+		if c.Mail.SMTPPort < 1 || c.Mail.SMTPPort > 65535 {
+			problems = append(problems, fmt.Sprintf("SMTP_PORT must be between 1 and 65535, got %d", c.Mail.SMTPPort))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("MAIL_DRIVER must be log or smtp, got %q", c.Mail.Driver))
+	}
+
+	verification := c.EmailVerification
+	if verification.URL != "" && !isAbsoluteURL(verification.URL) {
+		problems = append(problems, fmt.Sprintf("EMAIL_VERIFICATION_URL must be an absolute URL, got %q", verification.URL))
+	}
+	if verification.TokenTTL <= 0 {
+		problems = append(problems, "EMAIL_VERIFICATION_TTL must be positive")
+	}
+	for _, feature := range verification.Restrict {
+		if feature != FeatureBookings && feature != FeatureDriverProfile {
+			problems = append(problems, fmt.Sprintf("EMAIL_VERIFICATION_RESTRICT may list %s and %s, got %q", FeatureBookings, FeatureDriverProfile, feature))
+		}
+	}
+
+	reset := c.PasswordReset
+	if reset.URL != "" && !isAbsoluteURL(reset.URL) {
+		problems = append(problems, fmt.Sprintf("PASSWORD_RESET_URL must be an absolute URL, got %q", reset.URL))
+	}
+	if reset.TokenTTL <= 0 {
+		problems = append(problems, "PASSWORD_RESET_TTL must be positive")
+	}
+	return problems
+}
+
+// VerifyEmailURL is the frontend page email verification links open
+func (c *Config) VerifyEmailURL() string {
+	if c.EmailVerification.URL != "" {
+		return c.EmailVerification.URL
+	}
+	return strings.TrimSuffix(c.FrontendURL, "/") + "/verify-email"
+}
+
+// ResetPasswordURL is the frontend page password reset links open
+func (c *Config) ResetPasswordURL() string {
+	if c.PasswordReset.URL != "" {
+		return c.PasswordReset.URL
+	}
+	return strings.TrimSuffix(c.FrontendURL, "/") + "/reset-password"
+}
+
+// RedirectURLs are the frontend pages sign-ins may return to, the default first
+func (c *Config) RedirectURLs() []string {
+	if len(c.OAuthRedirectURLs) > 0 {
+		return c.OAuthRedirectURLs
+	}
+	return []string{strings.TrimSuffix(c.FrontendURL, "/") + "/success"}
+}
+
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func validationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	content := `{"port": 4000, "database": {"driver": "sqlite"}, "jwt": {"secret": "from-file", "ttl": "2h"}}`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("JWT_SECRET", "from-env")
+	t.Setenv("PORT", "5000")
+	t.Setenv("DB_MAX_OPEN_CONNS", "40")
+
+	cfg, args, err := Load([]string{"-port", "6000", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 6000 {
+		t.Errorf("flag should win over env and file, got port %d", cfg.Port)
+	}
+	if cfg.JWT.Secret != "from-env" {
+		t.Errorf("env should win over file, got secret %q", cfg.JWT.Secret)
+	}
+	if cfg.Database.Driver != "sqlite" || time.Duration(cfg.JWT.TTL) != 2*time.Hour {
+		t.Errorf("file values were not applied: %+v", cfg)
+	}
+	if cfg.Database.MaxOpenConns != 40 || cfg.Database.MaxIdleConns != Default().Database.MaxIdleConns {
+		t.Errorf("pool settings should merge env over defaults: %+v", cfg.Database)
+	}
+	if strings.Join(args, " ") != "migrate up" {
+		t.Errorf("remaining args = %v", args)
+	}
+}
+
+func TestLoadRejectsConflictingGoogleRedirect(t *testing.T) {
+	t.Setenv("GOOGLE_REDIRECT_URI", "https://api.example.com/auth/google/callback")
+	t.Setenv("GOOGLE_CALLBACK_URL", "https://old.example.com/callback")
+
+	if _, _, err := Load(nil); err == nil {
+		t.Fatal("expected an error when both redirect variables disagree")
+	}
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ACCOUNT", "3/30s")
+	t.Setenv("LOGIN_LOCKOUT_MAX_FAILURES", "8")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimit.Account != (Limit{Requests: 3, Window: Duration(30 * time.Second)}) {
+		t.Errorf("RATE_LIMIT_ACCOUNT not applied: %+v", cfg.RateLimit.Account)
+	}
+	if cfg.RateLimit.Auth != Default().RateLimit.Auth || cfg.RateLimit.Lockout.MaxFailures != 8 {
+		t.Errorf("rate limits should merge env over defaults: %+v", cfg.RateLimit)
+	}
+
+	t.Setenv("RATE_LIMIT_API", "lots")
+	if _, _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "RATE_LIMIT_API must be requests/window") {
+		t.Fatalf("expected a malformed limit to be rejected, got %v", err)
+	}
+}
+
+func TestLoadProxy(t *testing.T) {
+	t.Setenv("PROXY_HEADER", "X-Real-IP")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Proxy.Header != "X-Real-IP" || len(cfg.Proxy.TrustedProxies) != 2 || cfg.Proxy.TrustedProxies[1] != "192.168.1.1" {
+		t.Errorf("proxy settings not applied: %+v", cfg.Proxy)
+	}
+
+	cfg.JWT.Secret = "secret"
+	cfg.Database = DatabaseConfig{Driver: "sqlite"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Proxy.TrustedProxies = []string{"load-balancer"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `TRUSTED_PROXIES contains an invalid address or CIDR range "load-balancer"`) {
+		t.Errorf("expected a malformed proxy to be rejected, got %v", err)
+	}
+	cfg.Proxy.TrustedProxies = nil
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "TRUSTED_PROXIES is required when PROXY_HEADER is set") {
+		t.Errorf("a proxy header should need trusted proxies, got %v", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Env = "production"
+	cfg.JWT.Secret = "short"
+	cfg.FrontendURL = "localhost:5173"
+	cfg.Google.ClientID = "client"
+	cfg.Database.MaxIdleConns = 50
+	cfg.RequestTimeout = 0
+	cfg.RateLimit.API.Requests = 0
+	cfg.RateLimit.Lockout.MaxDuration = Duration(time.Second)
+	cfg.OAuthRedirectURLs = []string{"https://app.example.com/done", "/relative"}
+	cfg.CORS.AllowedOrigins = []string{"https://app.example.com", "*"}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation to fail")
+	}
+	for _, want := range []string{
+		"DATABASE_URL is required",
+		"JWT_SECRET must be at least 32 characters",
+		"FRONTEND_URL must be an absolute URL",
+		"GOOGLE_CLIENT_SECRET is required",
+		"GOOGLE_REDIRECT_URI must be an absolute URL",
+		"DB_MAX_IDLE_CONNS (50) cannot exceed DB_MAX_OPEN_CONNS (25)",
+		"REQUEST_TIMEOUT must be positive",
+		"RATE_LIMIT_API must allow at least one request",
+		"LOGIN_LOCKOUT_MAX_DURATION cannot be shorter than LOGIN_LOCKOUT_DURATION",
+		`OAUTH_REDIRECT_URLS contains an invalid URL "/relative"`,
+		"CORS_ALLOWED_ORIGINS cannot be * because the API allows credentials",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error is missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateAcceptsDefaultsWithSecret(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://localhost/app"
+	cfg.JWT.Secret = "dev-secret"
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedirectURLsDefaultToTheFrontend(t *testing.T) {
+	cfg := Default()
+	cfg.FrontendURL = "https://app.example.com/"
+	if got := cfg.RedirectURLs(); len(got) != 1 || got[0] != "https://app.example.com/success" {
+		t.Fatalf("default redirect URLs = %v", got)
+	}
+
+	t.Setenv("OAUTH_REDIRECT_URLS", "https://app.example.com/auth/done, https://admin.example.com/login")
+	loaded, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.RedirectURLs(); len(got) != 2 || got[1] != "https://admin.example.com/login" {
+		t.Fatalf("OAUTH_REDIRECT_URLS not applied: %v", got)
+	}
+}
+
+func TestLoadOIDCProviders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	content := `{"oidc_providers": [{"name": "corp", "issuer": "https://idp.corp.example", "client_id": "from-file",
+		"redirect_url": "https://api.example.com/auth/oidc/corp/callback", "claims": {"email": "upn"}}]}`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("OIDC_PROVIDERS", "corp, apple-id")
+	t.Setenv("OIDC_CORP_CLIENT_ID", "from-env")
+	t.Setenv("OIDC_APPLE_ID_ISSUER", "https://appleid.apple.com")
+	t.Setenv("OIDC_APPLE_ID_CLIENT_ID", "com.example.web")
+	t.Setenv("OIDC_APPLE_ID_REDIRECT_URI", "https://api.example.com/auth/oidc/apple-id/callback")
+	t.Setenv("OIDC_APPLE_ID_SCOPES", "openid,email name")
+	t.Setenv("OIDC_APPLE_ID_RESPONSE_MODE", "form_post")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.OIDCProviders) != 2 {
+		t.Fatalf("expected 2 providers, got %+v", cfg.OIDCProviders)
+	}
+	corp, apple := cfg.OIDCProviders[0], cfg.OIDCProviders[1]
+	if corp.ClientID != "from-env" || corp.Claims.Email != "upn" || corp.Issuer != "https://idp.corp.example" {
+		t.Errorf("env should merge over the file's provider: %+v", corp)
+	}
+	if apple.Name != "apple-id" || strings.Join(apple.Scopes, " ") != "openid email name" || apple.ResponseMode != "form_post" {
+		t.Errorf("provider from env not loaded: %+v", apple)
+	}
+
+	cfg.JWT.Secret = "secret"
+	cfg.Database = DatabaseConfig{Driver: "sqlite"}
+	cfg.OIDCProviders = append(cfg.OIDCProviders, OIDCProviderConfig{Name: "Bad Name"}, OIDCProviderConfig{Name: "corp"}, OIDCProviderConfig{Name: "partner"})
+	err = cfg.Validate()
+	for _, want := range []string{
+		`OIDC provider name "Bad Name" must be lowercase`,
+		`OIDC provider "corp" is configured twice`,
+		"OIDC_PARTNER_ISSUER must be an absolute URL",
+		"OIDC_PARTNER_CLIENT_ID is required",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error is missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadMailAndEmailVerification(t *testing.T) {
+	t.Setenv("MAIL_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "2525")
+	t.Setenv("EMAIL_VERIFICATION_RESTRICT", "")
+	t.Setenv("PASSWORD_RESET_TTL", "30m")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Mail.SMTPHost != "smtp.example.com" || cfg.Mail.SMTPPort != 2525 || len(cfg.EmailVerification.Restrict) != 0 {
+		t.Errorf("mail settings not applied: %+v %+v", cfg.Mail, cfg.EmailVerification)
+	}
+	if cfg.VerifyEmailURL() != "http://localhost:5173/verify-email" {
+		t.Errorf("verification URL should default to the frontend, got %q", cfg.VerifyEmailURL())
+	}
+	if cfg.ResetPasswordURL() != "http://localhost:5173/reset-password" || cfg.PasswordReset.TokenTTL != Duration(30*time.Minute) {
+		t.Errorf("password reset settings not applied: %q %+v", cfg.ResetPasswordURL(), cfg.PasswordReset)
+	}
+
+	cfg.JWT.Secret = "secret"
+	cfg.Database = DatabaseConfig{Driver: "sqlite"}
+	cfg.EmailVerification.Restrict = []string{FeatureBookings, "reviews"}
+	cfg.PasswordReset.URL = "/reset"
+	err = cfg.Validate()
+	for _, want := range []string{
+		`MAIL_FROM must be an email address when MAIL_DRIVER is smtp, got ""`,
+		`EMAIL_VERIFICATION_RESTRICT may list bookings and driver_profile, got "reviews"`,
+		`PASSWORD_RESET_URL must be an absolute URL, got "/reset"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error is missing %q:\n%v", want, err)
+		}
+	}
+
+	production := Default()
+	production.Env = "production"
+	if err := production.Validate(); err == nil || !strings.Contains(err.Error(), "MAIL_DRIVER=log sends no email") {
+		t.Errorf("production should require a real mail driver, got %v", err)
+	}
+}
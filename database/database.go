@@ -1,49 +1,150 @@
 package database
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
-	"os"
+	"strings"
+	"time"
 
-	"fiber-backend/models"
+	"fiber-backend/config"
+	"fiber-backend/metrics"
+	"fiber-backend/tracing"
 	"fiber-backend/utils"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
+// Supported values for DB_DRIVER
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
 
-func Connect() {
-	dsn := os.Getenv("DATABASE_URL")
+// InMemoryDSN opens a private SQLite database that lives as long as the connection
+const InMemoryDSN = ":memory:"
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Open connects to the configured database and sizes its pool, without checking the schema
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := OpenWith(cfg.Driver, cfg.URL)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, err
+	}
+	// An in-memory database lives on its single connection, which must never be recycled
+	if cfg.Driver == DriverSQLite && isInMemory(cfg.URL) {
+		return db, nil
 	}
 
-	// Create the enum type if it doesn't exist
-	db.Exec(`DO $$ BEGIN
-		CREATE TYPE user_role AS ENUM ('tourist', 'driver', 'admin');
-		EXCEPTION WHEN duplicate_object THEN null;
-	END $$;`)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	configurePool(sqlDB, cfg)
+	return db, nil
+}
 
-	// Auto Migrate the schema
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Driver{},
-		&models.Tourist{},
-		&models.Booking{},
-		&models.TouristRequest{},
+// configurePool applies the pool limits that are set; zero keeps database/sql's default
+func configurePool(sqlDB *sql.DB, cfg config.DatabaseConfig) {
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+	}
+}
+
+// OpenWith connects to the given driver. For SQLite an empty dsn means an in-memory database.
+func OpenWith(driver, dsn string) (*gorm.DB, error) {
+	config := &gorm.Config{
+		// Surface unique violations as gorm.ErrDuplicatedKey for the repositories
+		TranslateError: true,
+		Logger:         gormLogger{},
+	}
+
+	var (
+		db  *gorm.DB
+		err error
 	)
+	switch driver {
+	case DriverPostgres:
+		db, err = gorm.Open(postgres.Open(dsn), config)
+	case DriverSQLite:
+		if dsn == "" {
+			dsn = InMemoryDSN
+		}
+		db, err = gorm.Open(sqlite.Open(sqliteDSN(dsn)), config)
+		if err == nil && isInMemory(dsn) {
+			// Every new connection to :memory: would see an empty database
+			var sqlDB *sql.DB
+			if sqlDB, err = db.DB(); err == nil {
+				sqlDB.SetMaxOpenConns(1)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (expected %q or %q)", driver, DriverPostgres, DriverSQLite)
+	}
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		return nil, err
 	}
 
-	// Ensure google_id is nullable
-	if err := db.Exec(`ALTER TABLE users ALTER COLUMN google_id DROP NOT NULL;`).Error; err != nil {
-		utils.LogError("Failed to make google_id nullable: %v", err)
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// sqliteDSN turns on foreign keys, which SQLite leaves off by default
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "foreign_keys") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=foreign_keys(1)"
+}
+
+func isInMemory(dsn string) bool {
+	return dsn == "" || strings.HasPrefix(dsn, InMemoryDSN) || strings.Contains(dsn, "mode=memory")
+}
+
+// Connect opens the database and refuses to continue if migrations are pending.
+// In-memory SQLite databases start empty, so they are migrated on the spot.
+func Connect(cfg config.DatabaseConfig) *gorm.DB {
+	db, err := Open(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if db.Dialector.Name() == DriverSQLite && isInMemory(cfg.URL) {
+		if _, err := MigrateUp(db); err != nil {
+			log.Fatal("Failed to migrate in-memory database:", err)
+		}
+	}
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		log.Fatal("Failed to read migration status:", err)
+	}
+	if len(pending) > 0 {
+		for _, migration := range pending {
+			utils.LogError("Pending migration: %d_%s", migration.Version, migration.Name)
+		}
+		log.Fatalf("Database schema is behind the code (%d pending migrations). Run `migrate up` first.", len(pending))
 	}
 
-	DB = db
-	utils.LogInfo("Database connected and migrated successfully")
+	utils.LogInfo("Database connected (%s), schema is up to date", db.Dialector.Name())
+	return db
 }
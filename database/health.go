@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"fiber-backend/health"
+
+	"gorm.io/gorm"
+)
+
+// PingCheck reports whether the database accepts connections
+func PingCheck(db *gorm.DB) health.Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// MigrationsCheck reports whether the schema has every migration this build expects
+func MigrationsCheck(db *gorm.DB) health.Check {
+	return func(ctx context.Context) error {
+		pending, err := PendingMigrations(db.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations", len(pending))
+		}
+		return nil
+	}
+}
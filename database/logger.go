@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"fiber-backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is how long a query may take before it is logged as a warning
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger sends GORM's output to the structured logger. Every query is
+// logged at debug level, slow ones at warn and failures at error.
+type gormLogger struct{}
+
+func (gormLogger) LogMode(logger.LogLevel) logger.Interface {
+	// The level is controlled by the application log level instead
+	return gormLogger{}
+}
+
+func (gormLogger) Info(ctx context.Context, format string, args ...interface{}) {
+	utils.Logger().InfoContext(ctx, fmt.Sprintf(format, args...), "component", "gorm")
+}
+
+func (gormLogger) Warn(ctx context.Context, format string, args ...interface{}) {
+	utils.Logger().WarnContext(ctx, fmt.Sprintf(format, args...), "component", "gorm")
+}
+
+func (gormLogger) Error(ctx context.Context, format string, args ...interface{}) {
+	utils.Logger().ErrorContext(ctx, fmt.Sprintf(format, args...), "component", "gorm")
+}
+
+// ParamsFilter keeps bound values out of logged SQL, since they include
+// password hashes, emails and other personal data
+func (gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+func (gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	level := slog.LevelDebug
+	message := "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, message = slog.LevelError, "query failed"
+	case elapsed > slowQueryThreshold:
+		level, message = slog.LevelWarn, "slow query"
+	}
+
+	log := utils.Logger()
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{"component", "gorm", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds()}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	log.Log(ctx, level, message, attrs...)
+}
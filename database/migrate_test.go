@@ -0,0 +1,160 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func openMemory(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := OpenWith(DriverSQLite, InMemoryDSN)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// migrateDownTo reverts the migrations after version, however many were added
+// since the test was written
+func migrateDownTo(t *testing.T, db *gorm.DB, version int) {
+	t.Helper()
+	migrations, err := LoadMigrations(db.Dialector.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for _, migration := range migrations {
+		if migration.Version > version {
+			steps++
+		}
+	}
+	if _, err := MigrateDown(db, steps); err != nil {
+		t.Fatalf("migrate down to %d: %v", version, err)
+	}
+}
+
+func TestDialectsShareMigrationVersions(t *testing.T) {
+	postgres, err := LoadMigrations(DriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := LoadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite has %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d differs: postgres %d_%s, sqlite %d_%s", i,
+				postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestSQLiteMigratesUpAndDown(t *testing.T) {
+	db := openMemory(t)
+	migrations, err := LoadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", applied, len(migrations))
+	}
+	if pending, _ := PendingMigrations(db); len(pending) != 0 {
+		t.Fatalf("%d migrations still pending", len(pending))
+	}
+	if !db.Migrator().HasColumn("drivers", "version") {
+		t.Fatal("drivers.version was not created")
+	}
+
+	err = db.Exec("INSERT INTO users (email, password, role) VALUES ('a@example.com', 'x', 'pilot')").Error
+	if err == nil {
+		t.Fatal("role outside the user_role values was accepted")
+	}
+	err = db.Exec("INSERT INTO drivers (user_id, license_number, vehicle_type, vehicle_model, vehicle_color, languages, experience) VALUES (999, 'L', 't', 'm', 'c', 'es', 1)").Error
+	if err == nil {
+		t.Fatal("driver with a missing user was accepted, foreign keys are off")
+	}
+
+	reverted, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if reverted != len(migrations) {
+		t.Fatalf("reverted %d migrations, want %d", reverted, len(migrations))
+	}
+	if db.Migrator().HasTable("users") {
+		t.Fatal("users table survived migrate down")
+	}
+}
+
+func TestSQLiteMovesGoogleIDsToIdentities(t *testing.T) {
+	db := openMemory(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	// Back to before the move, with a Google user and a password user that
+	// both have rows referencing them
+	migrateDownTo(t, db, 7)
+	for _, statement := range []string{
+		"INSERT INTO users (id, email, password, google_id, role) VALUES (1, 'ana@example.com', 'random-unhashed', 'google-ana', 'tourist')",
+		"INSERT INTO users (id, email, password, role) VALUES (2, 'bob@example.com', '$2a$10$hash', 'tourist')",
+		"INSERT INTO tourists (user_id, nationality, language, arrival_date, departure_date) VALUES (1, 'CL', 'es', '2025-06-01', '2025-06-10')",
+		"INSERT INTO tourists (user_id, nationality, language, arrival_date, departure_date) VALUES (2, 'AR', 'es', '2025-06-01', '2025-06-10')",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if db.Migrator().HasColumn("users", "google_id") {
+		t.Fatal("users.google_id survived")
+	}
+	var identities []struct {
+		UserID   uint
+		Provider string
+		Subject  string
+	}
+	db.Raw("SELECT user_id, provider, subject FROM user_identities").Scan(&identities)
+	if len(identities) != 1 || identities[0].UserID != 1 || identities[0].Provider != "google" || identities[0].Subject != "google-ana" {
+		t.Fatalf("unexpected identities %+v", identities)
+	}
+	var passwords []string
+	db.Raw("SELECT password FROM users ORDER BY id").Scan(&passwords)
+	if len(passwords) != 2 || passwords[0] != "" || passwords[1] != "$2a$10$hash" {
+		t.Fatalf("only the unhashed password should be cleared, got %q", passwords)
+	}
+	var tourists int64
+	db.Raw("SELECT count(*) FROM tourists").Scan(&tourists)
+	if tourists != 2 {
+		t.Fatalf("rebuilding users lost tourists, %d left", tourists)
+	}
+	err := db.Exec("INSERT INTO tourists (user_id, nationality, language, arrival_date, departure_date) VALUES (99, 'CL', 'es', '2025-06-01', '2025-06-10')").Error
+	if err == nil {
+		t.Fatal("tourist with a missing user was accepted, foreign keys are off")
+	}
+
+	// And back again
+	migrateDownTo(t, db, 7)
+	var googleID string
+	db.Raw("SELECT google_id FROM users WHERE id = 1").Scan(&googleID)
+	if googleID != "google-ana" {
+		t.Fatalf("google_id not restored, got %q", googleID)
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS tourist_requests;
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS tourists;
+DROP TABLE IF EXISTS drivers;
+DROP TABLE IF EXISTS users;
+DROP TYPE IF EXISTS user_role;
@@ -0,0 +1,86 @@
+-- Baseline schema. Written with IF NOT EXISTS so databases created by the old
+-- AutoMigrate startup can adopt versioned migrations without being rebuilt.
+
+DO $$ BEGIN
+	CREATE TYPE user_role AS ENUM ('tourist', 'driver', 'admin');
+	EXCEPTION WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS users (
+	id         bigserial PRIMARY KEY,
+	email      text NOT NULL UNIQUE,
+	password   text NOT NULL,
+	name       text,
+	google_id  text UNIQUE,
+	role       user_role DEFAULT null,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- Older databases created google_id as NOT NULL
+ALTER TABLE users ALTER COLUMN google_id DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS drivers (
+	id             bigserial PRIMARY KEY,
+	created_at     timestamptz,
+	updated_at     timestamptz,
+	deleted_at     timestamptz,
+	user_id        bigint NOT NULL CONSTRAINT fk_drivers_user REFERENCES users (id),
+	license_number text NOT NULL,
+	vehicle_type   text NOT NULL,
+	vehicle_model  text NOT NULL,
+	vehicle_color  text NOT NULL,
+	languages      text NOT NULL,
+	experience     bigint NOT NULL,
+	rating         numeric DEFAULT 0,
+	status         text DEFAULT 'pending',
+	is_available   boolean DEFAULT true
+);
+CREATE INDEX IF NOT EXISTS idx_drivers_deleted_at ON drivers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tourists (
+	id             bigserial PRIMARY KEY,
+	created_at     timestamptz,
+	updated_at     timestamptz,
+	deleted_at     timestamptz,
+	user_id        bigint NOT NULL CONSTRAINT fk_tourists_user REFERENCES users (id),
+	nationality    text NOT NULL,
+	language       text NOT NULL,
+	arrival_date   timestamptz NOT NULL,
+	departure_date timestamptz NOT NULL,
+	preferences    text,
+	special_needs  text,
+	status         text DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS idx_tourists_deleted_at ON tourists (deleted_at);
+
+CREATE TABLE IF NOT EXISTS bookings (
+	id               bigserial PRIMARY KEY,
+	created_at       timestamptz,
+	updated_at       timestamptz,
+	deleted_at       timestamptz,
+	tourist_id       bigint NOT NULL CONSTRAINT fk_bookings_tourist REFERENCES tourists (id),
+	driver_id        bigint NOT NULL CONSTRAINT fk_bookings_driver REFERENCES drivers (id),
+	status           text DEFAULT 'pending',
+	booked_at        timestamptz NOT NULL,
+	pickup_location  text,
+	dropoff_location text,
+	date_time        text
+);
+CREATE INDEX IF NOT EXISTS idx_bookings_deleted_at ON bookings (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tourist_requests (
+	id               bigserial PRIMARY KEY,
+	created_at       timestamptz,
+	updated_at       timestamptz,
+	deleted_at       timestamptz,
+	tourist_id       bigint NOT NULL CONSTRAINT fk_tourist_requests_tourist REFERENCES tourists (id),
+	pickup_location  text NOT NULL,
+	dropoff_location text NOT NULL,
+	date_time        text NOT NULL,
+	notes            text,
+	status           varchar(20) DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS idx_tourist_requests_deleted_at ON tourist_requests (deleted_at);
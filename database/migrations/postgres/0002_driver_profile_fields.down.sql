@@ -0,0 +1,2 @@
+ALTER TABLE drivers DROP COLUMN IF EXISTS review_count;
+ALTER TABLE drivers DROP COLUMN IF EXISTS photo_url;
@@ -0,0 +1,2 @@
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS photo_url text;
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS review_count bigint DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE tourist_requests DROP COLUMN IF EXISTS version;
+ALTER TABLE bookings DROP COLUMN IF EXISTS version;
+ALTER TABLE tourists DROP COLUMN IF EXISTS version;
+ALTER TABLE drivers DROP COLUMN IF EXISTS version;
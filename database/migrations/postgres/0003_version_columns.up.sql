@@ -0,0 +1,5 @@
+-- Row versions for optimistic locking, exposed to clients as ETags
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE tourists ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE tourist_requests ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,12 @@
+-- Security-relevant events such as failed logins and account lockouts. user_id
+-- is not a foreign key: attempts against unknown emails are recorded too.
+CREATE TABLE IF NOT EXISTS audit_events (
+	id         bigserial PRIMARY KEY,
+	event      varchar(50) NOT NULL,
+	user_id    bigint,
+	email      text NOT NULL DEFAULT '',
+	ip         varchar(64) NOT NULL DEFAULT '',
+	detail     text NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_email ON audit_events (email, created_at);
@@ -0,0 +1,11 @@
+-- Single-use codes exchanged for an access token after a sign-in redirect.
+-- Rows are deleted when used; expired ones are swept when new codes are issued.
+CREATE TABLE IF NOT EXISTS login_codes (
+	id         bigserial PRIMARY KEY,
+	code_hash  varchar(64) NOT NULL,
+	user_id    bigint NOT NULL CONSTRAINT fk_login_codes_user REFERENCES users (id),
+	expires_at timestamptz NOT NULL,
+	created_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_codes_code_hash ON login_codes (code_hash);
+CREATE INDEX IF NOT EXISTS idx_login_codes_expires_at ON login_codes (expires_at);
@@ -0,0 +1,12 @@
+-- Accounts at external sign-in providers linked to users, one row per
+-- provider account. Google keeps using users.google_id for now.
+CREATE TABLE IF NOT EXISTS user_identities (
+	id         bigserial PRIMARY KEY,
+	user_id    bigint NOT NULL CONSTRAINT fk_user_identities_user REFERENCES users (id),
+	provider   varchar(50) NOT NULL,
+	subject    text NOT NULL,
+	email      text NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_subject ON user_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS google_id text UNIQUE;
+
+UPDATE users SET google_id = i.subject
+FROM user_identities i
+WHERE i.user_id = users.id AND i.provider = 'google';
+
+DELETE FROM user_identities WHERE provider = 'google';
+
+ALTER TABLE user_identities RENAME COLUMN linked_at TO created_at;
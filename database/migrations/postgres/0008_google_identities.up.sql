@@ -0,0 +1,16 @@
+-- Google accounts become identities like those of any other provider, and
+-- users.google_id goes away
+ALTER TABLE user_identities RENAME COLUMN created_at TO linked_at;
+
+-- When the account was linked isn't known; the user's last update is the closest
+INSERT INTO user_identities (user_id, provider, subject, email, linked_at)
+SELECT id, 'google', google_id, email, COALESCE(updated_at, now())
+FROM users
+WHERE google_id IS NOT NULL
+ON CONFLICT (provider, subject) DO NOTHING;
+
+ALTER TABLE users DROP COLUMN google_id;
+
+-- Accounts created through Google got an unhashed random password that could
+-- never be used; an empty password now means the user has none
+UPDATE users SET password = '' WHERE password NOT LIKE '$2%';
@@ -0,0 +1,19 @@
+-- Users confirm their email address through a single-use token sent to it.
+-- Accounts that exist already were created before addresses were checked and
+-- count as verified, so nobody loses access to what they could do before.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamptz;
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+-- Tokens emailed as links. Rows are deleted when used or replaced; expired ones
+-- are swept when new tokens are issued.
+CREATE TABLE IF NOT EXISTS email_tokens (
+	id         bigserial PRIMARY KEY,
+	token_hash varchar(64) NOT NULL,
+	user_id    bigint NOT NULL CONSTRAINT fk_email_tokens_user REFERENCES users (id),
+	purpose    varchar(20) NOT NULL,
+	expires_at timestamptz NOT NULL,
+	created_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_tokens_token_hash ON email_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user_purpose ON email_tokens (user_id, purpose);
+CREATE INDEX IF NOT EXISTS idx_email_tokens_expires_at ON email_tokens (expires_at);
@@ -0,0 +1,4 @@
+-- Access tokens carry the version of the user's sessions they were issued
+-- for. Changing or resetting the password bumps it, so every older token stops
+-- working.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS session_version bigint NOT NULL DEFAULT 0;
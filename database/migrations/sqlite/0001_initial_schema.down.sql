@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS tourist_requests;
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS tourists;
+DROP TABLE IF EXISTS drivers;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,77 @@
+-- SQLite has no enum types; a CHECK constraint stands in for user_role.
+
+CREATE TABLE IF NOT EXISTS users (
+	id         integer PRIMARY KEY AUTOINCREMENT,
+	email      text NOT NULL UNIQUE,
+	password   text NOT NULL,
+	name       text,
+	google_id  text UNIQUE,
+	role       text DEFAULT null CHECK (role IN ('tourist', 'driver', 'admin')),
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS drivers (
+	id             integer PRIMARY KEY AUTOINCREMENT,
+	created_at     datetime,
+	updated_at     datetime,
+	deleted_at     datetime,
+	user_id        integer NOT NULL CONSTRAINT fk_drivers_user REFERENCES users (id),
+	license_number text NOT NULL,
+	vehicle_type   text NOT NULL,
+	vehicle_model  text NOT NULL,
+	vehicle_color  text NOT NULL,
+	languages      text NOT NULL,
+	experience     integer NOT NULL,
+	rating         real DEFAULT 0,
+	status         text DEFAULT 'pending',
+	is_available   numeric DEFAULT true
+);
+CREATE INDEX IF NOT EXISTS idx_drivers_deleted_at ON drivers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tourists (
+	id             integer PRIMARY KEY AUTOINCREMENT,
+	created_at     datetime,
+	updated_at     datetime,
+	deleted_at     datetime,
+	user_id        integer NOT NULL CONSTRAINT fk_tourists_user REFERENCES users (id),
+	nationality    text NOT NULL,
+	language       text NOT NULL,
+	arrival_date   datetime NOT NULL,
+	departure_date datetime NOT NULL,
+	preferences    text,
+	special_needs  text,
+	status         text DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS idx_tourists_deleted_at ON tourists (deleted_at);
+
+CREATE TABLE IF NOT EXISTS bookings (
+	id               integer PRIMARY KEY AUTOINCREMENT,
+	created_at       datetime,
+	updated_at       datetime,
+	deleted_at       datetime,
+	tourist_id       integer NOT NULL CONSTRAINT fk_bookings_tourist REFERENCES tourists (id),
+	driver_id        integer NOT NULL CONSTRAINT fk_bookings_driver REFERENCES drivers (id),
+	status           text DEFAULT 'pending',
+	booked_at        datetime NOT NULL,
+	pickup_location  text,
+	dropoff_location text,
+	date_time        text
+);
+CREATE INDEX IF NOT EXISTS idx_bookings_deleted_at ON bookings (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tourist_requests (
+	id               integer PRIMARY KEY AUTOINCREMENT,
+	created_at       datetime,
+	updated_at       datetime,
+	deleted_at       datetime,
+	tourist_id       integer NOT NULL CONSTRAINT fk_tourist_requests_tourist REFERENCES tourists (id),
+	pickup_location  text NOT NULL,
+	dropoff_location text NOT NULL,
+	date_time        text NOT NULL,
+	notes            text,
+	status           varchar(20) DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS idx_tourist_requests_deleted_at ON tourist_requests (deleted_at);
@@ -0,0 +1,2 @@
+ALTER TABLE drivers DROP COLUMN review_count;
+ALTER TABLE drivers DROP COLUMN photo_url;
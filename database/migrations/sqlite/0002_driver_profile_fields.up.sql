@@ -0,0 +1,2 @@
+ALTER TABLE drivers ADD COLUMN photo_url text;
+ALTER TABLE drivers ADD COLUMN review_count integer DEFAULT 0;
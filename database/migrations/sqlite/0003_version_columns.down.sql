@@ -0,0 +1,4 @@
+ALTER TABLE tourist_requests DROP COLUMN version;
+ALTER TABLE bookings DROP COLUMN version;
+ALTER TABLE tourists DROP COLUMN version;
+ALTER TABLE drivers DROP COLUMN version;
@@ -0,0 +1,4 @@
+ALTER TABLE drivers ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE tourists ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE bookings ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE tourist_requests ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Language the user chose for API messages; empty means negotiate from Accept-Language
+ALTER TABLE users ADD COLUMN language text NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- SQLite can't add a UNIQUE column; the index enforces it instead
+ALTER TABLE users ADD COLUMN google_id text;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_google_id ON users (google_id);
+
+UPDATE users SET google_id = (
+	SELECT subject FROM user_identities i
+	WHERE i.user_id = users.id AND i.provider = 'google'
+	ORDER BY i.id LIMIT 1
+);
+
+DELETE FROM user_identities WHERE provider = 'google';
+
+ALTER TABLE user_identities RENAME COLUMN linked_at TO created_at;
@@ -0,0 +1,36 @@
+-- Google accounts become identities like those of any other provider, and
+-- users.google_id goes away
+ALTER TABLE user_identities RENAME COLUMN created_at TO linked_at;
+
+-- When the account was linked isn't known; the user's last update is the closest
+INSERT INTO user_identities (user_id, provider, subject, email, linked_at)
+SELECT id, 'google', google_id, email, COALESCE(updated_at, CURRENT_TIMESTAMP)
+FROM users
+WHERE google_id IS NOT NULL
+ON CONFLICT (provider, subject) DO NOTHING;
+
+-- SQLite can't drop a UNIQUE column, so users is rebuilt without it. The rows
+-- referencing users are checked at commit, once their users are back.
+PRAGMA defer_foreign_keys = ON;
+CREATE TEMP TABLE users_backup AS
+SELECT id, email, password, name, role, language, created_at, updated_at, deleted_at FROM users;
+DROP TABLE users;
+CREATE TABLE users (
+	id         integer PRIMARY KEY AUTOINCREMENT,
+	email      text NOT NULL UNIQUE,
+	password   text NOT NULL,
+	name       text,
+	role       text DEFAULT null CHECK (role IN ('tourist', 'driver', 'admin')),
+	language   text NOT NULL DEFAULT '',
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime
+);
+INSERT INTO users (id, email, password, name, role, language, created_at, updated_at, deleted_at)
+SELECT id, email, password, name, role, language, created_at, updated_at, deleted_at FROM users_backup;
+DROP TABLE users_backup;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- Accounts created through Google got an unhashed random password that could
+-- never be used; an empty password now means the user has none
+UPDATE users SET password = '' WHERE password NOT LIKE '$2%';
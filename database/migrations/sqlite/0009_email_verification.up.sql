@@ -0,0 +1,19 @@
+-- Users confirm their email address through a single-use token sent to it.
+-- Accounts that exist already were created before addresses were checked and
+-- count as verified, so nobody loses access to what they could do before.
+ALTER TABLE users ADD COLUMN email_verified_at datetime;
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+-- Tokens emailed as links. Rows are deleted when used or replaced; expired ones
+-- are swept when new tokens are issued.
+CREATE TABLE IF NOT EXISTS email_tokens (
+	id         integer PRIMARY KEY AUTOINCREMENT,
+	token_hash text NOT NULL,
+	user_id    integer NOT NULL CONSTRAINT fk_email_tokens_user REFERENCES users (id),
+	purpose    text NOT NULL,
+	expires_at datetime NOT NULL,
+	created_at datetime NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_tokens_token_hash ON email_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user_purpose ON email_tokens (user_id, purpose);
+CREATE INDEX IF NOT EXISTS idx_email_tokens_expires_at ON email_tokens (expires_at);
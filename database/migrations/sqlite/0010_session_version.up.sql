@@ -0,0 +1,4 @@
+-- Access tokens carry the version of the user's sessions they were issued
+-- for. Changing or resetting the password bumps it, so every older token stops
+-- working.
+ALTER TABLE users ADD COLUMN session_version integer NOT NULL DEFAULT 0;
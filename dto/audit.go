@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"fiber-backend/models"
+	"time"
+)
+
+// AuditEventResponse is one entry of GET /admin/audit-events
+type AuditEventResponse struct {
+	ID        uint      `json:"id"`
+	Event     string    `json:"event"`
+	UserID    *uint     `json:"user_id"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ToAuditEventResponses maps audit events to their admin view
+func ToAuditEventResponses(events []models.AuditEvent) []AuditEventResponse {
+	responses := make([]AuditEventResponse, len(events))
+	for i := range events {
+		event := &events[i]
+		responses[i] = AuditEventResponse{
+			ID:        event.ID,
+			Event:     event.Event,
+			UserID:    event.UserID,
+			Email:     event.Email,
+			IP:        event.IP,
+			Detail:    event.Detail,
+			CreatedAt: event.CreatedAt,
+		}
+	}
+	return responses
+}
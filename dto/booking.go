@@ -0,0 +1,88 @@
+package dto
+
+import (
+	"fiber-backend/models"
+	"time"
+)
+
+// BookingCreateRequest is the body of POST /api/bookings
+type BookingCreateRequest struct {
+	DriverID        uint   `json:"driver_id" validate:"required"`
+	PickupLocation  string `json:"pickup_location" validate:"required,max=255"`
+	DropoffLocation string `json:"dropoff_location" validate:"required,max=255"`
+	DateTime        string `json:"date_time" validate:"required,datetime"`
+}
+
+// ToModel builds a pending booking from the request; the tourist is the caller's
+func (r *BookingCreateRequest) ToModel() models.Booking {
+	return models.Booking{
+		DriverID:        r.DriverID,
+		PickupLocation:  r.PickupLocation,
+		DropoffLocation: r.DropoffLocation,
+		DateTime:        r.DateTime,
+	}
+}
+
+// BookDriverRequest is the body of POST /api/tourists/book-driver
+type BookDriverRequest struct {
+	DriverID        uint   `json:"driverId" validate:"required"`
+	PickupLocation  string `json:"pickup_location" validate:"required,max=255"`
+	DropoffLocation string `json:"dropoff_location" validate:"required,max=255"`
+	DateTime        string `json:"date_time" validate:"required,datetime"`
+}
+
+// ToModel builds the booking the request asks for; the tourist is the caller's
+func (r *BookDriverRequest) ToModel() models.Booking {
+	return models.Booking{
+		DriverID:        r.DriverID,
+		PickupLocation:  r.PickupLocation,
+		DropoffLocation: r.DropoffLocation,
+		DateTime:        r.DateTime,
+	}
+}
+
+// BookingStatusRequest is the body of PATCH /api/bookings/:id/status
+type BookingStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=pending confirmed completed cancelled"`
+}
+
+// BookingResponse is a booking as seen by the tourist and driver involved in it
+type BookingResponse struct {
+	ID              uint                `json:"id"`
+	Status          string              `json:"status"`
+	BookedAt        time.Time           `json:"booked_at"`
+	PickupLocation  string              `json:"pickup_location"`
+	DropoffLocation string              `json:"dropoff_location"`
+	DateTime        string              `json:"date_time"`
+	Driver          DriverPublicProfile `json:"driver"`
+	Tourist         TouristSummary      `json:"tourist"`
+	Version         uint                `json:"version"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// ToBookingResponse maps a booking to its response. Driver.User and Tourist.User should be preloaded.
+func ToBookingResponse(booking *models.Booking) BookingResponse {
+	return BookingResponse{
+		ID:              booking.ID,
+		Status:          booking.Status,
+		BookedAt:        booking.BookedAt,
+		PickupLocation:  booking.PickupLocation,
+		DropoffLocation: booking.DropoffLocation,
+		DateTime:        booking.DateTime,
+		Driver:          ToPublicProfile(&booking.Driver),
+		Tourist:         ToTouristSummary(&booking.Tourist),
+		Version:         booking.Version,
+		CreatedAt:       booking.CreatedAt,
+		UpdatedAt:       booking.UpdatedAt,
+	}
+}
+
+// ToBookingResponses maps a list of bookings to their responses
+func ToBookingResponses(bookings []models.Booking) []BookingResponse {
+	responses := make([]BookingResponse, 0, len(bookings))
+	for i := range bookings {
+		responses = append(responses, ToBookingResponse(&bookings[i]))
+	}
+	return responses
+}
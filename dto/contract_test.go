@@ -0,0 +1,219 @@
+package dto
+
+import (
+	"encoding/json"
+	"fiber-backend/models"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The contract below lists every JSON key each response may contain. A new key
+// showing up in a response must be added here on purpose, so model fields can't
+// leak into the API by accident.
+var responseContract = map[string][]string{
+	"UserResponse":           {"email", "id", "name", "role"},
+	"AuthResponse":           {"token", "user", "user.email", "user.id", "user.name", "user.role"},
+	"MeResponse":             {"created_at", "email", "email_verified", "google_id", "has_password", "id", "language", "name", "role"},
+	"DriverResponse":         {"created_at", "experience", "id", "is_available", "languages", "license_number", "photo_url", "rating", "review_count", "status", "updated_at", "vehicle_color", "vehicle_model", "vehicle_type", "version"},
+	"DriverPublicProfile":    {"id", "is_available", "languages", "name", "photo_url", "rating", "review_count", "vehicle", "vehicle.color", "vehicle.model", "vehicle.type"},
+	"TouristResponse":        {"arrival_date", "created_at", "departure_date", "id", "language", "nationality", "preferences", "special_needs", "status", "updated_at", "version"},
+	"TouristRequestResponse": {"created_at", "date_time", "dropoff_location", "id", "notes", "pickup_location", "status", "version"},
+	"AuditEventResponse":     {"created_at", "detail", "email", "event", "id", "ip", "user_id"},
+	"ProviderResponse":       {"auth_url", "name"},
+	"IdentityResponse":       {"email", "id", "linked_at", "provider"},
+	"LinkStartedResponse":    {"auth_url"},
+	"MessageResponse":        {"message"},
+	"BookingResponse": {
+		"booked_at", "created_at", "date_time", "driver", "driver.id", "driver.is_available", "driver.languages",
+		"driver.name", "driver.photo_url", "driver.rating", "driver.review_count", "driver.vehicle",
+		"driver.vehicle.color", "driver.vehicle.model", "driver.vehicle.type", "dropoff_location", "id",
+		"pickup_location", "status", "tourist", "tourist.id", "tourist.language", "tourist.name",
+		"tourist.nationality", "updated_at", "version",
+	},
+}
+
+func fixtureUser() models.User {
+	return models.User{
+		ID:        7,
+		Email:     "ana@example.com",
+		Password:  "$2a$10$secret-hash",
+		Name:      "Ana",
+		Role:      "driver",
+		Language:  "es",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true},
+	}
+}
+
+func fixtureDriver() models.Driver {
+	return models.Driver{
+		Model:         gorm.Model{ID: 3, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		UserID:        7,
+		User:          fixtureUser(),
+		LicenseNumber: "B-12345",
+		VehicleType:   "sedan",
+		VehicleModel:  "Corolla",
+		VehicleColor:  "white",
+		Languages:     "es, en",
+		Experience:    5,
+		PhotoURL:      "https://example.com/ana.png",
+		Rating:        4.8,
+		ReviewCount:   12,
+		Status:        "active",
+		IsAvailable:   true,
+	}
+}
+
+func fixtureTourist() models.Tourist {
+	user := fixtureUser()
+	user.Role = "tourist"
+	return models.Tourist{
+		Model:         gorm.Model{ID: 4, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		UserID:        7,
+		User:          user,
+		Nationality:   "CL",
+		Language:      "es",
+		ArrivalDate:   time.Now(),
+		DepartureDate: time.Now().Add(72 * time.Hour),
+		Preferences:   "quiet",
+		SpecialNeeds:  "wheelchair",
+		Status:        "active",
+	}
+}
+
+func TestResponsesMatchContract(t *testing.T) {
+	user := fixtureUser()
+	identities := []models.UserIdentity{{ID: 2, UserID: user.ID, Provider: "google", Subject: "google-123", Email: user.Email, LinkedAt: time.Now()}}
+	driver := fixtureDriver()
+	tourist := fixtureTourist()
+	request := models.TouristRequest{
+		Model:           gorm.Model{ID: 9, CreatedAt: time.Now()},
+		TouristID:       tourist.ID,
+		Tourist:         tourist,
+		PickupLocation:  "Airport",
+		DropoffLocation: "Hotel",
+		DateTime:        "2025-06-01T10:00",
+		Notes:           "two bags",
+		Status:          "pending",
+	}
+	booking := models.Booking{
+		Model:           gorm.Model{ID: 11, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		TouristID:       tourist.ID,
+		Tourist:         tourist,
+		DriverID:        driver.ID,
+		Driver:          driver,
+		Status:          "pending",
+		BookedAt:        time.Now(),
+		PickupLocation:  "Airport",
+		DropoffLocation: "Hotel",
+		DateTime:        "2025-06-01T10:00",
+	}
+
+	responses := map[string]interface{}{
+		"UserResponse":           ToUserResponse(&user),
+		"AuthResponse":           AuthResponse{Token: "jwt", User: ToUserResponse(&user)},
+		"MeResponse":             ToMeResponse(&user, identities),
+		"DriverResponse":         ToDriverResponse(&driver),
+		"DriverPublicProfile":    ToPublicProfile(&driver),
+		"TouristResponse":        ToTouristResponse(&tourist),
+		"TouristRequestResponse": ToTouristRequestResponse(&request),
+		"BookingResponse":        ToBookingResponse(&booking),
+		"AuditEventResponse":     ToAuditEventResponses([]models.AuditEvent{{ID: 1, Event: models.AuditLoginFailed, UserID: &user.ID, Email: user.Email, IP: "10.0.0.1", CreatedAt: time.Now()}})[0],
+		"ProviderResponse":       ProviderResponse{Name: "corp", AuthURL: "/auth/oidc/corp"},
+		"IdentityResponse":       ToIdentityResponses(identities)[0],
+		"LinkStartedResponse":    LinkStartedResponse{AuthURL: "https://accounts.example.com/authorize"},
+		"MessageResponse":        MessageResponse{Message: "Done"},
+	}
+
+	for name, response := range responses {
+		expected, ok := responseContract[name]
+		if !ok {
+			t.Errorf("%s has no entry in the response contract", name)
+			continue
+		}
+
+		raw, err := json.Marshal(response)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", name, err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("unmarshal %s: %v", name, err)
+		}
+
+		got := jsonKeys("", decoded)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s keys changed\n got: %v\nwant: %v", name, got, expected)
+		}
+	}
+}
+
+func TestResponsesNeverLeakSecrets(t *testing.T) {
+	driver := fixtureDriver()
+	tourist := fixtureTourist()
+	booking := models.Booking{Driver: driver, Tourist: tourist}
+
+	for name, response := range map[string]interface{}{
+		"DriverPublicProfile": ToPublicProfile(&driver),
+		"BookingResponse":     ToBookingResponse(&booking),
+	} {
+		raw, _ := json.Marshal(response)
+		body := string(raw)
+		for _, secret := range []string{"secret-hash", "google-123", "ana@example.com", "B-12345", "wheelchair"} {
+			if strings.Contains(body, secret) {
+				t.Errorf("%s leaks %q: %s", name, secret, body)
+			}
+		}
+	}
+}
+
+func TestResponsesDoNotEmbedModels(t *testing.T) {
+	types := []interface{}{
+		UserResponse{}, AuthResponse{}, RoleUpdatedResponse{}, LanguageUpdatedResponse{}, MeResponse{},
+		DriverResponse{}, DriverPublicProfile{}, TouristResponse{}, TouristSummary{},
+		BookingResponse{}, TouristRequestResponse{}, TouristRequestCreatedResponse{},
+		AuditEventResponse{}, ProviderResponse{}, IdentityResponse{}, LinkStartedResponse{},
+	}
+	for _, value := range types {
+		checkNoModelFields(t, reflect.TypeOf(value), reflect.TypeOf(value).Name())
+	}
+}
+
+func checkNoModelFields(t *testing.T, typ reflect.Type, path string) {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if pkg := typ.PkgPath(); pkg == "fiber-backend/models" || strings.HasPrefix(pkg, "gorm.io/") {
+		t.Errorf("%s exposes %s.%s", path, pkg, typ.Name())
+		return
+	}
+	if typ.Kind() != reflect.Struct || typ.PkgPath() != "fiber-backend/dto" {
+		return
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		checkNoModelFields(t, field.Type, path+"."+field.Name)
+	}
+}
+
+func jsonKeys(prefix string, value map[string]interface{}) []string {
+	keys := []string{}
+	for key, nested := range value {
+		full := key
+		if prefix != "" {
+			full = prefix + "." + key
+		}
+		keys = append(keys, full)
+		if object, ok := nested.(map[string]interface{}); ok {
+			keys = append(keys, jsonKeys(full, object)...)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
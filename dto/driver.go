@@ -0,0 +1,151 @@
+package dto
+
+import (
+	"fiber-backend/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DriverCreateRequest is the body of POST /api/drivers
+type DriverCreateRequest struct {
+	LicenseNumber string `json:"license_number" validate:"required,max=50"`
+	VehicleType   string `json:"vehicle_type" validate:"required,max=50"`
+	VehicleModel  string `json:"vehicle_model" validate:"required,max=100"`
+	VehicleColor  string `json:"vehicle_color" validate:"required,max=50"`
+	Languages     string `json:"languages" validate:"required,max=255"`
+	Experience    int    `json:"experience" validate:"min=0,max=70"`
+	PhotoURL      string `json:"photo_url" validate:"url,max=500"`
+}
+
+// ToModel builds a driver profile for the given user from the request
+func (r *DriverCreateRequest) ToModel(userID uint) models.Driver {
+	return models.Driver{
+		UserID:        userID,
+		LicenseNumber: r.LicenseNumber,
+		VehicleType:   r.VehicleType,
+		VehicleModel:  r.VehicleModel,
+		VehicleColor:  r.VehicleColor,
+		Languages:     r.Languages,
+		Experience:    r.Experience,
+		PhotoURL:      r.PhotoURL,
+	}
+}
+
+// DriverProfileUpdate holds the fields a driver may change on their own profile.
+// Nil fields are left untouched.
+type DriverProfileUpdate struct {
+	LicenseNumber *string `json:"license_number" validate:"notblank,max=50"`
+	VehicleType   *string `json:"vehicle_type" validate:"notblank,max=50"`
+	VehicleModel  *string `json:"vehicle_model" validate:"notblank,max=100"`
+	VehicleColor  *string `json:"vehicle_color" validate:"notblank,max=50"`
+	Languages     *string `json:"languages" validate:"notblank,max=255"`
+	Experience    *int    `json:"experience" validate:"min=0,max=70"`
+	PhotoURL      *string `json:"photo_url" validate:"url,max=500"`
+}
+
+// DriverAvailabilityRequest is the body of PATCH /api/drivers/me/availability
+type DriverAvailabilityRequest struct {
+	IsAvailable *bool `json:"is_available" validate:"required"`
+}
+
+// DriverResponse is the driver's own view of their profile
+type DriverResponse struct {
+	ID            uint      `json:"id"`
+	LicenseNumber string    `json:"license_number"`
+	VehicleType   string    `json:"vehicle_type"`
+	VehicleModel  string    `json:"vehicle_model"`
+	VehicleColor  string    `json:"vehicle_color"`
+	Languages     []string  `json:"languages"`
+	Experience    int       `json:"experience"`
+	PhotoURL      string    `json:"photo_url"`
+	Rating        float32   `json:"rating"`
+	ReviewCount   int       `json:"review_count"`
+	Status        string    `json:"status"`
+	IsAvailable   bool      `json:"is_available"`
+	Version       uint      `json:"version"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DriverPublicProfile is the subset of a driver profile that is safe to show to other users
+type DriverPublicProfile struct {
+	ID          uint           `json:"id"`
+	Name        string         `json:"name"`
+	PhotoURL    string         `json:"photo_url"`
+	Languages   []string       `json:"languages"`
+	Rating      float32        `json:"rating"`
+	ReviewCount int            `json:"review_count"`
+	Vehicle     VehicleSummary `json:"vehicle"`
+	IsAvailable bool           `json:"is_available"`
+}
+
+// VehicleSummary describes a driver's vehicle
+type VehicleSummary struct {
+	Type  string `json:"type"`
+	Model string `json:"model"`
+	Color string `json:"color"`
+}
+
+// ToDriverResponse maps a driver to the view its owner gets
+func ToDriverResponse(driver *models.Driver) DriverResponse {
+	return DriverResponse{
+		ID:            driver.ID,
+		LicenseNumber: driver.LicenseNumber,
+		VehicleType:   driver.VehicleType,
+		VehicleModel:  driver.VehicleModel,
+		VehicleColor:  driver.VehicleColor,
+		Languages:     SplitLanguages(driver.Languages),
+		Experience:    driver.Experience,
+		PhotoURL:      driver.PhotoURL,
+		Rating:        driver.Rating,
+		ReviewCount:   driver.ReviewCount,
+		Status:        driver.Status,
+		IsAvailable:   driver.IsAvailable,
+		Version:       driver.Version,
+		CreatedAt:     driver.CreatedAt,
+		UpdatedAt:     driver.UpdatedAt,
+	}
+}
+
+// ToPublicProfile builds the public view of a driver. The driver's User must be preloaded.
+func ToPublicProfile(driver *models.Driver) DriverPublicProfile {
+	profile := DriverPublicProfile{
+		ID:          driver.ID,
+		Name:        driver.User.Name,
+		PhotoURL:    driver.PhotoURL,
+		Languages:   SplitLanguages(driver.Languages),
+		Rating:      driver.Rating,
+		ReviewCount: driver.ReviewCount,
+		Vehicle: VehicleSummary{
+			Type:  driver.VehicleType,
+			Model: driver.VehicleModel,
+			Color: driver.VehicleColor,
+		},
+		IsAvailable: driver.IsAvailable,
+	}
+	if profile.Name == "" {
+		profile.Name = "Driver " + fmt.Sprint(driver.ID) // Default name if empty
+	}
+	return profile
+}
+
+// ToPublicProfiles maps a list of drivers to their public profiles
+func ToPublicProfiles(drivers []models.Driver) []DriverPublicProfile {
+	profiles := make([]DriverPublicProfile, 0, len(drivers))
+	for i := range drivers {
+		profiles = append(profiles, ToPublicProfile(&drivers[i]))
+	}
+	return profiles
+}
+
+// SplitLanguages turns the comma-separated languages column into a clean list
+func SplitLanguages(languages string) []string {
+	result := []string{}
+	for _, language := range strings.Split(languages, ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			result = append(result, language)
+		}
+	}
+	return result
+}
@@ -0,0 +1,203 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fiber-backend/i18n"
+	"fiber-backend/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TouristProfileRequest is the body of POST /api/tourists
+type TouristProfileRequest struct {
+	Nationality   string    `json:"nationality" validate:"required,max=100"`
+	Language      string    `json:"language" validate:"required,max=100"`
+	ArrivalDate   time.Time `json:"arrival_date" validate:"required"`
+	DepartureDate time.Time `json:"departure_date" validate:"required,after=arrival_date"`
+	Preferences   string    `json:"preferences" validate:"max=500"`
+	SpecialNeeds  string    `json:"special_needs" validate:"max=500"`
+}
+
+// ToModel builds a tourist profile for the given user from the request
+func (r *TouristProfileRequest) ToModel(userID uint) models.Tourist {
+	return models.Tourist{
+		UserID:        userID,
+		Nationality:   r.Nationality,
+		Language:      r.Language,
+		ArrivalDate:   r.ArrivalDate,
+		DepartureDate: r.DepartureDate,
+		Preferences:   r.Preferences,
+		SpecialNeeds:  r.SpecialNeeds,
+	}
+}
+
+// TouristResponse is the tourist's own view of their profile
+type TouristResponse struct {
+	ID            uint      `json:"id"`
+	Nationality   string    `json:"nationality"`
+	Language      string    `json:"language"`
+	ArrivalDate   time.Time `json:"arrival_date"`
+	DepartureDate time.Time `json:"departure_date"`
+	Preferences   string    `json:"preferences"`
+	SpecialNeeds  string    `json:"special_needs"`
+	Status        string    `json:"status"`
+	Version       uint      `json:"version"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TouristSummary is what a driver sees of the tourist on a booking
+type TouristSummary struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Nationality string `json:"nationality"`
+	Language    string `json:"language"`
+}
+
+// ToTouristResponse maps a tourist to the view its owner gets
+func ToTouristResponse(tourist *models.Tourist) TouristResponse {
+	return TouristResponse{
+		ID:            tourist.ID,
+		Nationality:   tourist.Nationality,
+		Language:      tourist.Language,
+		ArrivalDate:   tourist.ArrivalDate,
+		DepartureDate: tourist.DepartureDate,
+		Preferences:   tourist.Preferences,
+		SpecialNeeds:  tourist.SpecialNeeds,
+		Status:        tourist.Status,
+		Version:       tourist.Version,
+		CreatedAt:     tourist.CreatedAt,
+		UpdatedAt:     tourist.UpdatedAt,
+	}
+}
+
+// ToTouristSummary maps a tourist to the summary shown to drivers. The tourist's User must be preloaded.
+func ToTouristSummary(tourist *models.Tourist) TouristSummary {
+	return TouristSummary{
+		ID:          tourist.ID,
+		Name:        tourist.User.Name,
+		Nationality: tourist.Nationality,
+		Language:    tourist.Language,
+	}
+}
+
+// TouristProfilePatch is a JSON Merge Patch (RFC 7386) for PATCH /api/tourists/me.
+// Only the members present in the document are applied; null clears optional fields.
+type TouristProfilePatch struct {
+	fields map[string]json.RawMessage
+}
+
+// patchableTouristFields lists the members a tourist may change on their profile
+var patchableTouristFields = map[string]bool{
+	"nationality":    true,
+	"language":       true,
+	"arrival_date":   true,
+	"departure_date": true,
+	"preferences":    true,
+	"special_needs":  true,
+}
+
+// touristFieldLimits are the longest texts accepted, as in TouristProfileRequest
+var touristFieldLimits = map[string]int{
+	"nationality":   100,
+	"language":      100,
+	"preferences":   500,
+	"special_needs": 500,
+}
+
+// ParseTouristProfilePatch decodes a merge patch document
+func ParseTouristProfilePatch(body []byte) (*TouristProfilePatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+	return &TouristProfilePatch{fields: fields}, nil
+}
+
+// Apply validates the patch and merges it into the tourist. Nothing is changed when
+// validation fails; the returned map holds the message keys of the problems
+// keyed by field name.
+func (p *TouristProfilePatch) Apply(tourist *models.Tourist) map[string]string {
+	fieldErrors := map[string]string{}
+	patched := *tourist
+
+	for field, raw := range p.fields {
+		if !patchableTouristFields[field] {
+			fieldErrors[field] = "validation.not_modifiable"
+			continue
+		}
+		isNull := string(raw) == "null"
+
+		switch field {
+		case "nationality", "language", "preferences", "special_needs":
+			var value string
+			if !isNull {
+				if err := json.Unmarshal(raw, &value); err != nil {
+					fieldErrors[field] = "validation.must_be_text"
+					continue
+				}
+			}
+			value = strings.TrimSpace(value)
+			if (field == "nationality" || field == "language") && value == "" {
+				fieldErrors[field] = "validation.required"
+				continue
+			}
+			if limit := touristFieldLimits[field]; utf8.RuneCountInString(value) > limit {
+				fieldErrors[field] = i18n.Key("validation.max_length", limit)
+				continue
+			}
+			switch field {
+			case "nationality":
+				patched.Nationality = value
+			case "language":
+				patched.Language = value
+			case "preferences":
+				patched.Preferences = value
+			case "special_needs":
+				patched.SpecialNeeds = value
+			}
+		case "arrival_date", "departure_date":
+			date, problem := parsePatchDate(raw)
+			if problem != "" {
+				fieldErrors[field] = problem
+				continue
+			}
+			if field == "arrival_date" {
+				patched.ArrivalDate = date
+			} else {
+				patched.DepartureDate = date
+			}
+		}
+	}
+
+	if len(fieldErrors) == 0 && !patched.DepartureDate.After(patched.ArrivalDate) {
+		fieldErrors["departure_date"] = i18n.Key("validation.after", "arrival_date")
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+
+	*tourist = patched
+	return nil
+}
+
+// parsePatchDate accepts either YYYY-MM-DD or an RFC 3339 timestamp. It returns
+// the message key of the problem when the value is neither.
+func parsePatchDate(raw json.RawMessage) (time.Time, string) {
+	var value string
+	if string(raw) == "null" || json.Unmarshal(raw, &value) != nil || value == "" {
+		return time.Time{}, "validation.date_required"
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, ""
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, ""
+	}
+	return time.Time{}, "validation.date_format"
+}
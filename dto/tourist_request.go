@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"fiber-backend/models"
+	"time"
+)
+
+// TouristRequestCreateRequest is the body of POST /api/tourists/request
+type TouristRequestCreateRequest struct {
+	PickupLocation  string `json:"pickup_location" validate:"required,max=255"`
+	DropoffLocation string `json:"dropoff_location" validate:"required,max=255"`
+	DateTime        string `json:"date_time" validate:"required,datetime"`
+	Notes           string `json:"notes" validate:"max=500"`
+}
+
+// ToModel builds a pending driver request for the given tourist
+func (r *TouristRequestCreateRequest) ToModel(touristID uint) models.TouristRequest {
+	return models.TouristRequest{
+		TouristID:       touristID,
+		PickupLocation:  r.PickupLocation,
+		DropoffLocation: r.DropoffLocation,
+		DateTime:        r.DateTime,
+		Notes:           r.Notes,
+		Status:          "pending",
+	}
+}
+
+// TouristRequestResponse is a tourist's request for a driver
+type TouristRequestResponse struct {
+	ID              uint      `json:"id"`
+	PickupLocation  string    `json:"pickup_location"`
+	DropoffLocation string    `json:"dropoff_location"`
+	DateTime        string    `json:"date_time"`
+	Notes           string    `json:"notes"`
+	Status          string    `json:"status"`
+	Version         uint      `json:"version"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TouristRequestCreatedResponse is returned by POST /api/tourists/request
+type TouristRequestCreatedResponse struct {
+	Message string                 `json:"message"`
+	Request TouristRequestResponse `json:"request"`
+}
+
+// ToTouristRequestResponse maps a driver request to its response
+func ToTouristRequestResponse(request *models.TouristRequest) TouristRequestResponse {
+	return TouristRequestResponse{
+		ID:              request.ID,
+		PickupLocation:  request.PickupLocation,
+		DropoffLocation: request.DropoffLocation,
+		DateTime:        request.DateTime,
+		Notes:           request.Notes,
+		Status:          request.Status,
+		Version:         request.Version,
+		CreatedAt:       request.CreatedAt,
+	}
+}
@@ -0,0 +1,216 @@
+package dto
+
+import (
+	"fiber-backend/models"
+	"fiber-backend/validate"
+	"strings"
+	"time"
+)
+
+// RegisterRequest is the body of POST /auth/register
+type RegisterRequest struct {
+	Email    string                 `json:"email" validate:"required,email,max=255"`
+	Password string                 `json:"password" validate:"required,password"`
+	Name     string                 `json:"name" validate:"required,max=100"`
+	Tourist  *RegisterTouristFields `json:"tourist"`
+	Role     string                 `json:"role" validate:"oneof=tourist driver"`
+}
+
+// Check requires the tourist profile of tourist accounts
+func (r *RegisterRequest) Check(problems validate.Problems) {
+	if r.Role == "tourist" && r.Tourist == nil {
+		problems.Add("tourist", "validation.required")
+	}
+}
+
+// RegisterTouristFields holds the tourist profile created alongside a tourist account
+type RegisterTouristFields struct {
+	Nationality   string `json:"nationality" validate:"required,max=100"`
+	Language      string `json:"language" validate:"required,max=100"`
+	ArrivalDate   string `json:"arrival_date" validate:"required,date"`
+	DepartureDate string `json:"departure_date" validate:"required,date,after=arrival_date"`
+	Preferences   string `json:"preferences" validate:"max=500"`
+	SpecialNeeds  string `json:"special_needs" validate:"max=500"`
+}
+
+// ToModel builds the pending tourist profile. The dates must have been validated.
+func (f *RegisterTouristFields) ToModel() models.Tourist {
+	arrivalDate, _ := time.Parse("2006-01-02", f.ArrivalDate)
+	departureDate, _ := time.Parse("2006-01-02", f.DepartureDate)
+	return models.Tourist{
+		Nationality:   strings.TrimSpace(f.Nationality),
+		Language:      strings.TrimSpace(f.Language),
+		ArrivalDate:   arrivalDate,
+		DepartureDate: departureDate,
+		Preferences:   f.Preferences,
+		SpecialNeeds:  f.SpecialNeeds,
+		Status:        "pending",
+	}
+}
+
+// LoginRequest is the body of POST /auth/login
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,max=255"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateRoleRequest is the body of POST /auth/update-role
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// ExchangeCodeRequest is the body of POST /auth/exchange
+type ExchangeCodeRequest struct {
+	Code string `json:"code" validate:"required,max=100"`
+}
+
+// UpdateLanguageRequest is the body of POST /auth/update-language
+type UpdateLanguageRequest struct {
+	Language string `json:"language" validate:"required,language"`
+}
+
+// SetPasswordRequest is the body of POST /auth/password
+type SetPasswordRequest struct {
+	Password string `json:"password" validate:"required,password"`
+}
+
+// VerifyEmailRequest is the body of POST /auth/verify-email, with the token
+// from the emailed link
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=100"`
+}
+
+// ForgotPasswordRequest is the body of POST /auth/password/forgot
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,max=255"`
+}
+
+// ResetPasswordRequest is the body of POST /auth/password/reset, with the token
+// from the emailed link
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// ChangePasswordRequest is the body of POST /auth/password/change
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+// MessageResponse is a bare confirmation in the language of the request
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// UserResponse is the account summary returned by the auth endpoints
+type UserResponse struct {
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+}
+
+// AuthResponse is returned after a successful registration or login
+type AuthResponse struct {
+	Token string       `json:"token"`
+	User  UserResponse `json:"user"`
+}
+
+// RoleUpdatedResponse is returned by POST /auth/update-role
+type RoleUpdatedResponse struct {
+	Message string       `json:"message"`
+	User    UserResponse `json:"user"`
+}
+
+// LanguageUpdatedResponse is returned by POST /auth/update-language. The token
+// replaces the old one, which still carries the previous language.
+type LanguageUpdatedResponse struct {
+	Message string     `json:"message"`
+	Token   string     `json:"token"`
+	User    MeResponse `json:"user"`
+}
+
+// MeResponse is the authenticated user's own account, returned by GET /auth/me.
+// GoogleID is kept for older clients; GET /auth/identities lists every linked
+// provider account.
+type MeResponse struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Email       string  `json:"email"`
+	GoogleID    *string `json:"google_id"`
+	HasPassword bool    `json:"has_password"`
+	// EmailVerified is false until the user opens the link emailed to them
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	Language      string    `json:"language"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// IdentityResponse is a provider account linked to the user, listed by GET
+// /auth/identities
+type IdentityResponse struct {
+	ID       uint      `json:"id"`
+	Provider string    `json:"provider"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// ToUserResponse maps a user to its public account summary
+func ToUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:    user.ID,
+		Email: user.Email,
+		Name:  user.Name,
+		Role:  user.Role,
+	}
+}
+
+// ToMeResponse maps a user and their linked provider accounts to the view they
+// get of their own account
+func ToMeResponse(user *models.User, identities []models.UserIdentity) MeResponse {
+	response := MeResponse{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		HasPassword:   user.HasPassword(),
+		EmailVerified: user.EmailVerified(),
+		Role:          user.Role,
+		Language:      user.Language,
+		CreatedAt:     user.CreatedAt,
+	}
+	for i := range identities {
+		if identities[i].Provider == "google" {
+			response.GoogleID = &identities[i].Subject
+			break
+		}
+	}
+	return response
+}
+
+// LinkStartedResponse is returned by POST /auth/identities/:provider. The
+// browser goes to AuthURL to sign in at the provider, which links the account.
+type LinkStartedResponse struct {
+	AuthURL string `json:"auth_url"`
+}
+
+// ToIdentityResponses maps linked provider accounts to their responses
+func ToIdentityResponses(identities []models.UserIdentity) []IdentityResponse {
+	responses := make([]IdentityResponse, len(identities))
+	for i, identity := range identities {
+		responses[i] = IdentityResponse{
+			ID:       identity.ID,
+			Provider: identity.Provider,
+			Email:    identity.Email,
+			LinkedAt: identity.LinkedAt,
+		}
+	}
+	return responses
+}
+
+// ProviderResponse is a sign-in provider in GET /auth/providers. AuthURL is
+// the API path that starts a sign-in with it.
+type ProviderResponse struct {
+	Name    string `json:"name"`
+	AuthURL string `json:"auth_url"`
+}
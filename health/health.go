@@ -0,0 +1,107 @@
+// Package health answers the orchestrator's liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// checkTimeout bounds each readiness check so a hung dependency fails the probe
+const checkTimeout = 2 * time.Second
+
+// Check reports whether one dependency is usable
+type Check func(ctx context.Context) error
+
+// Checker holds the readiness checks and whether the server is shutting down
+type Checker struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{checks: map[string]Check{}}
+}
+
+// Add registers a readiness check under name
+func (h *Checker) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop sending new
+// requests while in-flight ones drain
+func (h *Checker) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Liveness answers /healthz: the process is up and serving requests
+func (h *Checker) Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readiness answers /readyz by running every check in parallel. It returns 503
+// with the failing checks while any of them fails or the server is stopping.
+func (h *Checker) Readiness(c *fiber.Ctx) error {
+	if h.shuttingDown.Load() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "shutting_down",
+		})
+	}
+
+	results := h.run(c.UserContext())
+	status, code := "ready", fiber.StatusOK
+	for _, result := range results {
+		if result != "ok" {
+			status, code = "unavailable", fiber.StatusServiceUnavailable
+		}
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"checks": results,
+	})
+}
+
+func (h *Checker) run(ctx context.Context) map[string]string {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	results := make(map[string]string, len(names))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := range names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(names[i], checks[i])
+	}
+	wg.Wait()
+	return results
+}
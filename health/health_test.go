@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func probe(t *testing.T, checker *Checker) (int, readiness) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/readyz", checker.Readiness)
+
+	res, err := app.Test(httptest.NewRequest("GET", "/readyz", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body readiness
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, body
+}
+
+func TestReadinessReportsFailingChecks(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(context.Context) error { return nil })
+
+	if status, body := probe(t, checker); status != fiber.StatusOK || body.Status != "ready" {
+		t.Fatalf("healthy checks: got %d %+v", status, body)
+	}
+
+	checker.Add("migrations", func(context.Context) error { return errors.New("2 pending migrations") })
+	status, body := probe(t, checker)
+	if status != fiber.StatusServiceUnavailable || body.Checks["migrations"] != "2 pending migrations" || body.Checks["database"] != "ok" {
+		t.Fatalf("failing check: got %d %+v", status, body)
+	}
+}
+
+func TestReadinessTimesOutHungChecks(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	if status, _ := probe(t, checker); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("hung check should fail readiness, got %d", status)
+	}
+	if time.Since(start) > checkTimeout+time.Second {
+		t.Fatal("readiness waited past the check timeout")
+	}
+}
+
+func TestReadinessFailsWhileShuttingDown(t *testing.T) {
+	checker := NewChecker()
+	checker.SetShuttingDown()
+
+	if status, body := probe(t, checker); status != fiber.StatusServiceUnavailable || body.Status != "shutting_down" {
+		t.Fatalf("got %d %+v", status, body)
+	}
+}
+
+type testWorker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func (w testWorker) Name() string                  { return w.name }
+func (w testWorker) Run(ctx context.Context) error { return w.run(ctx) }
+
+func TestWorkersReportCrashesAndStop(t *testing.T) {
+	crashed := make(chan struct{})
+	workers := NewWorkers()
+	workers.Start(
+		testWorker{"steady", func(ctx context.Context) error { <-ctx.Done(); return nil }},
+		testWorker{"flaky", func(context.Context) error { defer close(crashed); return errors.New("boom") }},
+	)
+
+	<-crashed
+	// The crash is recorded right after Run returns
+	deadline := time.Now().Add(time.Second)
+	for workers.Check(context.Background()) == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := workers.Check(context.Background()); err == nil {
+		t.Fatal("a crashed worker should fail the check")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := workers.Stop(ctx); err != nil {
+		t.Fatalf("workers did not stop: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"fiber-backend/utils"
+)
+
+// Worker is a background job that runs until its context is cancelled
+type Worker interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Workers starts background workers, reports their state to the readiness
+// probe and stops them on shutdown
+type Workers struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	stopped map[string]error // workers that returned before shutdown
+	names   []string
+}
+
+func NewWorkers() *Workers {
+	return &Workers{stopped: map[string]error{}}
+}
+
+// Start runs each worker in its own goroutine. It is called once, at startup.
+func (w *Workers) Start(workers ...Worker) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(context.Background())
+	for _, worker := range workers {
+		w.names = append(w.names, worker.Name())
+		w.wg.Add(1)
+		go w.run(ctx, worker)
+	}
+}
+
+func (w *Workers) run(ctx context.Context, worker Worker) {
+	defer w.wg.Done()
+	utils.LogInfo("Worker %s started", worker.Name())
+
+	err := worker.Run(ctx)
+	if ctx.Err() != nil {
+		utils.LogInfo("Worker %s stopped", worker.Name())
+		return
+	}
+
+	if err == nil {
+		err = fmt.Errorf("exited unexpectedly")
+	}
+	utils.LogError("Worker %s failed: %v", worker.Name(), err)
+	w.mu.Lock()
+	w.stopped[worker.Name()] = err
+	w.mu.Unlock()
+}
+
+// Check fails if any worker has stopped before shutdown
+func (w *Workers) Check(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, name := range w.names {
+		if err, ok := w.stopped[name]; ok {
+			return fmt.Errorf("worker %s is not running: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Stop cancels every worker and waits for them to return or for ctx to expire
+func (w *Workers) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workers did not stop in time: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,145 @@
+// Package i18n translates the messages the API sends to clients. Catalogs are
+// flat JSON files under locales/, one per language, keyed by message key
+// (errors.tourist_not_found, validation.not_empty, messages.role_updated, ...).
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is used when nothing the client accepts is supported, and for any
+// key missing from another catalog
+const Default = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs maps a language to its messages
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	loaded := map[string]map[string]string{}
+	for _, file := range files {
+		raw, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog %s: %v", file.Name(), err))
+		}
+		loaded[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	if _, ok := loaded[Default]; !ok {
+		panic("i18n: the default catalog is missing")
+	}
+	return loaded
+}
+
+// Languages returns the supported language codes, sorted
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Supported returns the supported language matching tag ("pt-BR" matches "pt"), or ""
+func Supported(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if _, ok := catalogs[base]; ok {
+		return base
+	}
+	return ""
+}
+
+// Key attaches an argument to a message key; it replaces {0} in the message,
+// e.g. Key("validation.max_length", 100) for "must be at most {0} characters"
+func Key(key string, arg interface{}) string {
+	return key + ":" + fmt.Sprint(arg)
+}
+
+// T returns the message for key, optionally built with Key, in language. It
+// falls back to the default language and then to the key itself.
+func T(language, key string) string {
+	name, arg, hasArg := strings.Cut(key, ":")
+	message, ok := catalogs[language][name]
+	if !ok {
+		if message, ok = catalogs[Default][name]; !ok {
+			return key
+		}
+	}
+	if hasArg {
+		message = strings.ReplaceAll(message, "{0}", arg)
+	}
+	return message
+}
+
+// Negotiate picks the supported language the client prefers most from an
+// Accept-Language header, or "" when it accepts none of them
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		tag     string
+		quality float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > 0 {
+			choices = append(choices, choice{tag, quality})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].quality > choices[j].quality })
+
+	for _, c := range choices {
+		if c.tag == "*" {
+			return Default
+		}
+		if language := Supported(c.tag); language != "" {
+			return language
+		}
+	}
+	return ""
+}
+
+type languageKey struct{}
+
+// WithLanguage returns a context carrying the language responses should use
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// FromContext returns the language stored in ctx, or Default
+func FromContext(ctx context.Context) string {
+	if ctx != nil {
+		if language, ok := ctx.Value(languageKey{}).(string); ok && language != "" {
+			return language
+		}
+	}
+	return Default
+}
@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEveryCatalogHasEveryKey(t *testing.T) {
+	for _, language := range []string{"en", "es", "pt", "fr"} {
+		if _, ok := catalogs[language]; !ok {
+			t.Errorf("no catalog for %s", language)
+		}
+	}
+
+	for language, messages := range catalogs {
+		for key := range catalogs[Default] {
+			if strings.TrimSpace(messages[key]) == "" {
+				t.Errorf("%s: %s is missing", language, key)
+			}
+		}
+		for key := range messages {
+			if _, ok := catalogs[Default][key]; !ok {
+				t.Errorf("%s: %s is not in the %s catalog", language, key, Default)
+			}
+		}
+	}
+}
+
+// messageKey matches the message keys written as string literals in the code
+var messageKey = regexp.MustCompile(`"((?:errors|validation|messages|emails)\.[a-z_]+)"`)
+
+func TestEveryKeyUsedInTheCodeIsTranslated(t *testing.T) {
+	root := ".."
+	found := 0
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name := entry.Name(); name != ".." && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, match := range messageKey.FindAllStringSubmatch(string(source), -1) {
+			found++
+			if _, ok := catalogs[Default][match[1]]; !ok {
+				t.Errorf("%s uses %s, which has no message", path, match[1])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == 0 {
+		t.Fatal("no message keys found in the code; is the pattern still right?")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                             "",
+		"es":                           "es",
+		"pt-BR,pt;q=0.9,en;q=0.8":      "pt",
+		"de-DE,fr;q=0.7,en;q=0.5":      "fr",
+		"en;q=0.3, es-AR;q=0.9":        "es",
+		"de, ja":                       "",
+		"de, *;q=0.1":                  Default,
+		"fr;q=0, es;q=0.2":             "es",
+		"fr;q=bogus, es;q=0.2":         "es",
+		"  FR-ca ;q=1.0 , es ; q=0.5 ": "fr",
+	}
+	for header, want := range cases {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestTranslateFallsBack(t *testing.T) {
+	if got := T("es", "errors.driver_not_found"); got != "Chofer no encontrado" {
+		t.Errorf("es translation = %q", got)
+	}
+	if got := T("de", "errors.driver_not_found"); got != T(Default, "errors.driver_not_found") {
+		t.Errorf("unsupported languages should get the default catalog, got %q", got)
+	}
+	if got := T("es", "errors.no_such_key"); got != "errors.no_such_key" {
+		t.Errorf("unknown keys should come back as is, got %q", got)
+	}
+}
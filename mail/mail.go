@@ -0,0 +1,43 @@
+// Package mail sends the emails the API sends users, such as the links that
+// verify their address
+package mail
+
+import (
+	"context"
+	"fiber-backend/config"
+	"fiber-backend/utils"
+	"fmt"
+)
+
+// Message is a plain-text email to one recipient
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Sender delivers messages. Send returns once the message is handed off, or
+// fails when ctx ends first.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New returns the sender cfg selects, which is expected to have been validated
+func New(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Driver {
+	case "log":
+		return LogSender{}, nil
+	case "smtp":
+		return NewSMTPSender(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// LogSender logs who each message is for instead of sending it. The text is
+// left out since it carries single-use tokens.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, message Message) error {
+	utils.LogInfoContext(ctx, "Not sending email %q to %s: MAIL_DRIVER is log", message.Subject, message.To)
+	return nil
+}
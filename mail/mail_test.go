@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"context"
+	"fiber-backend/config"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts one message and returns the envelope and data it got
+func fakeSMTPServer(t *testing.T) (config.MailConfig, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		var got []string
+		text.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command, _, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO":
+				text.PrintfLine("250-fake\r\n250 8BITMIME")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, _ := text.ReadDotLines()
+				got = append(got, strings.Join(data, "\n"))
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				received <- got
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return config.MailConfig{Driver: "smtp", From: "noreply@example.com", SMTPHost: host, SMTPPort: portNumber}, received
+}
+
+func TestSMTPSenderDeliversTheMessage(t *testing.T) {
+	cfg, received := fakeSMTPServer(t)
+	sender, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = sender.Send(ctx, Message{To: "ana@example.com", Subject: "Vérifiez votre e-mail", Text: "Abre este enlace:\nhttps://app.example.com/verify-email?token=abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-received
+	if len(got) != 3 || got[0] != "MAIL FROM:<noreply@example.com> BODY=8BITMIME" || got[1] != "RCPT TO:<ana@example.com>" {
+		t.Fatalf("unexpected envelope %q", got)
+	}
+	for _, want := range []string{
+		"From: noreply@example.com",
+		"To: ana@example.com",
+		"Subject: =?utf-8?q?V=C3=A9rifiez_votre_e-mail?=",
+		"Content-Type: text/plain; charset=utf-8",
+		"\n\nAbre este enlace:\nhttps://app.example.com/verify-email?token=abc",
+	} {
+		if !strings.Contains(got[2], want) {
+			t.Errorf("message is missing %q:\n%s", want, got[2])
+		}
+	}
+}
+
+func TestSMTPSenderGivesUpWithTheContext(t *testing.T) {
+	// A server that accepts the connection but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sender := NewSMTPSender(config.MailConfig{From: "noreply@example.com", SMTPHost: host, SMTPPort: portNumber})
+	if err := sender.Send(ctx, Message{To: "ana@example.com"}); err == nil {
+		t.Fatal("expected the send to time out")
+	}
+}
+
+func TestNewRejectsUnknownDrivers(t *testing.T) {
+	if _, err := New(config.MailConfig{Driver: "carrier-pigeon"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if sender, err := New(config.MailConfig{Driver: "log"}); err != nil || sender.Send(context.Background(), Message{To: "ana@example.com"}) != nil {
+		t.Fatalf("the log sender should accept anything, got %v", err)
+	}
+}
+
+// senderFunc adapts a function to Sender
+type senderFunc func(ctx context.Context, message Message) error
+
+func (f senderFunc) Send(ctx context.Context, message Message) error { return f(ctx, message) }
+
+func TestQueueDeliversAfterTheRequestEnds(t *testing.T) {
+	delivered := make(chan error, 1)
+	queue := NewQueue(senderFunc(func(ctx context.Context, message Message) error {
+		delivered <- ctx.Err()
+		return nil
+	}), 1)
+
+	requestCtx, cancel := context.WithCancel(context.Background())
+	if err := queue.Send(requestCtx, Message{To: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Send(requestCtx, Message{To: "bob@example.com"}); err != ErrQueueFull {
+		t.Fatalf("a full queue should refuse messages, got %v", err)
+	}
+	cancel()
+
+	workerCtx, stop := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- queue.Run(workerCtx) }()
+	select {
+	case err := <-delivered:
+		if err != nil {
+			t.Fatalf("the message was sent with a finished context: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the queued message was not delivered")
+	}
+	stop()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Run should return once stopped, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/utils"
+	"time"
+)
+
+// ErrQueueFull is returned by Queue.Send when messages arrive faster than they
+// are delivered
+var ErrQueueFull = errors.New("mail queue is full")
+
+// queueSendTimeout bounds the delivery of one queued message
+const queueSendTimeout = 30 * time.Second
+
+type queued struct {
+	ctx     context.Context
+	message Message
+}
+
+// Queue is a Sender that hands messages to another one in the background, so
+// requests neither wait for the mail server nor show by how long they take
+// whether they sent anything. It runs as a health.Worker.
+type Queue struct {
+	sender   Sender
+	messages chan queued
+}
+
+// NewQueue returns a queue that holds up to size messages for sender
+func NewQueue(sender Sender, size int) *Queue {
+	return &Queue{sender: sender, messages: make(chan queued, size)}
+}
+
+func (q *Queue) Name() string { return "mail" }
+
+// Send queues the message without waiting for it to be delivered. It keeps the
+// values of ctx, such as the request ID for the logs, but not its deadline.
+func (q *Queue) Send(ctx context.Context, message Message) error {
+	select {
+	case q.messages <- queued{ctx: context.WithoutCancel(ctx), message: message}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers queued messages until ctx is cancelled. Failed deliveries are
+// logged and dropped; the user can ask for another email.
+func (q *Queue) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case next := <-q.messages:
+			sendCtx, cancel := context.WithTimeout(next.ctx, queueSendTimeout)
+			if err := q.sender.Send(sendCtx, next.message); err != nil {
+				utils.LogErrorContext(sendCtx, "Failed to send email %q to %s: %v", next.message.Subject, next.message.To, err)
+			}
+			cancel()
+		}
+	}
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
 	"fiber-backend/config"
 	"fiber-backend/database"
-	"fiber-backend/routes"
+	"fiber-backend/health"
+	"fiber-backend/mail"
+	"fiber-backend/repository"
+	"fiber-backend/server"
 	"fiber-backend/services"
+	"fiber-backend/tracing"
 	"fiber-backend/utils"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
 )
 
@@ -25,58 +31,107 @@ func main() {
 		}
 	}
 
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// `migrate up|down|status` manages the schema instead of starting the server
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	logFile, err := utils.SetupLogging(utils.LogOptions{
+		Level:      cfg.Log.Level,
+		Format:     cfg.Log.Format,
+		Dir:        cfg.Log.Dir,
+		MaxSizeMB:  cfg.Log.MaxSizeMB,
+		MaxAgeDays: cfg.Log.MaxAgeDays,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logFile.Close()
+
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Connect to database
 	utils.LogInfo("Connecting to database")
-	database.Connect()
+	db := database.Connect(cfg.Database)
 
-	// Initialize OAuth configuration
-	utils.LogInfo("Initializing OAuth configuration")
-	config.InitOAuth()
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Create a new Fiber instance with custom config
-	app := fiber.New(fiber.Config{
-		AppName:      "Fiber Auth API",
-		ErrorHandler: utils.ErrorHandler,
+	// Emails are delivered in the background so requests don't wait on SMTP
+	sender, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mailQueue := mail.NewQueue(sender, 1000)
+
+	// Background jobs register here; readiness fails if one dies
+	workers := health.NewWorkers()
+	workers.Start(mailQueue)
+
+	checker := health.NewChecker()
+	checker.Add("database", database.PingCheck(db))
+	checker.Add("migrations", database.MigrationsCheck(db))
+	checker.Add("workers", workers.Check)
+
+	app, err := server.New(server.Options{
+		Config: cfg,
+		Store:  repository.NewGormStore(db),
+		Google: services.GoogleProductionEndpoints,
+		DB:     sqlDB,
+		Health: checker,
+		Mailer: mailQueue,
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Middleware
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	app.Use(logger.New())
+	listenErr := make(chan error, 1)
+	go func() {
+		utils.LogInfo("Server starting on port %d", cfg.Port)
+		listenErr <- app.Listen(fmt.Sprintf(":%d", cfg.Port))
+	}()
 
-	corsConfig := cors.Config{
-		AllowOrigins:     "http://localhost:5173,https://tourist-golang.netlify.app",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With,X-CSRF-Token",
-		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
-		AllowCredentials: true,
-		ExposeHeaders:    "Content-Length, Authorization",
-		MaxAge:           86400, // 24 hours cache for preflight requests
+	select {
+	case err := <-listenErr:
+		utils.LogError("Server stopped: %v", err)
+	case <-ctx.Done():
+		utils.LogInfo("Shutdown signal received, draining connections")
 	}
 
-	utils.LogInfo("CORS Configuration:")
-	utils.LogInfo("- Allowed Origins: %s", corsConfig.AllowOrigins)
-	utils.LogInfo("- Allowed Headers: %s", corsConfig.AllowHeaders)
-	utils.LogInfo("- Allowed Methods: %s", corsConfig.AllowMethods)
-	utils.LogInfo("- Allow Credentials: %v", corsConfig.AllowCredentials)
-
-	app.Use(cors.New(corsConfig))
-
-	// Initialize services
-	authService := services.NewAuthService(database.DB)
-	driverService := services.NewDriverService(database.DB)
-
-	// Setup routes
-	utils.LogInfo("Setting up routes")
-	routes.SetupAuthRoutes(app, authService)
-	routes.SetupTouristRoutes(app)
-	routes.SetupDriverRoutes(app, driverService)
-	routes.SetupBookingRoutes(app, database.DB)
-
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001"
+	// Fail readiness first so no new traffic is routed here, then let
+	// in-flight requests finish before the workers and the pool go away
+	checker.SetShuttingDown()
+	timeout := time.Duration(cfg.ShutdownTimeout)
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
+		utils.LogError("HTTP shutdown did not complete: %v", err)
 	}
 
-	utils.LogInfo("Server starting on port %s", port)
-	log.Fatal(app.Listen(":" + port))
+	stopCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := workers.Stop(stopCtx); err != nil {
+		utils.LogError("%v", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		utils.LogError("Closing the database pool failed: %v", err)
+	}
+	utils.LogInfo("Shutdown complete")
 }
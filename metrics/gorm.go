@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// GormPlugin times every statement GORM runs. Register it with db.Use.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	register := func(operation string, before, after func(name string, fn func(*gorm.DB)) error) error {
+		if err := before("metrics:before_"+operation, startTimer); err != nil {
+			return err
+		}
+		return after("metrics:after_"+operation, func(tx *gorm.DB) { observe(tx, operation) })
+	}
+
+	return errors.Join(
+		register("create", callback.Create().Before("gorm:create").Register, callback.Create().After("gorm:create").Register),
+		register("query", callback.Query().Before("gorm:query").Register, callback.Query().After("gorm:query").Register),
+		register("update", callback.Update().Before("gorm:update").Register, callback.Update().After("gorm:update").Register),
+		register("delete", callback.Delete().Before("gorm:delete").Register, callback.Delete().After("gorm:delete").Register),
+		register("row", callback.Row().Before("gorm:row").Register, callback.Row().After("gorm:row").Register),
+		register("raw", callback.Raw().Before("gorm:raw").Register, callback.Raw().After("gorm:raw").Register),
+	)
+}
+
+func startTimer(tx *gorm.DB) {
+	tx.InstanceSet(startKey, time.Now())
+}
+
+func observe(tx *gorm.DB, operation string) {
+	value, ok := tx.InstanceGet(startKey)
+	if !ok {
+		return
+	}
+	start, ok := value.(time.Time)
+	if !ok {
+		return
+	}
+
+	table := tx.Statement.Table
+	if table == "" {
+		table = "unknown"
+	}
+	dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		dbQueryErrors.WithLabelValues(operation, table).Inc()
+	}
+}
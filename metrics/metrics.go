@@ -0,0 +1,158 @@
+// Package metrics exposes Prometheus metrics for HTTP traffic, the database
+// and domain events. Domain counters are package-level so services can record
+// events without extra wiring; NewRegistry collects them for one app.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "fiber_backend"
+
+// scrapeTimeout bounds the store queries made while serving a scrape
+const scrapeTimeout = 5 * time.Second
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database statement latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Database statements that failed, by operation and table. Not-found lookups are not errors.",
+	}, []string{"operation", "table"})
+
+	// BookingsCreated counts bookings created through either booking endpoint
+	BookingsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bookings_created_total",
+		Help:      "Bookings created.",
+	})
+
+	// BookingsCompleted counts bookings moved to the completed status
+	BookingsCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bookings_completed_total",
+		Help:      "Bookings marked as completed.",
+	})
+
+	// Logins counts login attempts by method (password, google or an OIDC
+	// provider's name) and result (success, failure)
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by method and result.",
+	}, []string{"method", "result"})
+
+	// OAuthFailures counts failed OAuth sign-ins by provider and the step that failed
+	OAuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oauth_failures_total",
+		Help:      "Failed OAuth sign-ins by provider and stage.",
+	}, []string{"provider", "stage"})
+
+	// RateLimited counts requests rejected by a rate limit, by route group
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected for exceeding a rate limit, by route group.",
+	}, []string{"group"})
+
+	// AccountLockouts counts accounts locked after repeated failed logins
+	AccountLockouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "account_lockouts_total",
+		Help:      "Accounts locked after repeated failed logins.",
+	})
+)
+
+// Options are the app-specific sources a registry reports on
+type Options struct {
+	// DB is the connection pool behind GORM; nil when running without a database
+	DB *sql.DB
+	// PendingRequests counts tourist requests waiting for a driver
+	PendingRequests func(ctx context.Context) (int64, error)
+}
+
+// NewRegistry builds a registry with the runtime, HTTP, database and domain
+// metrics. Each app gets its own so tests can build several side by side.
+func NewRegistry(opts Options) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbQueryDuration,
+		dbQueryErrors,
+		BookingsCreated,
+		BookingsCompleted,
+		Logins,
+		OAuthFailures,
+		RateLimited,
+		AccountLockouts,
+	)
+
+	if opts.DB != nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(opts.DB, "main"))
+	}
+	if opts.PendingRequests != nil {
+		registry.MustRegister(newPendingRequestsCollector(opts.PendingRequests))
+	}
+	return registry
+}
+
+// pendingRequestsCollector reads the pending count from the store on each scrape,
+// so it stays right no matter which code path changed a request's status
+type pendingRequestsCollector struct {
+	count func(ctx context.Context) (int64, error)
+	desc  *prometheus.Desc
+}
+
+func newPendingRequestsCollector(count func(ctx context.Context) (int64, error)) *pendingRequestsCollector {
+	return &pendingRequestsCollector{
+		count: count,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tourist_requests_pending"),
+			"Tourist requests waiting for a driver.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *pendingRequestsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *pendingRequestsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	count, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
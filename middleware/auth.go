@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"context"
+	"fiber-backend/apperror"
+	"fiber-backend/i18n"
 	"fiber-backend/utils"
 	"strings"
 
@@ -8,46 +11,50 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func Protected() fiber.Handler {
+// AccessTokenCookie holds the token of sign-ins delivered by cookie
+const AccessTokenCookie = "access_token"
+
+// Sessions tells Protected whether the tokens a user was issued for a session
+// version are still good; they aren't once the user's password changes or the
+// user is gone
+type Sessions interface {
+	SessionValid(ctx context.Context, userID, version uint) (bool, error)
+}
+
+// Protected rejects requests without a valid bearer token and stores the
+// caller's ID in c.Locals("userID"). Without an Authorization header the token
+// is read from AccessTokenCookie. The language stored in the token, if any,
+// replaces the one negotiated by Locale.
+func Protected(tokens *utils.JWTManager, sessions Sessions) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		utils.LogInfo("Processing protected route: %s", c.Path())
+		utils.LogInfoContext(c.UserContext(), "Processing protected route: %s", c.Path())
 
 		// Get the Authorization header
 		authHeader := c.Get("Authorization")
+		if authHeader == "" && c.Cookies(AccessTokenCookie) != "" {
+			authHeader = "Bearer " + c.Cookies(AccessTokenCookie)
+		}
 		if authHeader == "" {
-			utils.LogError("Authorization header missing for route: %s", c.Path())
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authorization header is required",
-			})
+			utils.LogErrorContext(c.UserContext(), "Authorization header missing for route: %s", c.Path())
+			return apperror.AuthHeaderMissing
 		}
 
 		// Check if the header has the Bearer prefix
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			utils.LogError("Invalid authorization header format for route: %s", c.Path())
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid authorization header format",
-			})
+			utils.LogErrorContext(c.UserContext(), "Invalid authorization header format for route: %s", c.Path())
+			return apperror.AuthHeaderInvalid
 		}
 
 		// Get the token
 		tokenString := parts[1]
 
 		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				utils.LogError("Invalid token signing method for route: %s", c.Path())
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token signing method")
-			}
-			return utils.GetJWTSecret(), nil
-		})
+		token, err := tokens.Validate(tokenString)
 
 		if err != nil {
-			utils.LogError("Token validation failed for route: %s, error: %v", c.Path(), err)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token",
-			})
+			utils.LogErrorContext(c.UserContext(), "Token validation failed for route: %s, error: %v", c.Path(), err)
+			return apperror.TokenInvalid
 		}
 
 		// Check if the token is valid
@@ -67,21 +74,32 @@ func Protected() fiber.Handler {
 			}
 
 			if !ok {
-				utils.LogError("Invalid user_id in token claims for route: %s", c.Path())
-				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-					"error": "Invalid token claims",
-				})
+				utils.LogErrorContext(c.UserContext(), "Invalid user_id in token claims for route: %s", c.Path())
+				return apperror.TokenInvalid
+			}
+
+			// Tokens from before session versions were added count as version 0
+			version, _ := claims["sv"].(float64)
+			valid, err := sessions.SessionValid(c.UserContext(), uint(userID), uint(version))
+			if err != nil {
+				return err
+			}
+			if !valid {
+				utils.LogErrorContext(c.UserContext(), "Revoked token of user %d for route: %s", uint(userID), c.Path())
+				return apperror.TokenInvalid
 			}
 
 			// Convert float64 to uint
 			c.Locals("userID", uint(userID))
-			utils.LogInfo("User %d authenticated successfully for route: %s", uint(userID), c.Path())
+			// The language the user chose wins over Accept-Language
+			if language, _ := claims["lang"].(string); i18n.Supported(language) != "" {
+				SetLanguage(c, i18n.Supported(language))
+			}
+			utils.LogInfoContext(c.UserContext(), "User %d authenticated successfully for route: %s", uint(userID), c.Path())
 			return c.Next()
 		}
 
-		utils.LogError("Invalid token claims for route: %s", c.Path())
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid token",
-		})
+		utils.LogErrorContext(c.UserContext(), "Invalid token claims for route: %s", c.Path())
+		return apperror.TokenInvalid
 	}
 }
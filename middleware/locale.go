@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"fiber-backend/i18n"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Locale picks the response language from Accept-Language and stores it in the
+// user context. Protected replaces it with the user's stored language.
+func Locale() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		language := i18n.Negotiate(c.Get(fiber.HeaderAcceptLanguage))
+		if language == "" {
+			language = i18n.Default
+		}
+		SetLanguage(c, language)
+		return c.Next()
+	}
+}
+
+// SetLanguage switches the language of the response
+func SetLanguage(c *fiber.Ctx, language string) {
+	c.Set(fiber.HeaderContentLanguage, language)
+	c.SetUserContext(i18n.WithLanguage(c.UserContext(), language))
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"fiber-backend/utils"
+	"regexp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fiberutils "github.com/gofiber/fiber/v2/utils"
+)
+
+// validRequestID limits inbound IDs to something safe to echo and log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID reuses the caller's X-Request-ID or generates one, returns it in
+// the response header and stores it in the user context so every log line
+// written for the request carries it
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID.MatchString(requestID) {
+			requestID = fiberutils.UUIDv4()
+		}
+
+		c.Set(fiber.HeaderXRequestID, requestID)
+		c.Locals("requestID", requestID)
+		c.SetUserContext(utils.WithRequestID(c.UserContext(), requestID))
+		return c.Next()
+	}
+}
+
+// AccessLog writes one structured line per request once the response is ready
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		if err != nil {
+			// Let the error handler pick the status before it is logged
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := utils.Logger().InfoContext
+		if status >= fiber.StatusInternalServerError {
+			level = utils.Logger().ErrorContext
+		}
+		level(c.UserContext(), "request",
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+			"bytes", len(c.Response().Body()),
+		)
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// timeoutOwner is the Locals key holding the context of the innermost Timeout
+const timeoutOwner = "timeoutContext"
+
+// timeoutParent is the Locals key holding the user context from before any Timeout
+const timeoutParent = "timeoutParent"
+
+// Timeout puts a deadline on the request's user context, which services pass on to
+// every query and outbound call. A Timeout on a route replaces the one set by
+// app.Use, so single routes can be given more or less time than the default.
+//
+// If the handler fails once the context is done, the context's error is returned
+// instead so the error handler answers 499 (client gone) or 503 (deadline passed).
+func Timeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		parent, ok := c.Locals(timeoutParent).(context.Context)
+		if !ok {
+			parent = c.UserContext()
+			c.Locals(timeoutParent, parent)
+		}
+
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		c.Locals(timeoutOwner, ctx)
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if c.Locals(timeoutOwner) != ctx {
+			// A route-level Timeout took over and has already handled the outcome
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil && (err != nil || c.Response().StatusCode() >= fiber.StatusBadRequest) {
+			return ctxErr
+		}
+		return err
+	}
+}
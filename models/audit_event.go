@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Audit events recorded by the auth service
+const (
+	AuditLoginSucceeded = "login.succeeded"
+	AuditLoginFailed    = "login.failed"
+	AuditLoginBlocked   = "login.blocked" // attempted while the account was locked
+	AuditAccountLocked  = "account.locked"
+
+	AuditIdentityLinked   = "identity.linked" // detail is the provider
+	AuditIdentityUnlinked = "identity.unlinked"
+	AuditPasswordSet      = "password.set"
+	AuditEmailVerified    = "email.verified" // detail is "link" or the provider that vouched for it
+
+	AuditPasswordChanged        = "password.changed"
+	AuditPasswordResetRequested = "password.reset_requested" // detail says why no email was sent, if none was
+	AuditPasswordReset          = "password.reset"
+)
+
+// AuditEvent is an append-only record of a security-relevant event. Email is
+// the address that was tried, which may not belong to any user.
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Event     string    `json:"event" gorm:"not null"`
+	UserID    *uint     `json:"user_id"`
+	Email     string    `json:"email" gorm:"not null;default:''"`
+	IP        string    `json:"ip" gorm:"not null;default:''"`
+	Detail    string    `json:"detail" gorm:"not null;default:''"`
+	CreatedAt time.Time `json:"created_at"`
+}
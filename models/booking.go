@@ -17,4 +17,5 @@ type Booking struct {
 	PickupLocation  string    `json:"pickup_location"`
 	DropoffLocation string    `json:"dropoff_location"`
 	DateTime        string    `json:"date_time"`
+	Version         uint      `json:"version" gorm:"not null;default:1"`
 }
@@ -14,7 +14,10 @@ type Driver struct {
 	VehicleColor  string  `json:"vehicle_color" gorm:"not null"`
 	Languages     string  `json:"languages" gorm:"not null"`  // Comma-separated list of languages
 	Experience    int     `json:"experience" gorm:"not null"` // Years of experience
+	PhotoURL      string  `json:"photo_url"`
 	Rating        float32 `json:"rating" gorm:"default:0"`
+	ReviewCount   int     `json:"review_count" gorm:"default:0"`
 	Status        string  `json:"status" gorm:"default:'pending'"` // pending, active, suspended
 	IsAvailable   bool    `json:"is_available" gorm:"default:true"`
+	Version       uint    `json:"version" gorm:"not null;default:1"`
 }
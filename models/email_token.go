@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// What an EmailToken is good for
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// EmailToken is a single-use token emailed to a user as a link. Only its
+// SHA-256 hash is stored, and it is good for Purpose until ExpiresAt.
+type EmailToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"not null"`
+	Purpose   string    `json:"purpose" gorm:"size:20;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginCode is a single-use code the frontend exchanges for an access token
+// after a sign-in redirect, so the token itself never appears in a URL. Only
+// the code's SHA-256 hash is stored.
+type LoginCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CodeHash  string    `json:"-" gorm:"uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -16,5 +16,6 @@ type Tourist struct {
 	DepartureDate time.Time `json:"departure_date" gorm:"not null"`
 	Preferences   string    `json:"preferences"`
 	SpecialNeeds  string    `json:"special_needs"`
-	Status        string    `json:"status" gorm:"default:'pending'"` // pending, active, completed
+	Status        string    `json:"status" gorm:"default:'pending'"`   // pending, active, completed
+	Version       uint      `json:"version" gorm:"not null;default:1"` // Incremented on every update, exposed as the ETag
 }
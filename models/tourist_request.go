@@ -14,4 +14,5 @@ type TouristRequest struct {
 	Notes           string  `json:"notes"`
 	Status          string  `json:"status" gorm:"type:varchar(20);default:'pending'"`
 	// Status can be: pending, accepted, rejected, completed
+	Version uint `json:"version" gorm:"not null;default:1"`
 }
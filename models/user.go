@@ -6,14 +6,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// User is an account. Users who signed up through a provider have no password
+// until they set one; their provider accounts are UserIdentity rows.
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	Email     string         `json:"email" gorm:"unique;not null"`
-	Password  string         `json:"-" gorm:"not null"`
+	Password  string         `json:"-" gorm:"not null"` // bcrypt hash, empty when the user has none
 	Name      string         `json:"name"`
-	GoogleID  *string        `json:"google_id" gorm:"unique"`
 	Role      string         `json:"role" gorm:"type:user_role;default:null"`
+	Language  string         `json:"language" gorm:"size:8;not null;default:''"` // i18n language code, empty to negotiate
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// EmailVerifiedAt is when the user confirmed they own Email, nil until then
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// SessionVersion is carried by the user's access tokens; bumping it when
+	// the password changes signs the user out everywhere
+	SessionVersion uint `json:"-" gorm:"not null;default:0"`
+}
+
+// EmailVerified reports whether the user confirmed their email address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// HasPassword reports whether the user can sign in with a password
+func (u *User) HasPassword() bool {
+	return u.Password != ""
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an external sign-in provider.
+// Subject is the provider's stable ID for the account, unique per provider.
+type UserIdentity struct {
+	ID       uint      `json:"id" gorm:"primaryKey"`
+	UserID   uint      `json:"user_id" gorm:"not null;index"`
+	Provider string    `json:"provider" gorm:"size:50;not null;uniqueIndex:idx_user_identities_subject"`
+	Subject  string    `json:"subject" gorm:"not null;uniqueIndex:idx_user_identities_subject"`
+	Email    string    `json:"email" gorm:"not null;default:''"` // as the provider reported it when linked
+	LinkedAt time.Time `json:"linked_at" gorm:"not null;autoCreateTime"`
+}
@@ -0,0 +1,134 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/config"
+	"fiber-backend/oidc"
+	"fiber-backend/oidc/oidctest"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const redirectURL = "https://api.example.com/auth/oidc/corp/callback"
+
+func newProvider(t *testing.T, claims config.OIDCClaims) (*oidc.Provider, *oidctest.Issuer) {
+	issuer := oidctest.NewIssuer(t)
+	provider := oidc.NewProvider(config.OIDCProviderConfig{
+		Name:         "corp",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  redirectURL,
+		Claims:       claims,
+	}, http.DefaultClient)
+	return provider, issuer
+}
+
+// signIn runs a sign-in against the issuer: it follows the authorization URL,
+// started with nonce, and redeems the code it comes back with expecting the-nonce
+func signIn(t *testing.T, provider *oidc.Provider, nonce string) (*oidc.Identity, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, err := provider.AuthURL(ctx, "the-state", "the-verifier-of-at-least-43-characters-long", nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(callback.String(), redirectURL) || callback.Query().Get("state") != "the-state" {
+		t.Fatalf("unexpected redirect %q (status %d)", res.Header.Get("Location"), res.StatusCode)
+	}
+
+	return provider.Exchange(ctx, callback.Query().Get("code"), "the-verifier-of-at-least-43-characters-long", "the-nonce")
+}
+
+func TestExchangeReturnsTheVerifiedIdentity(t *testing.T) {
+	provider, issuer := newProvider(t, config.OIDCClaims{})
+	issuer.Login(map[string]any{"sub": "u-42", "email": "ana@example.com", "name": "Ana"})
+
+	identity, err := signIn(t, provider, "the-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := oidc.Identity{Provider: "corp", Subject: "u-42", Email: "ana@example.com", EmailVerified: true, Name: "Ana"}
+	if *identity != want {
+		t.Fatalf("got %+v, want %+v", *identity, want)
+	}
+
+	authorization := issuer.LastAuthorization()
+	if authorization.Get("nonce") != "the-nonce" || authorization.Get("scope") != "openid email profile" || authorization.Get("response_mode") != "" {
+		t.Fatalf("unexpected authorization request %v", authorization)
+	}
+}
+
+func TestExchangeMapsConfiguredClaims(t *testing.T) {
+	provider, issuer := newProvider(t, config.OIDCClaims{Subject: "oid", Email: "upn", EmailVerified: "upn_verified", Name: "display_name"})
+	issuer.Login(map[string]any{"oid": "object-7", "upn": "ana@corp.example", "upn_verified": "true", "display_name": "Ana Corp"})
+
+	identity, err := signIn(t, provider, "the-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := oidc.Identity{Provider: "corp", Subject: "object-7", Email: "ana@corp.example", EmailVerified: true, Name: "Ana Corp"}
+	if *identity != want {
+		t.Fatalf("got %+v, want %+v", *identity, want)
+	}
+}
+
+func TestExchangeRejectsBadIDTokens(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(*oidctest.Issuer)
+		want  string
+	}{
+		{"other audience", func(i *oidctest.Issuer) { i.Login(map[string]any{"aud": "someone-else"}) }, "audience"},
+		{"other issuer", func(i *oidctest.Issuer) { i.Login(map[string]any{"iss": "https://evil.example"}) }, "different provider"},
+		{"expired", func(i *oidctest.Issuer) { i.Login(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}) }, "expired"},
+		{"unpublished key", func(i *oidctest.Issuer) { i.SignWithUnpublishedKey() }, "signature"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, issuer := newProvider(t, config.OIDCClaims{})
+			tc.setup(issuer)
+			_, err := signIn(t, provider, "the-nonce")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error about %s, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsAnotherSignInsNonce(t *testing.T) {
+	provider, _ := newProvider(t, config.OIDCClaims{})
+	if _, err := signIn(t, provider, "another-nonce"); !errors.Is(err, oidc.ErrNonce) {
+		t.Fatalf("expected ErrNonce, got %v", err)
+	}
+}
+
+func TestUnreachableIssuerFailsToStartSignIns(t *testing.T) {
+	provider := oidc.NewProvider(config.OIDCProviderConfig{Name: "down", Issuer: "http://127.0.0.1:1", ClientID: "c"}, http.DefaultClient)
+	if _, err := provider.AuthURL(context.Background(), "s", "v", "n"); err == nil || !strings.Contains(err.Error(), "discovery") {
+		t.Fatalf("discovery against an unreachable issuer should fail, got %v", err)
+	}
+}
+
+func TestRegistryLooksProvidersUpByName(t *testing.T) {
+	var registry *oidc.Registry
+	if registry.Get("down") != nil || len(registry.Names()) != 0 {
+		t.Fatal("a nil registry has no providers")
+	}
+	registry = oidc.NewRegistry([]config.OIDCProviderConfig{{Name: "b"}, {Name: "a"}}, http.DefaultClient)
+	if strings.Join(registry.Names(), ",") != "b,a" || registry.Get("a").Name() != "a" || registry.Get("c") != nil {
+		t.Fatalf("unexpected registry %v", registry.Names())
+	}
+}
@@ -0,0 +1,163 @@
+// Package oidc signs users in with any OpenID Connect provider. Providers are
+// found through discovery at their issuer, and the ID tokens they return are
+// checked against the issuer's published signing keys.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/config"
+	"fmt"
+	"net/http"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ErrNonce is returned for ID tokens that don't carry the nonce the sign-in
+// was started with, as when a token issued for another sign-in is replayed
+var ErrNonce = errors.New("id token nonce does not match")
+
+// defaultScopes are requested when a provider configures none
+var defaultScopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+
+// Identity is the account at a provider an ID token vouches for
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is one configured OIDC provider. Discovery happens on first use and
+// is retried on later calls until it succeeds.
+type Provider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider returns the provider for cfg, making its calls with client
+func NewProvider(cfg config.OIDCProviderConfig, client *http.Client) *Provider {
+	return &Provider{config: cfg, client: client}
+}
+
+// Name is the name the provider is configured and routed under
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// discover fetches the issuer's metadata the first time it succeeds
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	// The signing keys are fetched later with the same client, outside of
+	// any request
+	discovered, err := gooidc.NewProvider(gooidc.ClientContext(ctx, p.client), p.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery for %s failed: %w", p.config.Name, err)
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     discovered.Endpoint(),
+		Scopes:       scopes,
+	}
+	p.verifier = discovered.Verifier(&gooidc.Config{ClientID: p.config.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// AuthURL is where to send the user to sign in. state and nonce come back in
+// the callback and the ID token; verifier is the PKCE secret Exchange needs.
+func (p *Provider) AuthURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	oauth, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	options := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier), gooidc.Nonce(nonce)}
+	if p.config.ResponseMode != "" {
+		options = append(options, oauth2.SetAuthURLParam("response_mode", p.config.ResponseMode))
+	}
+	return oauth.AuthCodeURL(state, options...), nil
+}
+
+// Exchange redeems an authorization code and returns the identity in the ID
+// token, once its signature, issuer, audience, expiry and nonce check out
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	oauth, idTokens, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth.Exchange(gooidc.ClientContext(ctx, p.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%s token exchange failed: %w", p.config.Name, err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, fmt.Errorf("%s returned no id_token", p.config.Name)
+	}
+
+	idToken, err := idTokens.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s id token rejected: %w", p.config.Name, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonce
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return p.identity(claims)
+}
+
+// identity reads the mapped claims. email_verified is a string at some
+// providers, Apple among them.
+func (p *Provider) identity(claims map[string]any) (*Identity, error) {
+	names := p.config.Claims
+	identity := &Identity{
+		Provider: p.config.Name,
+		Subject:  stringClaim(claims, names.Subject, "sub"),
+		Email:    stringClaim(claims, names.Email, "email"),
+		Name:     stringClaim(claims, names.Name, "name"),
+	}
+	switch verified := claims[orDefault(names.EmailVerified, "email_verified")].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%s id token has no subject", p.config.Name)
+	}
+	return identity, nil
+}
+
+func stringClaim(claims map[string]any, name, fallback string) string {
+	value, _ := claims[orDefault(name, fallback)].(string)
+	return value
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
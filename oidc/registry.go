@@ -0,0 +1,38 @@
+package oidc
+
+import (
+	"fiber-backend/config"
+	"net/http"
+)
+
+// Registry holds the configured providers by name. A nil registry has none.
+type Registry struct {
+	providers map[string]*Provider
+	names     []string
+}
+
+// NewRegistry builds a provider for each config, all making their calls with client
+func NewRegistry(configs []config.OIDCProviderConfig, client *http.Client) *Registry {
+	r := &Registry{providers: map[string]*Provider{}}
+	for _, cfg := range configs {
+		r.providers[cfg.Name] = NewProvider(cfg, client)
+		r.names = append(r.names, cfg.Name)
+	}
+	return r
+}
+
+// Get returns the provider called name, or nil when there is none
+func (r *Registry) Get(name string) *Provider {
+	if r == nil {
+		return nil
+	}
+	return r.providers[name]
+}
+
+// Names lists the providers in the order they were configured
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	return r.names
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fiber-backend/apperror"
+	"fiber-backend/metrics"
+	"fiber-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Limit allows Requests per Window in each bucket
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// KeyFunc names the bucket a request counts against; "" exempts the request
+type KeyFunc func(c *fiber.Ctx) string
+
+// ByIP gives every client address its own bucket
+func ByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// ByAccount gives every email address in a JSON body its own bucket, so one
+// account can't be hammered from many addresses. Requests without an email are exempt.
+func ByAccount(c *fiber.Ctx) string {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(c.Body(), &body) != nil {
+		return ""
+	}
+	if account := Account(body.Email); account != "" {
+		return "account:" + account
+	}
+	return ""
+}
+
+// Account normalizes an email address so "Ana@Example.com " and
+// "ana@example.com" share their limits and lockout
+func Account(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Middleware allows each bucket limit.Requests per limit.Window and rejects the
+// rest with 429 RATE_LIMITED. Buckets are scoped to group, so every route group
+// has its own budget. When the store fails the request is let through: an
+// outage of the limiter should not take the API down with it.
+func Middleware(store Store, group string, limit Limit, key KeyFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bucket := key(c)
+		if bucket == "" {
+			return c.Next()
+		}
+
+		count, resets, err := store.Increment(c.UserContext(), "rate:"+group+":"+bucket, limit.Window)
+		if err != nil {
+			utils.LogErrorContext(c.UserContext(), "Rate limit store failed for %s: %v", group, err)
+			return c.Next()
+		}
+
+		remaining := limit.Requests - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(resets.Unix(), 10))
+
+		if count > limit.Requests {
+			metrics.RateLimited.WithLabelValues(group).Inc()
+			utils.LogInfoContext(c.UserContext(), "Rate limit of %s exceeded by %s", group, bucket)
+			c.Set(fiber.HeaderRetryAfter, retryAfter(resets))
+			return apperror.RateLimited
+		}
+		return c.Next()
+	}
+}
+
+// retryAfter renders the wait until t in whole seconds, at least one
+func retryAfter(t time.Time) string {
+	seconds := int(time.Until(t).Seconds() + 0.999)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
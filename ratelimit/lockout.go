@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LockoutPolicy locks an account after MaxFailures failed logins within Window.
+// The first lock lasts Duration and every further lock within a day doubles it,
+// up to MaxDuration.
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// lockHistory is how long earlier locks count toward the next one's duration
+const lockHistory = 24 * time.Hour
+
+// LockedError is returned for logins to a locked account
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// Lockout tracks failed logins per account. A nil *Lockout never locks anything.
+type Lockout struct {
+	store  Store
+	policy LockoutPolicy
+}
+
+// NewLockout returns a lockout keeping its counters in store
+func NewLockout(store Store, policy LockoutPolicy) *Lockout {
+	return &Lockout{store: store, policy: policy}
+}
+
+// Check returns a *LockedError while account is locked
+func (l *Lockout) Check(ctx context.Context, account string) error {
+	if l == nil {
+		return nil
+	}
+	locked, until, err := l.store.Get(ctx, "locked:"+account)
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return &LockedError{Until: until}
+	}
+	return nil
+}
+
+// Fail records a failed login. It returns a *LockedError when this failure
+// locks the account.
+func (l *Lockout) Fail(ctx context.Context, account string) error {
+	if l == nil {
+		return nil
+	}
+	failures, _, err := l.store.Increment(ctx, "failures:"+account, l.policy.Window)
+	if err != nil || failures < l.policy.MaxFailures {
+		return err
+	}
+
+	// Start over counting failures once the lock is over
+	if err := l.store.Delete(ctx, "failures:"+account); err != nil {
+		return err
+	}
+	locks, _, err := l.store.Increment(ctx, "locks:"+account, lockHistory)
+	if err != nil {
+		return err
+	}
+	_, until, err := l.store.Increment(ctx, "locked:"+account, l.lockDuration(locks))
+	if err != nil {
+		return err
+	}
+	return &LockedError{Until: until}
+}
+
+// Succeed forgets the failures and earlier locks of account
+func (l *Lockout) Succeed(ctx context.Context, account string) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.store.Delete(ctx, "failures:"+account); err != nil {
+		return err
+	}
+	return l.store.Delete(ctx, "locks:"+account)
+}
+
+// lockDuration is the length of the nth lock
+func (l *Lockout) lockDuration(n int) time.Duration {
+	duration := l.policy.Duration
+	for i := 1; i < n && duration < l.policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > l.policy.MaxDuration {
+		duration = l.policy.MaxDuration
+	}
+	return duration
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// clock is a fake time source the tests move by hand
+type clock struct{ now time.Time }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = func() time.Time { return c.now }
+	return store, c
+}
+
+func TestMemoryStoreCountersExpire(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTestStore()
+	start := clock.now
+
+	for want := 1; want <= 3; want++ {
+		count, expires, err := store.Increment(ctx, "k", time.Minute)
+		if err != nil || count != want {
+			t.Fatalf("increment %d: got %d, %v", want, count, err)
+		}
+		if !expires.Equal(start.Add(time.Minute)) {
+			t.Fatalf("the window should start at the first hit, expires %v", expires)
+		}
+		clock.advance(10 * time.Second)
+	}
+
+	clock.advance(time.Minute)
+	if count, _, _ := store.Get(ctx, "k"); count != 0 {
+		t.Fatalf("expired counter still reads %d", count)
+	}
+	if count, _, _ := store.Increment(ctx, "k", time.Minute); count != 1 {
+		t.Fatalf("expired counter should restart at 1, got %d", count)
+	}
+
+	store.Delete(ctx, "k")
+	if count, _, _ := store.Get(ctx, "k"); count != 0 {
+		t.Fatalf("deleted counter still reads %d", count)
+	}
+}
+
+func TestLockoutDoublesUpToTheMaximum(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTestStore()
+	lockout := NewLockout(store, LockoutPolicy{MaxFailures: 3, Window: 15 * time.Minute, Duration: time.Minute, MaxDuration: 3 * time.Minute})
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		for i := 1; i < 3; i++ {
+			if err := lockout.Fail(ctx, "ana@example.com"); err != nil {
+				t.Fatalf("failure %d should not lock yet: %v", i, err)
+			}
+		}
+		var locked *LockedError
+		if err := lockout.Fail(ctx, "ana@example.com"); !errors.As(err, &locked) {
+			t.Fatalf("third failure should lock, got %v", err)
+		}
+		if got := locked.Until.Sub(clock.now); got != want {
+			t.Fatalf("lock lasts %v, want %v", got, want)
+		}
+		if err := lockout.Check(ctx, "ana@example.com"); !errors.As(err, &locked) {
+			t.Fatalf("account should be locked, got %v", err)
+		}
+		if err := lockout.Check(ctx, "other@example.com"); err != nil {
+			t.Fatalf("other accounts are unaffected, got %v", err)
+		}
+		clock.advance(want)
+		if err := lockout.Check(ctx, "ana@example.com"); err != nil {
+			t.Fatalf("lock should be over after %v, got %v", want, err)
+		}
+	}
+
+	// A successful login forgets earlier locks
+	lockout.Succeed(ctx, "ana@example.com")
+	for i := 0; i < 3; i++ {
+		lockout.Fail(ctx, "ana@example.com")
+	}
+	var locked *LockedError
+	if err := lockout.Check(ctx, "ana@example.com"); !errors.As(err, &locked) || locked.Until.Sub(clock.now) != time.Minute {
+		t.Fatalf("lock after a success should last the base duration, got %v", err)
+	}
+}
+
+func TestNilLockoutNeverLocks(t *testing.T) {
+	var lockout *Lockout
+	for i := 0; i < 10; i++ {
+		if err := lockout.Fail(context.Background(), "ana@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lockout.Check(context.Background(), "ana@example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiddlewareRejectsRequestsOverTheLimit(t *testing.T) {
+	store, _ := newTestStore()
+	app := fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	app.Use(Middleware(store, "auth", Limit{Requests: 2, Window: time.Minute}, ByAccount))
+	app.Post("/login", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+
+	send := func(body string) *http.Response {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		res := send(`{"email": "Ana@Example.com"}`)
+		if res.StatusCode != http.StatusNoContent || res.Header.Get("X-RateLimit-Remaining") != wantRemaining {
+			t.Fatalf("request %d: status %d, remaining %q", i+1, res.StatusCode, res.Header.Get("X-RateLimit-Remaining"))
+		}
+		if res.Header.Get("X-RateLimit-Limit") != "2" || res.Header.Get("X-RateLimit-Reset") == "" {
+			t.Fatalf("request %d is missing rate limit headers: %v", i+1, res.Header)
+		}
+	}
+
+	// The same account in another spelling shares the bucket
+	res := send(`{"email": " ana@example.com"}`)
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") == "" {
+		t.Fatalf("third request: status %d, Retry-After %q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+
+	if res := send(`{"email": "other@example.com"}`); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("other accounts have their own bucket, got %d", res.StatusCode)
+	}
+	if res := send(`not json`); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("requests without an account are exempt, got %d", res.StatusCode)
+	}
+}
+
+// failingStore is a Store whose backend is down
+type failingStore struct{}
+
+func (failingStore) Increment(context.Context, string, time.Duration) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("connection refused")
+}
+func (failingStore) Get(context.Context, string) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("connection refused")
+}
+func (failingStore) Delete(context.Context, string) error { return errors.New("connection refused") }
+
+func TestMiddlewareFailsOpen(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware(failingStore{}, "api", Limit{Requests: 1, Window: time.Minute}, ByIP))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+
+	for i := 0; i < 3; i++ {
+		res, err := app.Test(httptest.NewRequest("GET", "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("request %d: an unavailable store should not block requests, got %d", i+1, res.StatusCode)
+		}
+	}
+}
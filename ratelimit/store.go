@@ -0,0 +1,100 @@
+// Package ratelimit throttles requests and locks accounts after repeated failed
+// logins. Counters live in a Store: in memory by default, or in a shared store
+// (Redis, memcached, a database) so limits hold across every replica.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps counters that expire. Implementations must be safe for concurrent
+// use; a shared store typically maps Increment to an atomic increment that sets
+// the expiry on the first hit (INCR and PEXPIRE in Redis).
+type Store interface {
+	// Increment adds one to the counter at key and returns the new count and when
+	// the counter expires. A missing or expired counter restarts at one and
+	// expires after ttl.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int, time.Time, error)
+	// Get returns the counter at key, or zero when it is missing or expired
+	Get(ctx context.Context, key string) (int, time.Time, error)
+	// Delete removes the counter at key
+	Delete(ctx context.Context, key string) error
+}
+
+// sweepInterval is how often the memory store drops expired counters
+const sweepInterval = time.Minute
+
+type counter struct {
+	count   int
+	expires time.Time
+}
+
+// MemoryStore is a Store local to one process, fine for a single replica
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]counter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]counter{}, now: time.Now}
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, ttl time.Duration) (int, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, time.Time{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = counter{expires: now.Add(ttl)}
+	}
+	c.count++
+	s.counters[key] = c
+	return c.count, c.expires, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (int, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, time.Time{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !s.now().Before(c.expires) {
+		return 0, time.Time{}, nil
+	}
+	return c.count, c.expires, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counters, key)
+	return nil
+}
+
+// sweep drops expired counters so idle clients don't accumulate. Callers hold mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, c := range s.counters {
+		if !now.Before(c.expires) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns the production store backed by GORM. The connection should be
+// opened with TranslateError so unique violations surface as ErrDuplicate.
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Users() UserRepository                     { return &gormUsers{db: s.db} }
+func (s *gormStore) Tourists() TouristRepository               { return &gormTourists{db: s.db} }
+func (s *gormStore) Drivers() DriverRepository                 { return &gormDrivers{db: s.db} }
+func (s *gormStore) Bookings() BookingRepository               { return &gormBookings{db: s.db} }
+func (s *gormStore) TouristRequests() TouristRequestRepository { return &gormTouristRequests{db: s.db} }
+func (s *gormStore) AuditEvents() AuditEventRepository         { return &gormAuditEvents{db: s.db} }
+func (s *gormStore) LoginCodes() LoginCodeRepository           { return &gormLoginCodes{db: s.db} }
+func (s *gormStore) Identities() IdentityRepository            { return &gormIdentities{db: s.db} }
+func (s *gormStore) EmailTokens() EmailTokenRepository         { return &gormEmailTokens{db: s.db} }
+
+func (s *gormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
+
+// translate maps GORM errors onto the repository errors
+func translate(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	default:
+		return err
+	}
+}
+
+// updateVersioned writes every column of value if the row's version still matches
+// *version, bumping the version in the same statement
+func updateVersioned(db *gorm.DB, value interface{}, version *uint) error {
+	current := *version
+	*version = current + 1
+
+	result := db.Model(value).
+		Where("version = ?", current).
+		Select("*").
+		Omit(clause.Associations, "id", "created_at").
+		Updates(value)
+	if result.Error != nil {
+		*version = current
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		*version = current
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	return translate(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *gormUsers) Update(ctx context.Context, user *models.User) error {
+	omit := []string{"password", "session_version"}
+	// Users who signed up through a provider have no role until they pick one;
+	// the column stays NULL, which an empty string would violate
+	if user.Role == "" {
+		omit = append(omit, "role")
+	}
+	return translate(r.db.WithContext(ctx).Omit(omit...).Save(user).Error)
+}
+
+func (r *gormUsers) UpdatePassword(ctx context.Context, id uint, passwordHash string) error {
+	return r.updateColumn(ctx, id, "password", passwordHash)
+}
+
+func (r *gormUsers) RevokeSessions(ctx context.Context, id uint) error {
+	return r.updateColumn(ctx, id, "session_version", gorm.Expr("session_version + 1"))
+}
+
+// updateColumn writes one column of the user in place, whatever other copies
+// of the user are being saved meanwhile
+func (r *gormUsers) updateColumn(ctx context.Context, id uint, column string, value interface{}) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update(column, value)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormTourists struct {
+	db *gorm.DB
+}
+
+func (r *gormTourists) FindByID(ctx context.Context, id uint) (*models.Tourist, error) {
+	var tourist models.Tourist
+	if err := r.db.WithContext(ctx).First(&tourist, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &tourist, nil
+}
+
+func (r *gormTourists) FindByUserID(ctx context.Context, userID uint) (*models.Tourist, error) {
+	var tourist models.Tourist
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&tourist).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &tourist, nil
+}
+
+func (r *gormTourists) Create(ctx context.Context, tourist *models.Tourist) error {
+	return translate(r.db.WithContext(ctx).Omit(clause.Associations).Create(tourist).Error)
+}
+
+func (r *gormTourists) Update(ctx context.Context, tourist *models.Tourist) error {
+	return updateVersioned(r.db.WithContext(ctx), tourist, &tourist.Version)
+}
+
+type gormDrivers struct {
+	db *gorm.DB
+}
+
+func (r *gormDrivers) FindByID(ctx context.Context, id uint) (*models.Driver, error) {
+	var driver models.Driver
+	if err := r.db.WithContext(ctx).Preload("User").First(&driver, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &driver, nil
+}
+
+func (r *gormDrivers) FindByUserID(ctx context.Context, userID uint) (*models.Driver, error) {
+	var driver models.Driver
+	if err := r.db.WithContext(ctx).Preload("User").Where("user_id = ?", userID).First(&driver).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &driver, nil
+}
+
+func (r *gormDrivers) List(ctx context.Context) ([]models.Driver, error) {
+	var drivers []models.Driver
+	err := r.db.WithContext(ctx).Preload("User").Find(&drivers).Error
+	return drivers, translate(err)
+}
+
+func (r *gormDrivers) ListByStatus(ctx context.Context, status string) ([]models.Driver, error) {
+	var drivers []models.Driver
+	err := r.db.WithContext(ctx).Preload("User").Where("status = ?", status).Find(&drivers).Error
+	return drivers, translate(err)
+}
+
+func (r *gormDrivers) Create(ctx context.Context, driver *models.Driver) error {
+	return translate(r.db.WithContext(ctx).Omit(clause.Associations).Create(driver).Error)
+}
+
+func (r *gormDrivers) Update(ctx context.Context, driver *models.Driver) error {
+	return updateVersioned(r.db.WithContext(ctx), driver, &driver.Version)
+}
+
+type gormBookings struct {
+	db *gorm.DB
+}
+
+func (r *gormBookings) withRelations(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("Driver.User").Preload("Tourist.User")
+}
+
+func (r *gormBookings) FindByID(ctx context.Context, id uint) (*models.Booking, error) {
+	var booking models.Booking
+	if err := r.withRelations(ctx).First(&booking, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &booking, nil
+}
+
+func (r *gormBookings) ListByTourist(ctx context.Context, touristID uint) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.withRelations(ctx).Where("tourist_id = ?", touristID).Find(&bookings).Error
+	return bookings, translate(err)
+}
+
+func (r *gormBookings) ListByDriver(ctx context.Context, driverID uint) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.withRelations(ctx).Where("driver_id = ?", driverID).Find(&bookings).Error
+	return bookings, translate(err)
+}
+
+func (r *gormBookings) Create(ctx context.Context, booking *models.Booking) error {
+	return translate(r.db.WithContext(ctx).Omit(clause.Associations).Create(booking).Error)
+}
+
+func (r *gormBookings) Update(ctx context.Context, booking *models.Booking) error {
+	return updateVersioned(r.db.WithContext(ctx), booking, &booking.Version)
+}
+
+type gormTouristRequests struct {
+	db *gorm.DB
+}
+
+func (r *gormTouristRequests) FindByID(ctx context.Context, id uint) (*models.TouristRequest, error) {
+	var request models.TouristRequest
+	if err := r.db.WithContext(ctx).First(&request, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &request, nil
+}
+
+func (r *gormTouristRequests) CountByStatus(ctx context.Context, status string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.TouristRequest{}).Where("status = ?", status).Count(&count).Error
+	return count, translate(err)
+}
+
+func (r *gormTouristRequests) Create(ctx context.Context, request *models.TouristRequest) error {
+	return translate(r.db.WithContext(ctx).Omit(clause.Associations).Create(request).Error)
+}
+
+func (r *gormTouristRequests) Update(ctx context.Context, request *models.TouristRequest) error {
+	return updateVersioned(r.db.WithContext(ctx), request, &request.Version)
+}
+
+type gormAuditEvents struct {
+	db *gorm.DB
+}
+
+func (r *gormAuditEvents) Create(ctx context.Context, event *models.AuditEvent) error {
+	return translate(r.db.WithContext(ctx).Create(event).Error)
+}
+
+func (r *gormAuditEvents) List(ctx context.Context, email string, limit int) ([]models.AuditEvent, error) {
+	query := r.db.WithContext(ctx).Order("created_at DESC, id DESC").Limit(limit)
+	if email != "" {
+		query = query.Where("email = ?", email)
+	}
+	var events []models.AuditEvent
+	return events, translate(query.Find(&events).Error)
+}
+
+type gormLoginCodes struct {
+	db *gorm.DB
+}
+
+func (r *gormLoginCodes) Create(ctx context.Context, code *models.LoginCode) error {
+	return translate(r.db.WithContext(ctx).Create(code).Error)
+}
+
+func (r *gormLoginCodes) Consume(ctx context.Context, codeHash string, now time.Time) (*models.LoginCode, error) {
+	var code models.LoginCode
+	if err := r.db.WithContext(ctx).Where("code_hash = ? AND expires_at > ?", codeHash, now).First(&code).Error; err != nil {
+		return nil, translate(err)
+	}
+	// Of two concurrent exchanges of the same code only one deletes the row
+	result := r.db.WithContext(ctx).Delete(&models.LoginCode{}, code.ID)
+	if result.Error != nil {
+		return nil, translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return &code, nil
+}
+
+func (r *gormLoginCodes) DeleteByUser(ctx context.Context, userID uint) error {
+	return translate(r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.LoginCode{}).Error)
+}
+
+func (r *gormLoginCodes) DeleteExpired(ctx context.Context, now time.Time) error {
+	return translate(r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.LoginCode{}).Error)
+}
+
+type gormIdentities struct {
+	db *gorm.DB
+}
+
+func (r *gormIdentities) FindBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (r *gormIdentities) ListByUser(ctx context.Context, userID uint) ([]models.UserIdentity, error) {
+	identities := []models.UserIdentity{}
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("linked_at, id").Find(&identities).Error
+	return identities, translate(err)
+}
+
+func (r *gormIdentities) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return translate(r.db.WithContext(ctx).Create(identity).Error)
+}
+
+func (r *gormIdentities) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.UserIdentity{}, id)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormEmailTokens struct {
+	db *gorm.DB
+}
+
+func (r *gormEmailTokens) Create(ctx context.Context, token *models.EmailToken) error {
+	return translate(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormEmailTokens) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.EmailToken, error) {
+	var token models.EmailToken
+	err := r.db.WithContext(ctx).Where("token_hash = ? AND purpose = ? AND expires_at > ?", tokenHash, purpose, now).First(&token).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	// Of two concurrent uses of the same token only one deletes the row
+	result := r.db.WithContext(ctx).Delete(&models.EmailToken{}, token.ID)
+	if result.Error != nil {
+		return nil, translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (r *gormEmailTokens) DeleteByUser(ctx context.Context, userID uint, purpose string) error {
+	return translate(r.db.WithContext(ctx).Where("user_id = ? AND purpose = ?", userID, purpose).Delete(&models.EmailToken{}).Error)
+}
+
+func (r *gormEmailTokens) DeleteExpired(ctx context.Context, now time.Time) error {
+	return translate(r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.EmailToken{}).Error)
+}
@@ -0,0 +1,758 @@
+package repository
+
+import (
+	"context"
+	"fiber-backend/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryData is the state of an in-memory store. Rows are stored by value so callers
+// never share memory with the store.
+type memoryData struct {
+	nextID          uint
+	users           map[uint]models.User
+	tourists        map[uint]models.Tourist
+	drivers         map[uint]models.Driver
+	bookings        map[uint]models.Booking
+	touristRequests map[uint]models.TouristRequest
+	auditEvents     []models.AuditEvent
+	loginCodes      map[string]models.LoginCode
+	identities      map[uint]models.UserIdentity
+	emailTokens     map[string]models.EmailToken
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		users:           map[uint]models.User{},
+		tourists:        map[uint]models.Tourist{},
+		drivers:         map[uint]models.Driver{},
+		bookings:        map[uint]models.Booking{},
+		touristRequests: map[uint]models.TouristRequest{},
+		loginCodes:      map[string]models.LoginCode{},
+		identities:      map[uint]models.UserIdentity{},
+		emailTokens:     map[string]models.EmailToken{},
+	}
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := newMemoryData()
+	c.nextID = d.nextID
+	for id, row := range d.users {
+		c.users[id] = row
+	}
+	for id, row := range d.tourists {
+		c.tourists[id] = row
+	}
+	for id, row := range d.drivers {
+		c.drivers[id] = row
+	}
+	for id, row := range d.bookings {
+		c.bookings[id] = row
+	}
+	for id, row := range d.touristRequests {
+		c.touristRequests[id] = row
+	}
+	c.auditEvents = append(c.auditEvents, d.auditEvents...)
+	for hash, row := range d.loginCodes {
+		c.loginCodes[hash] = row
+	}
+	for id, row := range d.identities {
+		c.identities[id] = row
+	}
+	for hash, row := range d.emailTokens {
+		c.emailTokens[hash] = row
+	}
+	return c
+}
+
+func (d *memoryData) newID() uint {
+	d.nextID++
+	return d.nextID
+}
+
+// MemoryStore is a Store kept entirely in memory, meant for tests. It mimics the
+// database defaults the models rely on (status, availability and version).
+type MemoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	inTx bool
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mu: &sync.Mutex{}, data: newMemoryData()}
+}
+
+func (s *MemoryStore) Users() UserRepository                     { return &memoryUsers{s} }
+func (s *MemoryStore) Tourists() TouristRepository               { return &memoryTourists{s} }
+func (s *MemoryStore) Drivers() DriverRepository                 { return &memoryDrivers{s} }
+func (s *MemoryStore) Bookings() BookingRepository               { return &memoryBookings{s} }
+func (s *MemoryStore) TouristRequests() TouristRequestRepository { return &memoryTouristRequests{s} }
+func (s *MemoryStore) AuditEvents() AuditEventRepository         { return &memoryAuditEvents{s} }
+func (s *MemoryStore) LoginCodes() LoginCodeRepository           { return &memoryLoginCodes{s} }
+func (s *MemoryStore) Identities() IdentityRepository            { return &memoryIdentities{s} }
+func (s *MemoryStore) EmailTokens() EmailTokenRepository         { return &memoryEmailTokens{s} }
+
+// Transaction runs fn against a copy of the data and swaps it in if fn succeeds.
+// Transactions are serialized with every other call on the store.
+func (s *MemoryStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{mu: s.mu, data: s.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	// Like a database, a transaction whose context ended is rolled back
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// with runs fn while holding the store lock (unless already inside a transaction).
+// A done ctx fails the call the way a cancelled query would.
+func (s *MemoryStore) with(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !s.inTx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(s.data)
+}
+
+// stamp sets the timestamps GORM would fill in on save
+func stamp(createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	*updatedAt = now
+}
+
+type memoryUsers struct {
+	s *MemoryStore
+}
+
+func (r *memoryUsers) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var found *models.User
+	err := r.s.with(ctx, func(d *memoryData) error {
+		user, ok := d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		found = &user
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryUsers) findBy(ctx context.Context, match func(models.User) bool) (*models.User, error) {
+	var found *models.User
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, id := range sortedKeys(d.users) {
+			if user := d.users[id]; match(user) {
+				found = &user
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return found, err
+}
+
+func (r *memoryUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findBy(ctx, func(user models.User) bool { return user.Email == email })
+}
+
+func (r *memoryUsers) checkUnique(d *memoryData, user *models.User) error {
+	for id, existing := range d.users {
+		if id == user.ID {
+			continue
+		}
+		if existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+func (r *memoryUsers) Create(ctx context.Context, user *models.User) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		if err := r.checkUnique(d, user); err != nil {
+			return err
+		}
+		user.ID = d.newID()
+		stamp(&user.CreatedAt, &user.UpdatedAt)
+		d.users[user.ID] = *user
+		return nil
+	})
+}
+
+func (r *memoryUsers) Update(ctx context.Context, user *models.User) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		if _, ok := d.users[user.ID]; !ok {
+			return ErrNotFound
+		}
+		if err := r.checkUnique(d, user); err != nil {
+			return err
+		}
+		stamp(&user.CreatedAt, &user.UpdatedAt)
+		row := *user
+		row.Password = d.users[user.ID].Password
+		row.SessionVersion = d.users[user.ID].SessionVersion
+		d.users[user.ID] = row
+		return nil
+	})
+}
+
+func (r *memoryUsers) UpdatePassword(ctx context.Context, id uint, passwordHash string) error {
+	return r.update(ctx, id, func(user *models.User) { user.Password = passwordHash })
+}
+
+func (r *memoryUsers) RevokeSessions(ctx context.Context, id uint) error {
+	return r.update(ctx, id, func(user *models.User) { user.SessionVersion++ })
+}
+
+func (r *memoryUsers) update(ctx context.Context, id uint, change func(user *models.User)) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		user, ok := d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		change(&user)
+		user.UpdatedAt = time.Now()
+		d.users[id] = user
+		return nil
+	})
+}
+
+type memoryTourists struct {
+	s *MemoryStore
+}
+
+func (r *memoryTourists) FindByID(ctx context.Context, id uint) (*models.Tourist, error) {
+	var found *models.Tourist
+	err := r.s.with(ctx, func(d *memoryData) error {
+		tourist, ok := d.tourists[id]
+		if !ok {
+			return ErrNotFound
+		}
+		found = &tourist
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryTourists) FindByUserID(ctx context.Context, userID uint) (*models.Tourist, error) {
+	var found *models.Tourist
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, id := range sortedKeys(d.tourists) {
+			if tourist := d.tourists[id]; tourist.UserID == userID {
+				found = &tourist
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return found, err
+}
+
+func (r *memoryTourists) Create(ctx context.Context, tourist *models.Tourist) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		tourist.ID = d.newID()
+		if tourist.Status == "" {
+			tourist.Status = "pending"
+		}
+		if tourist.Version == 0 {
+			tourist.Version = 1
+		}
+		stamp(&tourist.CreatedAt, &tourist.UpdatedAt)
+		stored := *tourist
+		stored.User = models.User{}
+		d.tourists[tourist.ID] = stored
+		return nil
+	})
+}
+
+func (r *memoryTourists) Update(ctx context.Context, tourist *models.Tourist) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		existing, ok := d.tourists[tourist.ID]
+		if !ok || existing.Version != tourist.Version {
+			return ErrVersionConflict
+		}
+		tourist.Version++
+		tourist.CreatedAt = existing.CreatedAt
+		stamp(&tourist.CreatedAt, &tourist.UpdatedAt)
+		stored := *tourist
+		stored.User = models.User{}
+		d.tourists[tourist.ID] = stored
+		return nil
+	})
+}
+
+type memoryDrivers struct {
+	s *MemoryStore
+}
+
+// hydrate fills in the driver's User like Preload("User") would
+func (r *memoryDrivers) hydrate(d *memoryData, driver models.Driver) models.Driver {
+	driver.User = d.users[driver.UserID]
+	return driver
+}
+
+func (r *memoryDrivers) FindByID(ctx context.Context, id uint) (*models.Driver, error) {
+	var found *models.Driver
+	err := r.s.with(ctx, func(d *memoryData) error {
+		driver, ok := d.drivers[id]
+		if !ok {
+			return ErrNotFound
+		}
+		driver = r.hydrate(d, driver)
+		found = &driver
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryDrivers) FindByUserID(ctx context.Context, userID uint) (*models.Driver, error) {
+	var found *models.Driver
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, id := range sortedKeys(d.drivers) {
+			if driver := d.drivers[id]; driver.UserID == userID {
+				driver = r.hydrate(d, driver)
+				found = &driver
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return found, err
+}
+
+func (r *memoryDrivers) list(ctx context.Context, match func(models.Driver) bool) ([]models.Driver, error) {
+	drivers := []models.Driver{}
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, id := range sortedKeys(d.drivers) {
+			if driver := d.drivers[id]; match(driver) {
+				drivers = append(drivers, r.hydrate(d, driver))
+			}
+		}
+		return nil
+	})
+	return drivers, err
+}
+
+func (r *memoryDrivers) List(ctx context.Context) ([]models.Driver, error) {
+	return r.list(ctx, func(models.Driver) bool { return true })
+}
+
+func (r *memoryDrivers) ListByStatus(ctx context.Context, status string) ([]models.Driver, error) {
+	return r.list(ctx, func(driver models.Driver) bool { return driver.Status == status })
+}
+
+func (r *memoryDrivers) Create(ctx context.Context, driver *models.Driver) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		driver.ID = d.newID()
+		if driver.Status == "" {
+			driver.Status = "pending"
+		}
+		// Like the column default, a false availability is stored as true on insert
+		driver.IsAvailable = true
+		if driver.Version == 0 {
+			driver.Version = 1
+		}
+		stamp(&driver.CreatedAt, &driver.UpdatedAt)
+		stored := *driver
+		stored.User = models.User{}
+		d.drivers[driver.ID] = stored
+		return nil
+	})
+}
+
+func (r *memoryDrivers) Update(ctx context.Context, driver *models.Driver) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		existing, ok := d.drivers[driver.ID]
+		if !ok || existing.Version != driver.Version {
+			return ErrVersionConflict
+		}
+		driver.Version++
+		driver.CreatedAt = existing.CreatedAt
+		stamp(&driver.CreatedAt, &driver.UpdatedAt)
+		stored := *driver
+		stored.User = models.User{}
+		d.drivers[driver.ID] = stored
+		return nil
+	})
+}
+
+type memoryBookings struct {
+	s *MemoryStore
+}
+
+// hydrate fills in Driver.User and Tourist.User like the GORM preloads would
+func (r *memoryBookings) hydrate(d *memoryData, booking models.Booking) models.Booking {
+	booking.Driver = d.drivers[booking.DriverID]
+	booking.Driver.User = d.users[booking.Driver.UserID]
+	booking.Tourist = d.tourists[booking.TouristID]
+	booking.Tourist.User = d.users[booking.Tourist.UserID]
+	return booking
+}
+
+func (r *memoryBookings) FindByID(ctx context.Context, id uint) (*models.Booking, error) {
+	var found *models.Booking
+	err := r.s.with(ctx, func(d *memoryData) error {
+		booking, ok := d.bookings[id]
+		if !ok {
+			return ErrNotFound
+		}
+		booking = r.hydrate(d, booking)
+		found = &booking
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryBookings) list(ctx context.Context, match func(models.Booking) bool) ([]models.Booking, error) {
+	bookings := []models.Booking{}
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, id := range sortedKeys(d.bookings) {
+			if booking := d.bookings[id]; match(booking) {
+				bookings = append(bookings, r.hydrate(d, booking))
+			}
+		}
+		return nil
+	})
+	return bookings, err
+}
+
+func (r *memoryBookings) ListByTourist(ctx context.Context, touristID uint) ([]models.Booking, error) {
+	return r.list(ctx, func(booking models.Booking) bool { return booking.TouristID == touristID })
+}
+
+func (r *memoryBookings) ListByDriver(ctx context.Context, driverID uint) ([]models.Booking, error) {
+	return r.list(ctx, func(booking models.Booking) bool { return booking.DriverID == driverID })
+}
+
+func (r *memoryBookings) Create(ctx context.Context, booking *models.Booking) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		if _, ok := d.tourists[booking.TouristID]; !ok {
+			return ErrNotFound
+		}
+		if _, ok := d.drivers[booking.DriverID]; !ok {
+			return ErrNotFound
+		}
+		booking.ID = d.newID()
+		if booking.Status == "" {
+			booking.Status = "pending"
+		}
+		if booking.Version == 0 {
+			booking.Version = 1
+		}
+		stamp(&booking.CreatedAt, &booking.UpdatedAt)
+		stored := *booking
+		stored.Driver, stored.Tourist = models.Driver{}, models.Tourist{}
+		d.bookings[booking.ID] = stored
+		return nil
+	})
+}
+
+func (r *memoryBookings) Update(ctx context.Context, booking *models.Booking) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		existing, ok := d.bookings[booking.ID]
+		if !ok || existing.Version != booking.Version {
+			return ErrVersionConflict
+		}
+		booking.Version++
+		booking.CreatedAt = existing.CreatedAt
+		stamp(&booking.CreatedAt, &booking.UpdatedAt)
+		stored := *booking
+		stored.Driver, stored.Tourist = models.Driver{}, models.Tourist{}
+		d.bookings[booking.ID] = stored
+		return nil
+	})
+}
+
+type memoryTouristRequests struct {
+	s *MemoryStore
+}
+
+func (r *memoryTouristRequests) FindByID(ctx context.Context, id uint) (*models.TouristRequest, error) {
+	var found *models.TouristRequest
+	err := r.s.with(ctx, func(d *memoryData) error {
+		request, ok := d.touristRequests[id]
+		if !ok {
+			return ErrNotFound
+		}
+		found = &request
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryTouristRequests) CountByStatus(ctx context.Context, status string) (int64, error) {
+	var count int64
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, request := range d.touristRequests {
+			if request.Status == status {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryTouristRequests) Create(ctx context.Context, request *models.TouristRequest) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		request.ID = d.newID()
+		if request.Status == "" {
+			request.Status = "pending"
+		}
+		if request.Version == 0 {
+			request.Version = 1
+		}
+		stamp(&request.CreatedAt, &request.UpdatedAt)
+		stored := *request
+		stored.Tourist = models.Tourist{}
+		d.touristRequests[request.ID] = stored
+		return nil
+	})
+}
+
+func (r *memoryTouristRequests) Update(ctx context.Context, request *models.TouristRequest) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		existing, ok := d.touristRequests[request.ID]
+		if !ok || existing.Version != request.Version {
+			return ErrVersionConflict
+		}
+		request.Version++
+		request.CreatedAt = existing.CreatedAt
+		stamp(&request.CreatedAt, &request.UpdatedAt)
+		stored := *request
+		stored.Tourist = models.Tourist{}
+		d.touristRequests[request.ID] = stored
+		return nil
+	})
+}
+
+func sortedKeys[V any](rows map[uint]V) []uint {
+	keys := make([]uint, 0, len(rows))
+	for id := range rows {
+		keys = append(keys, id)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+type memoryAuditEvents struct {
+	s *MemoryStore
+}
+
+func (r *memoryAuditEvents) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		event.ID = d.newID()
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+		d.auditEvents = append(d.auditEvents, *event)
+		return nil
+	})
+}
+
+func (r *memoryAuditEvents) List(ctx context.Context, email string, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for i := len(d.auditEvents) - 1; i >= 0 && len(events) < limit; i-- {
+			if email == "" || d.auditEvents[i].Email == email {
+				events = append(events, d.auditEvents[i])
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+type memoryLoginCodes struct {
+	s *MemoryStore
+}
+
+func (r *memoryLoginCodes) Create(ctx context.Context, code *models.LoginCode) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		if _, taken := d.loginCodes[code.CodeHash]; taken {
+			return ErrDuplicate
+		}
+		code.ID = d.newID()
+		if code.CreatedAt.IsZero() {
+			code.CreatedAt = time.Now()
+		}
+		d.loginCodes[code.CodeHash] = *code
+		return nil
+	})
+}
+
+func (r *memoryLoginCodes) Consume(ctx context.Context, codeHash string, now time.Time) (*models.LoginCode, error) {
+	var found *models.LoginCode
+	err := r.s.with(ctx, func(d *memoryData) error {
+		code, ok := d.loginCodes[codeHash]
+		if !ok || !code.ExpiresAt.After(now) {
+			return ErrNotFound
+		}
+		delete(d.loginCodes, codeHash)
+		found = &code
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryLoginCodes) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		for hash, code := range d.loginCodes {
+			if code.UserID == userID {
+				delete(d.loginCodes, hash)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryLoginCodes) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		for hash, code := range d.loginCodes {
+			if !code.ExpiresAt.After(now) {
+				delete(d.loginCodes, hash)
+			}
+		}
+		return nil
+	})
+}
+
+type memoryIdentities struct {
+	s *MemoryStore
+}
+
+func (r *memoryIdentities) FindBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var found *models.UserIdentity
+	err := r.s.with(ctx, func(d *memoryData) error {
+		for _, identity := range d.identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				found = &identity
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return found, err
+}
+
+func (r *memoryIdentities) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		for _, existing := range d.identities {
+			if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+				return ErrDuplicate
+			}
+		}
+		if _, ok := d.users[identity.UserID]; !ok {
+			return ErrNotFound
+		}
+		identity.ID = d.newID()
+		if identity.LinkedAt.IsZero() {
+			identity.LinkedAt = time.Now()
+		}
+		d.identities[identity.ID] = *identity
+		return nil
+	})
+}
+
+func (r *memoryIdentities) ListByUser(ctx context.Context, userID uint) ([]models.UserIdentity, error) {
+	identities := []models.UserIdentity{}
+	err := r.s.with(ctx, func(d *memoryData) error {
+		// IDs grow with every insert, so their order is the order of linking
+		for _, id := range sortedKeys(d.identities) {
+			if identity := d.identities[id]; identity.UserID == userID {
+				identities = append(identities, identity)
+			}
+		}
+		return nil
+	})
+	return identities, err
+}
+
+func (r *memoryIdentities) Delete(ctx context.Context, id uint) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		if _, ok := d.identities[id]; !ok {
+			return ErrNotFound
+		}
+		delete(d.identities, id)
+		return nil
+	})
+}
+
+type memoryEmailTokens struct {
+	s *MemoryStore
+}
+
+func (r *memoryEmailTokens) Create(ctx context.Context, token *models.EmailToken) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		if _, taken := d.emailTokens[token.TokenHash]; taken {
+			return ErrDuplicate
+		}
+		if _, ok := d.users[token.UserID]; !ok {
+			return ErrNotFound
+		}
+		token.ID = d.newID()
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		d.emailTokens[token.TokenHash] = *token
+		return nil
+	})
+}
+
+func (r *memoryEmailTokens) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.EmailToken, error) {
+	var found *models.EmailToken
+	err := r.s.with(ctx, func(d *memoryData) error {
+		token, ok := d.emailTokens[tokenHash]
+		if !ok || token.Purpose != purpose || !token.ExpiresAt.After(now) {
+			return ErrNotFound
+		}
+		delete(d.emailTokens, tokenHash)
+		found = &token
+		return nil
+	})
+	return found, err
+}
+
+func (r *memoryEmailTokens) DeleteByUser(ctx context.Context, userID uint, purpose string) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		for hash, token := range d.emailTokens {
+			if token.UserID == userID && token.Purpose == purpose {
+				delete(d.emailTokens, hash)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryEmailTokens) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.s.with(ctx, func(d *memoryData) error {
+		for hash, token := range d.emailTokens {
+			if !token.ExpiresAt.After(now) {
+				delete(d.emailTokens, hash)
+			}
+		}
+		return nil
+	})
+}
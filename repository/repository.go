@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/models"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no row matches the lookup
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a unique column (email, provider subject) is already taken
+	ErrDuplicate = errors.New("duplicate record")
+	// ErrVersionConflict is returned when a row was changed by someone else after it was read
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// Store gives access to every repository and runs groups of calls atomically
+type Store interface {
+	Users() UserRepository
+	Tourists() TouristRepository
+	Drivers() DriverRepository
+	Bookings() BookingRepository
+	TouristRequests() TouristRequestRepository
+	AuditEvents() AuditEventRepository
+	LoginCodes() LoginCodeRepository
+	Identities() IdentityRepository
+	EmailTokens() EmailTokenRepository
+
+	// Transaction runs fn against a store whose writes are committed only if fn returns nil
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Every repository call takes the request's context. Once it is cancelled or its
+// deadline passes the query is abandoned and ctx.Err() is returned.
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	// Update saves the user but leaves the password and session version alone:
+	// they only change through UpdatePassword and RevokeSessions, so a writer
+	// holding an older copy of the user can't undo a password change
+	Update(ctx context.Context, user *models.User) error
+	// UpdatePassword sets the user's password hash
+	UpdatePassword(ctx context.Context, id uint, passwordHash string) error
+	// RevokeSessions bumps the user's session version, so the tokens issued
+	// before stop working
+	RevokeSessions(ctx context.Context, id uint) error
+}
+
+// Versioned repositories below only write an update when the stored version still
+// equals the one on the value passed in. On success the value's Version is bumped,
+// otherwise ErrVersionConflict is returned and nothing is written.
+
+type TouristRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Tourist, error)
+	FindByUserID(ctx context.Context, userID uint) (*models.Tourist, error)
+	Create(ctx context.Context, tourist *models.Tourist) error
+	Update(ctx context.Context, tourist *models.Tourist) error
+}
+
+// DriverRepository lookups preload the driver's User
+type DriverRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Driver, error)
+	FindByUserID(ctx context.Context, userID uint) (*models.Driver, error)
+	List(ctx context.Context) ([]models.Driver, error)
+	ListByStatus(ctx context.Context, status string) ([]models.Driver, error)
+	Create(ctx context.Context, driver *models.Driver) error
+	Update(ctx context.Context, driver *models.Driver) error
+}
+
+// BookingRepository lookups preload Driver.User and Tourist.User
+type BookingRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Booking, error)
+	ListByTourist(ctx context.Context, touristID uint) ([]models.Booking, error)
+	ListByDriver(ctx context.Context, driverID uint) ([]models.Booking, error)
+	Create(ctx context.Context, booking *models.Booking) error
+	Update(ctx context.Context, booking *models.Booking) error
+}
+
+type TouristRequestRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.TouristRequest, error)
+	CountByStatus(ctx context.Context, status string) (int64, error)
+	Create(ctx context.Context, request *models.TouristRequest) error
+	Update(ctx context.Context, request *models.TouristRequest) error
+}
+
+// AuditEventRepository only appends; events are never changed or deleted
+type AuditEventRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// List returns the newest events first, only those for email unless it is empty
+	List(ctx context.Context, email string, limit int) ([]models.AuditEvent, error)
+}
+
+type LoginCodeRepository interface {
+	Create(ctx context.Context, code *models.LoginCode) error
+	// Consume deletes the unexpired code with the given hash and returns it. A
+	// code can be consumed once: later calls, like calls for expired or unknown
+	// codes, return ErrNotFound.
+	Consume(ctx context.Context, codeHash string, now time.Time) (*models.LoginCode, error)
+	// DeleteByUser removes the user's codes that haven't been exchanged yet
+	DeleteByUser(ctx context.Context, userID uint) error
+	// DeleteExpired removes the codes that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type EmailTokenRepository interface {
+	Create(ctx context.Context, token *models.EmailToken) error
+	// Consume deletes the unexpired token with the given purpose and hash and
+	// returns it, once, like LoginCodeRepository.Consume
+	Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.EmailToken, error)
+	// DeleteByUser removes the user's tokens for purpose, so only a newer one works
+	DeleteByUser(ctx context.Context, userID uint, purpose string) error
+	// DeleteExpired removes the tokens that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type IdentityRepository interface {
+	FindBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	// ListByUser returns the user's identities, oldest link first
+	ListByUser(ctx context.Context, userID uint) ([]models.UserIdentity, error)
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	Delete(ctx context.Context, id uint) error
+}
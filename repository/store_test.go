@@ -0,0 +1,228 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/database"
+	"fiber-backend/models"
+	"fiber-backend/repository"
+	"testing"
+	"time"
+)
+
+// stores are the Store implementations every contract test runs against: the
+// in-memory one and GORM on migrated SQLite, as in production
+var stores = map[string]func(t *testing.T) repository.Store{
+	"memory": func(t *testing.T) repository.Store {
+		return repository.NewMemoryStore()
+	},
+	"sqlite": func(t *testing.T) repository.Store {
+		db, err := database.OpenWith(database.DriverSQLite, database.InMemoryDSN)
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		if _, err := database.MigrateUp(db); err != nil {
+			t.Fatalf("migrate sqlite: %v", err)
+		}
+		return repository.NewGormStore(db)
+	},
+}
+
+func TestStoreContract(t *testing.T) {
+	contract := map[string]func(t *testing.T, store repository.Store){
+		"lookups of missing rows are ErrNotFound": testNotFound,
+		"emails and provider subjects are unique": testDuplicates,
+		"stale versioned updates conflict":        testVersionConflict,
+		"failed transactions write nothing":       testTransactionRollback,
+		"user updates keep password and sessions": testUserUpdateKeepsCredentials,
+	}
+	for storeName, open := range stores {
+		for name, test := range contract {
+			t.Run(storeName+"/"+name, func(t *testing.T) {
+				test(t, open(t))
+			})
+		}
+	}
+}
+
+func createUser(t *testing.T, store repository.Store, email string) *models.User {
+	t.Helper()
+	user := &models.User{Email: email, Password: "hash", Name: "User " + email, Role: "driver"}
+	if err := store.Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return user
+}
+
+func createDriver(t *testing.T, store repository.Store, email string) *models.Driver {
+	t.Helper()
+	driver := &models.Driver{
+		UserID:        createUser(t, store, email).ID,
+		LicenseNumber: "LIC-" + email,
+		VehicleType:   "sedan",
+		VehicleModel:  "Corolla",
+		VehicleColor:  "white",
+		Languages:     "es",
+		Status:        "active",
+		IsAvailable:   true,
+	}
+	if err := store.Drivers().Create(context.Background(), driver); err != nil {
+		t.Fatalf("create driver: %v", err)
+	}
+	return driver
+}
+
+func testNotFound(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	lookups := map[string]func() error{
+		"user by id":      func() error { _, err := store.Users().FindByID(ctx, 999); return err },
+		"user by email":   func() error { _, err := store.Users().FindByEmail(ctx, "nobody@example.com"); return err },
+		"user password":   func() error { return store.Users().UpdatePassword(ctx, 999, "hash") },
+		"user sessions":   func() error { return store.Users().RevokeSessions(ctx, 999) },
+		"tourist by user": func() error { _, err := store.Tourists().FindByUserID(ctx, 999); return err },
+		"driver by id":    func() error { _, err := store.Drivers().FindByID(ctx, 999); return err },
+		"booking by id":   func() error { _, err := store.Bookings().FindByID(ctx, 999); return err },
+		"request by id":   func() error { _, err := store.TouristRequests().FindByID(ctx, 999); return err },
+		"identity":        func() error { _, err := store.Identities().FindBySubject(ctx, "google", "nobody"); return err },
+		"login code":      func() error { _, err := store.LoginCodes().Consume(ctx, "nope", time.Now()); return err },
+	}
+	for name, lookup := range lookups {
+		if err := lookup(); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("%s: got %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func testDuplicates(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	ana := createUser(t, store, "ana@example.com")
+	if err := store.Users().Create(ctx, &models.User{Email: "ana@example.com", Password: "hash"}); !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("second user with the email: got %v, want ErrDuplicate", err)
+	}
+
+	bob := createUser(t, store, "bob@example.com")
+	bob.Email = ana.Email
+	if err := store.Users().Update(ctx, bob); !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("changing to a taken email: got %v, want ErrDuplicate", err)
+	}
+	if stored, err := store.Users().FindByID(ctx, bob.ID); err != nil || stored.Email != "bob@example.com" {
+		t.Fatalf("the refused update was stored: %+v, %v", stored, err)
+	}
+
+	if err := store.Identities().Create(ctx, &models.UserIdentity{UserID: ana.ID, Provider: "google", Subject: "g-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Identities().Create(ctx, &models.UserIdentity{UserID: bob.ID, Provider: "google", Subject: "g-1"}); !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("second link of the provider subject: got %v, want ErrDuplicate", err)
+	}
+}
+
+func testVersionConflict(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	driver := createDriver(t, store, "dan@example.com")
+
+	first, err := store.Drivers().FindByID(ctx, driver.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Drivers().FindByID(ctx, driver.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := first.Version
+
+	first.Status = "suspended"
+	if err := store.Drivers().Update(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != read+1 {
+		t.Fatalf("version after the update is %d, want %d", first.Version, read+1)
+	}
+
+	second.IsAvailable = false
+	if err := store.Drivers().Update(ctx, second); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("stale update: got %v, want ErrVersionConflict", err)
+	}
+	if second.Version != read {
+		t.Fatalf("a refused update left the version at %d, want %d", second.Version, read)
+	}
+	stored, err := store.Drivers().FindByID(ctx, driver.ID)
+	if err != nil || stored.Status != "suspended" || !stored.IsAvailable || stored.Version != read+1 {
+		t.Fatalf("stored %+v, %v", stored, err)
+	}
+}
+
+func testTransactionRollback(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	driver := createDriver(t, store, "dan@example.com")
+	failure := errors.New("payment declined")
+
+	err := store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().Create(ctx, &models.User{Email: "ana@example.com", Password: "hash"}); err != nil {
+			return err
+		}
+		driver.IsAvailable = false
+		if err := tx.Drivers().Update(ctx, driver); err != nil {
+			return err
+		}
+		// Writes are visible inside the transaction
+		if _, err := tx.Users().FindByEmail(ctx, "ana@example.com"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want the error fn returned", err)
+	}
+	if _, err := store.Users().FindByEmail(ctx, "ana@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("the user created in the failed transaction exists: %v", err)
+	}
+	stored, err := store.Drivers().FindByID(ctx, driver.ID)
+	if err != nil || !stored.IsAvailable || stored.Version != 1 {
+		t.Fatalf("the driver update of the failed transaction was kept: %+v, %v", stored, err)
+	}
+
+	err = store.Transaction(ctx, func(tx repository.Store) error {
+		return tx.Users().Create(ctx, &models.User{Email: "bob@example.com", Password: "hash"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Users().FindByEmail(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("the committed user is missing: %v", err)
+	}
+}
+
+func testUserUpdateKeepsCredentials(t *testing.T, store repository.Store) {
+	ctx := context.Background()
+	user := createUser(t, store, "ana@example.com")
+	stale, err := store.Users().FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Users().UpdatePassword(ctx, user.ID, "new hash"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Users().RevokeSessions(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A writer holding the copy from before the password change saves it
+	stale.Name = "Ana"
+	if err := store.Users().Update(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.Users().FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Ana" || stored.Password != "new hash" || stored.SessionVersion != user.SessionVersion+1 {
+		t.Fatalf("got name %q, password %q, session version %d", stored.Name, stored.Password, stored.SessionVersion)
+	}
+}
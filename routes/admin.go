@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/dto"
+	"fiber-backend/i18n"
+	"fiber-backend/repository"
+	"fiber-backend/services"
+	"fiber-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupAdminRoutes registers operational endpoints reserved for admins
+func SetupAdminRoutes(app *fiber.App, authService *services.AuthService, protected fiber.Handler) {
+	admin := app.Group("/admin", protected, requireAdmin(authService))
+
+	admin.Get("/log-level", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"level": utils.LogLevel()})
+	})
+
+	// Change the log level without restarting, e.g. to debug a live issue
+	admin.Put("/log-level", func(c *fiber.Ctx) error {
+		var input struct {
+			Level string `json:"level"`
+		}
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
+		if err := utils.SetLogLevel(input.Level); err != nil {
+			return apperror.ValidationFailed.WithFields(map[string]string{"level": "validation.log_level"})
+		}
+
+		utils.LogInfoContext(c.UserContext(), "Log level changed to %s by user %d", utils.LogLevel(), c.Locals("userID").(uint))
+		return c.JSON(fiber.Map{"level": utils.LogLevel()})
+	})
+
+	// Recent security events, e.g. to see why an account was locked
+	admin.Get("/audit-events", func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 100)
+		if limit < 1 {
+			return apperror.ValidationFailed.WithFields(map[string]string{"limit": i18n.Key("validation.min", 1)})
+		}
+		if limit > 1000 {
+			return apperror.ValidationFailed.WithFields(map[string]string{"limit": i18n.Key("validation.max", 1000)})
+		}
+		events, err := authService.AuditEvents(c.UserContext(), c.Query("email"), limit)
+		if err != nil {
+			return err
+		}
+		return c.JSON(dto.ToAuditEventResponses(events))
+	})
+}
+
+func requireAdmin(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := authService.GetUser(c.UserContext(), c.Locals("userID").(uint))
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if user.Role != "admin" {
+			return apperror.AdminRequired
+		}
+		return c.Next()
+	}
+}
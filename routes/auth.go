@@ -1,379 +1,361 @@
 package routes
 
 import (
-	"fiber-backend/config"
-	"fiber-backend/database"
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/dto"
+	"fiber-backend/i18n"
 	"fiber-backend/middleware"
 	"fiber-backend/models"
+	"fiber-backend/ratelimit"
+	"fiber-backend/repository"
 	"fiber-backend/services"
 	"fiber-backend/utils"
-	"fmt"
 	"net/url"
-	"os"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func SetupAuthRoutes(app *fiber.App, authService *services.AuthService) {
-	utils.LogInfo("Setting up authentication routes")
+// signInCallbackTimeout covers the calls to the provider plus the account lookup
+const signInCallbackTimeout = 30 * time.Second
+
+// googleFlowCookie carries the sealed state of a Google sign-in to the callback
+const googleFlowCookie = "google_oauth_flow"
 
-	// Initialize OAuth config
-	config.InitOAuth()
+// SetupAuthRoutes registers /auth. Sign-ins may only redirect back to one of
+// redirectURLs; the first is the default.
+func SetupAuthRoutes(app *fiber.App, authService *services.AuthService, protected fiber.Handler, redirectURLs []string) {
+	utils.LogInfo("Setting up authentication routes")
 
 	// Auth routes
 	auth := app.Group("/auth")
 
 	// Register with tourist profile
 	auth.Post("/register", func(c *fiber.Ctx) error {
-		utils.LogInfo("Processing registration request")
-		var input struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-			Name     string `json:"name"`
-			Tourist  struct {
-				Nationality   string `json:"nationality"`
-				Language      string `json:"language"`
-				ArrivalDate   string `json:"arrival_date"`
-				DepartureDate string `json:"departure_date"`
-				Preferences   string `json:"preferences"`
-				SpecialNeeds  string `json:"special_needs"`
-			} `json:"tourist"`
-			Role string `json:"role"`
-		}
+		utils.LogInfoContext(c.UserContext(), "Processing registration request")
+		var input dto.RegisterRequest
 
-		if err := c.BodyParser(&input); err != nil {
-			utils.LogError("Failed to parse registration request: %v", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid request body",
-			})
+		if err := parseBody(c, &input); err != nil {
+			utils.LogErrorContext(c.UserContext(), "Failed to parse registration request: %v", err)
+			return err
 		}
 
-		utils.LogInfo("Registering new user - Email: %s, Name: %s", input.Email, input.Name)
-		utils.LogInfo("Raw password length: %d", len(input.Password))
-
-		// Start transaction
-		tx := database.DB.Begin()
+		utils.LogInfoContext(c.UserContext(), "Registering new user - Email: %s, Name: %s", input.Email, input.Name)
 
-		// Hash password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), 10)
-		if err != nil {
-			tx.Rollback()
-			utils.LogError("Failed to hash password: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not hash password",
-			})
-		}
-		utils.LogInfo("Password hashed successfully - Hash length: %d, Hash: %s", len(hashedPassword), string(hashedPassword))
-
-		// Create user
 		user := models.User{
-			Email:    input.Email,
-			Password: string(hashedPassword),
-			Name:     input.Name,
-			GoogleID: nil,
-			Role:     input.Role,
+			Email: input.Email,
+			Name:  input.Name,
+			Role:  input.Role,
 		}
 
-		if err := tx.Create(&user).Error; err != nil {
-			tx.Rollback()
-			utils.LogError("Failed to create user in database: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not create user",
-			})
-		}
-		utils.LogInfo("User created successfully in database - ID: %d", user.ID)
-
 		// If registering as a tourist, create tourist profile
+		var tourist *models.Tourist
 		if input.Role == "tourist" {
-			arrivalDate, err := time.Parse("2006-01-02", input.Tourist.ArrivalDate)
-			if err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": "Invalid arrival date format. Use YYYY-MM-DD",
-				})
-			}
-
-			departureDate, err := time.Parse("2006-01-02", input.Tourist.DepartureDate)
-			if err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": "Invalid departure date format. Use YYYY-MM-DD",
-				})
-			}
-
-			tourist := models.Tourist{
-				UserID:        user.ID,
-				Nationality:   input.Tourist.Nationality,
-				Language:      input.Tourist.Language,
-				ArrivalDate:   arrivalDate,
-				DepartureDate: departureDate,
-				Preferences:   input.Tourist.Preferences,
-				SpecialNeeds:  input.Tourist.SpecialNeeds,
-				Status:        "pending",
-			}
-
-			if err := tx.Create(&tourist).Error; err != nil {
-				tx.Rollback()
-				utils.LogError("Failed to create tourist profile: %v", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Could not create tourist profile",
-				})
-			}
+			profile := input.Tourist.ToModel()
+			tourist = &profile
+			// Tourists speak the language of their profile when we support it
+			user.Language = i18n.Supported(profile.Language)
 		}
 
-		// Commit transaction
-		if err := tx.Commit().Error; err != nil {
-			tx.Rollback()
-			utils.LogError("Failed to commit transaction: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to complete registration",
-			})
+		token, err := authService.Register(c.UserContext(), &user, input.Password, tourist)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return apperror.EmailAlreadyRegistered
 		}
-
-		// Generate token for immediate login
-		token, err := utils.GenerateToken(user)
 		if err != nil {
-			utils.LogError("Failed to generate token: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not generate token",
-			})
+			return err
 		}
 
-		utils.LogInfo("User registered successfully: %s", user.Email)
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"user": fiber.Map{
-				"id":    user.ID,
-				"email": user.Email,
-				"name":  user.Name,
-				"role":  user.Role,
-			},
-			"token": token,
+		utils.LogInfoContext(c.UserContext(), "User registered successfully: %s", user.Email)
+		return c.Status(fiber.StatusCreated).JSON(dto.AuthResponse{
+			Token: token,
+			User:  dto.ToUserResponse(&user),
 		})
 	})
 
 	// Update user role
-	auth.Post("/update-role", middleware.Protected(), func(c *fiber.Ctx) error {
+	auth.Post("/update-role", protected, func(c *fiber.Ctx) error {
 		// Get user ID from context (set by middleware)
 		userID := c.Locals("userID").(uint)
-		utils.LogInfo("Processing role update request for user ID: %d", userID)
+		utils.LogInfoContext(c.UserContext(), "Processing role update request for user ID: %d", userID)
 
 		// Parse request body
-		var body struct {
-			Role string `json:"role"`
-		}
-		if err := c.BodyParser(&body); err != nil {
-			utils.LogError("Failed to parse role update request: %v", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid request body",
-			})
+		var body dto.UpdateRoleRequest
+		if err := parseBody(c, &body); err != nil {
+			utils.LogErrorContext(c.UserContext(), "Failed to parse role update request: %v", err)
+			return err
 		}
 
-		utils.LogInfo("Requested role update for user %d to role: %s", userID, body.Role)
+		utils.LogInfoContext(c.UserContext(), "Requested role update for user %d to role: %s", userID, body.Role)
 
 		// Validate role
 		if body.Role != "tourist" && body.Role != "driver" {
-			utils.LogError("Invalid role requested: %s", body.Role)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid role. Must be 'tourist' or 'driver'",
-			})
+			utils.LogErrorContext(c.UserContext(), "Invalid role requested: %s", body.Role)
+			return apperror.InvalidRole
 		}
 
-		// Start transaction
-		tx := database.DB.Begin()
-
-		// Get current user
-		var user models.User
-		if err := tx.First(&user, userID).Error; err != nil {
-			tx.Rollback()
-			utils.LogError("Failed to find user %d: %v", userID, err)
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "User not found",
-			})
+		user, err := authService.UpdateRole(c.UserContext(), userID, body.Role)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
 		}
-
-		// Check if user already has a role
-		if user.Role != "" {
-			utils.LogInfo("User %d already has role: %s", userID, user.Role)
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "User already has a role assigned",
-				"role":  user.Role,
-			})
+		if errors.Is(err, services.ErrRoleAlreadyAssigned) {
+			return apperror.RoleAlreadyAssigned.WithCurrent(dto.ToUserResponse(user))
+		}
+		if err != nil {
+			return err
 		}
 
-		previousRole := user.Role
-		user.Role = body.Role
+		utils.LogInfoContext(c.UserContext(), "Successfully updated role for user %d to %s", userID, user.Role)
+		return c.JSON(dto.RoleUpdatedResponse{
+			Message: message(c, "messages.role_updated"),
+			User:    dto.ToUserResponse(user),
+		})
+	})
 
-		if err := tx.Save(&user).Error; err != nil {
-			tx.Rollback()
-			utils.LogError("Failed to update role for user %d from %s to %s: %v", userID, previousRole, body.Role, err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update user role",
-			})
+	// Choose the language of API messages
+	auth.Post("/update-language", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var body dto.UpdateLanguageRequest
+		if err := parseBody(c, &body); err != nil {
+			return err
 		}
 
-		// Commit transaction
-		if err := tx.Commit().Error; err != nil {
-			tx.Rollback()
-			utils.LogError("Failed to commit role update transaction: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update user role",
-			})
+		language := i18n.Supported(body.Language)
+		user, token, err := authService.UpdateLanguage(c.UserContext(), userID, language)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		identities, err := authService.Identities(c.UserContext(), userID)
+		if err != nil {
+			return err
 		}
 
-		utils.LogInfo("Successfully updated role for user %d from %s to %s", userID, previousRole, user.Role)
-		return c.JSON(fiber.Map{
-			"message": "Role updated successfully",
-			"user": fiber.Map{
-				"id":    user.ID,
-				"email": user.Email,
-				"name":  user.Name,
-				"role":  user.Role,
-			},
+		// Answer in the new language already
+		middleware.SetLanguage(c, language)
+		return c.JSON(dto.LanguageUpdatedResponse{
+			Message: message(c, "messages.language_updated"),
+			Token:   token,
+			User:    dto.ToMeResponse(user, identities),
 		})
 	})
 
 	// Login
 	auth.Post("/login", func(c *fiber.Ctx) error {
-		utils.LogInfo("Processing login request")
-
-		// Log CORS-related headers
-		utils.LogInfo("Request Headers:")
-		utils.LogInfo("- Origin: %s", c.Get("Origin"))
-		utils.LogInfo("- Access-Control-Request-Method: %s", c.Get("Access-Control-Request-Method"))
-		utils.LogInfo("- Access-Control-Request-Headers: %s", c.Get("Access-Control-Request-Headers"))
-
-		// Log response headers
-		utils.LogInfo("Response Headers:")
-		utils.LogInfo("- Access-Control-Allow-Origin: %s", c.Get("Access-Control-Allow-Origin"))
-		utils.LogInfo("- Access-Control-Allow-Methods: %s", c.Get("Access-Control-Allow-Methods"))
-		utils.LogInfo("- Access-Control-Allow-Headers: %s", c.Get("Access-Control-Allow-Headers"))
-		utils.LogInfo("- Access-Control-Allow-Credentials: %s", c.Get("Access-Control-Allow-Credentials"))
-
-		var input struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-		}
+		var input dto.LoginRequest
 
-		if err := c.BodyParser(&input); err != nil {
-			utils.LogError("Failed to parse login request: %v", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid request body",
-			})
+		if err := parseBody(c, &input); err != nil {
+			utils.LogErrorContext(c.UserContext(), "Failed to parse login request: %v", err)
+			return err
 		}
 
-		utils.LogInfo("Login attempt - Email: %s, Password length: %d", input.Email, len(input.Password))
+		utils.LogInfoContext(c.UserContext(), "Login attempt - Email: %s", input.Email)
 
-		var user models.User
-		if err := database.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-			utils.LogError("Login failed - User not found in database: %s", input.Email)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid credentials",
-			})
+		user, token, err := authService.Login(c.UserContext(), input.Email, input.Password, c.IP())
+		var locked *ratelimit.LockedError
+		if errors.As(err, &locked) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+			return apperror.AccountLocked
 		}
-
-		utils.LogInfo("User found in database - ID: %d, Email: %s", user.ID, user.Email)
-		utils.LogInfo("Stored password hash: %s", user.Password)
-		utils.LogInfo("Input password length: %d, Stored hash length: %d", len(input.Password), len(user.Password))
-
-		// Compare passwords
-		err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
-		if err != nil {
-			utils.LogError("Password comparison failed - Error: %v", err)
-			utils.LogError("Attempted to compare hash '%s' with password of length %d", user.Password, len(input.Password))
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid credentials",
-			})
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			return apperror.InvalidCredentials
 		}
-
-		utils.LogInfo("Password verified successfully")
-
-		// Create token
-		token, err := utils.GenerateToken(user)
 		if err != nil {
-			utils.LogError("Failed to generate token for user: %s", user.Email)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not generate token",
-			})
+			return err
 		}
 
-		// Set CORS headers explicitly for this response
-		c.Set("Access-Control-Allow-Origin", c.Get("Origin"))
-		c.Set("Access-Control-Allow-Credentials", "true")
-
-		utils.LogInfo("Login successful - User: %s, Token generated", user.Email)
-		utils.LogInfo("Final Response Headers:")
-		utils.LogInfo("- Access-Control-Allow-Origin: %s", c.Get("Access-Control-Allow-Origin"))
-		utils.LogInfo("- Access-Control-Allow-Credentials: %s", c.Get("Access-Control-Allow-Credentials"))
-
-		return c.JSON(fiber.Map{
-			"token": token,
-			"user": fiber.Map{
-				"id":    user.ID,
-				"email": user.Email,
-				"name":  user.Name,
-				"role":  user.Role,
-			},
+		utils.LogInfoContext(c.UserContext(), "Login successful - User: %s, Token generated", user.Email)
+
+		return c.JSON(dto.AuthResponse{
+			Token: token,
+			User:  dto.ToUserResponse(user),
 		})
 	})
 
 	// Google OAuth routes
+	// ?redirect_uri= picks the frontend page to return to and ?delivery= how the
+	// token gets there: a login code (the default) or an HttpOnly cookie
 	auth.Get("/google", func(c *fiber.Ctx) error {
-		clientID := os.Getenv("GOOGLE_CLIENT_ID")
-		redirectURI := os.Getenv("GOOGLE_REDIRECT_URI")
-		url := fmt.Sprintf(
-			"https://accounts.google.com/o/oauth2/v2/auth?client_id=%s&redirect_uri=%s&response_type=code&scope=email profile",
-			clientID,
-			redirectURI,
-		)
-		return c.Redirect(url)
+		options, err := signInOptions(c, redirectURLs)
+		if err != nil {
+			return err
+		}
+
+		authURL, flow, err := authService.GoogleAuthURL(options)
+		if errors.Is(err, services.ErrGoogleDisabled) {
+			return apperror.GoogleLoginDisabled
+		}
+		if err != nil {
+			return err
+		}
+		setGoogleFlowCookie(c, flow)
+		return c.Redirect(authURL)
 	})
 
-	auth.Get("/google/callback", func(c *fiber.Ctx) error {
+	// The callback waits on two calls to Google, so it gets longer than the default
+	auth.Get("/google/callback", middleware.Timeout(signInCallbackTimeout), func(c *fiber.Ctx) error {
 		code := c.Query("code")
 		if code == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Authorization code not provided",
-			})
+			return apperror.OAuthCodeMissing
 		}
 
-		user, token, err := authService.HandleGoogleAuth(code)
+		// A flow is good for one sign-in, whatever its outcome
+		flow := c.Cookies(googleFlowCookie)
+		setGoogleFlowCookie(c, "")
+
+		signIn, err := authService.HandleGoogleAuth(c.UserContext(), code, c.Query("state"), flow, c.IP())
+		if errors.Is(err, services.ErrGoogleDisabled) {
+			return apperror.GoogleLoginDisabled
+		}
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return signInError(err, apperror.GoogleLoginFailed)
+		}
+		return redirectSignedIn(c, signIn, authService.TokenTTL())
+	})
+
+	// The frontend trades the code from a sign-in redirect for a token, once
+	auth.Post("/exchange", func(c *fiber.Ctx) error {
+		var input dto.ExchangeCodeRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
 		}
 
-		// Redirect to frontend with token and user info
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:5173/success" // Default frontend URL now uses port 5173
+		user, token, err := authService.ExchangeLoginCode(c.UserContext(), input.Code)
+		if errors.Is(err, services.ErrLoginCodeInvalid) {
+			return apperror.LoginCodeInvalid
 		}
-		return c.Redirect(fmt.Sprintf("%s/success?token=%s&name=%s&email=%s",
-			frontendURL, token, url.QueryEscape(user.Name), url.QueryEscape(user.Email)))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(dto.AuthResponse{
+			Token: token,
+			User:  dto.ToUserResponse(user),
+		})
+	})
+
+	// Clears the token cookie of cookie-delivered sign-ins
+	auth.Post("/logout", func(c *fiber.Ctx) error {
+		setAccessTokenCookie(c, "", 0)
+		return c.SendStatus(fiber.StatusNoContent)
 	})
 
 	// Protected route example
-	auth.Get("/me", middleware.Protected(), func(c *fiber.Ctx) error {
+	auth.Get("/me", protected, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		var user models.User
-		if err := database.DB.First(&user, userID).Error; err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Usuario no encontrado",
-				"code":  "USER_NOT_FOUND",
-			})
+		user, err := authService.GetUser(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		identities, err := authService.Identities(c.UserContext(), userID)
+		if err != nil {
+			return err
 		}
 
-		return c.JSON(fiber.Map{
-			"id":         user.ID,
-			"name":       user.Name,
-			"email":      user.Email,
-			"google_id":  user.GoogleID,
-			"role":       user.Role,
-			"created_at": user.CreatedAt,
-		})
+		return c.JSON(dto.ToMeResponse(user, identities))
 	})
 }
+
+// signInOptions reads where a sign-in returns the user to, ?redirect_uri= from
+// the allowlist, and how the token gets there, ?delivery=
+func signInOptions(c *fiber.Ctx, redirectURLs []string) (services.SignInOptions, error) {
+	redirectURL := c.Query("redirect_uri", redirectURLs[0])
+	if !slices.Contains(redirectURLs, redirectURL) {
+		return services.SignInOptions{}, apperror.RedirectURINotAllowed
+	}
+	delivery := c.Query("delivery", services.DeliverCode)
+	if delivery != services.DeliverCode && delivery != services.DeliverCookie {
+		return services.SignInOptions{}, apperror.ValidationFailed.WithFields(map[string]string{
+			"delivery": i18n.Key("validation.one_of", services.DeliverCode+", "+services.DeliverCookie),
+		})
+	}
+	return services.SignInOptions{RedirectURL: redirectURL, Delivery: delivery}, nil
+}
+
+// signInError maps a failed sign-in callback to its API error, failed being
+// the one for anything the provider got wrong
+func signInError(err error, failed *apperror.AppError) error {
+	switch {
+	case errors.Is(err, services.ErrOAuthState):
+		return apperror.OAuthStateInvalid
+	case errors.Is(err, services.ErrIdentityEmailUnverified):
+		return apperror.IdentityEmailUnverified
+	case errors.Is(err, services.ErrAccountEmailUnverified):
+		return apperror.AccountEmailUnverified
+	case errors.Is(err, services.ErrIdentityLinkedElsewhere):
+		return apperror.IdentityLinkedElsewhere
+	}
+	return failed.Wrap(err)
+}
+
+// redirectSignedIn sends the user back to the frontend with the login code, or
+// with the token set in a cookie. The token never goes in the URL, where it
+// would end up in browser history, logs and Referer headers.
+func redirectSignedIn(c *fiber.Ctx, signIn *services.SignIn, tokenTTL time.Duration) error {
+	target, err := url.Parse(signIn.RedirectURL)
+	if err != nil {
+		return err
+	}
+	if signIn.Delivery == services.DeliverCookie {
+		setAccessTokenCookie(c, signIn.Token, tokenTTL)
+	} else {
+		query := target.Query()
+		query.Set("code", signIn.Code)
+		target.RawQuery = query.Encode()
+	}
+	return c.Redirect(target.String())
+}
+
+// setGoogleFlowCookie stores the flow of a Google sign-in, or clears it when
+// flow is empty. Lax lets the cookie come back on the redirect from Google while
+// keeping it off cross-site requests of any other kind.
+func setGoogleFlowCookie(c *fiber.Ctx, flow string) {
+	cookie := &fiber.Cookie{
+		Name:     googleFlowCookie,
+		Value:    flow,
+		Path:     "/auth/google",
+		MaxAge:   int(services.SignInFlowTTL.Seconds()),
+		Secure:   isHTTPS(c),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+	if flow == "" {
+		cookie.MaxAge = 0
+		cookie.Expires = time.Unix(0, 0)
+	}
+	c.Cookie(cookie)
+}
+
+// setAccessTokenCookie hands the token to the browser without exposing it to
+// scripts; an empty token clears the cookie. Lax keeps it off cross-site
+// requests that change anything.
+func setAccessTokenCookie(c *fiber.Ctx, token string, ttl time.Duration) {
+	cookie := &fiber.Cookie{
+		Name:     middleware.AccessTokenCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   isHTTPS(c),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+	if token == "" {
+		cookie.Expires = time.Unix(0, 0)
+	}
+	c.Cookie(cookie)
+}
+
+// isHTTPS reports whether the client reached the API over TLS, directly or
+// through a proxy that terminates it
+func isHTTPS(c *fiber.Ctx) bool {
+	return c.Protocol() == "https" || c.Get(fiber.HeaderXForwardedProto) == "https"
+}
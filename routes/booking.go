@@ -1,112 +1,147 @@
 package routes
 
 import (
-	"fiber-backend/models"
-	"time"
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/config"
+	"fiber-backend/dto"
+	"fiber-backend/repository"
+	"fiber-backend/services"
+	"fiber-backend/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
-func SetupBookingRoutes(app *fiber.App, db *gorm.DB) {
+// SetupBookingRoutes registers /api/bookings. Every route needs a signed-in
+// user, and a booking is only shown to or changed by its tourist and driver;
+// verified gates creating one like the tourist routes do.
+func SetupBookingRoutes(app *fiber.App, bookingService *services.BookingService, protected fiber.Handler, verified func(feature string) fiber.Handler) {
 	bookingGroup := app.Group("/api/bookings")
 
 	// Get all bookings for the authenticated tourist
-	bookingGroup.Get("/tourist", func(c *fiber.Ctx) error {
+	bookingGroup.Get("/tourist", protected, func(c *fiber.Ctx) error {
 		// Get user ID from the token
 		userID := c.Locals("userID").(uint)
 
-		// Get tourist ID from the user ID
-		var tourist models.Tourist
-		if err := db.Where("user_id = ?", userID).First(&tourist).Error; err != nil {
-			return c.Status(404).JSON(fiber.Map{
-				"error": "Tourist not found",
-			})
+		bookings, err := bookingService.GetTouristBookings(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.TouristNotFound
 		}
-
-		var bookings []models.Booking
-		// Preload the driver and tourist relationships to get all necessary data
-		if err := db.Preload("Driver.User").Preload("Tourist").Where("tourist_id = ?", tourist.ID).Find(&bookings).Error; err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to fetch bookings",
-			})
+		if err != nil {
+			return err
 		}
 
-		return c.JSON(bookings)
+		return c.JSON(dto.ToBookingResponses(bookings))
 	})
 
-	// Create a new booking
-	bookingGroup.Post("/", func(c *fiber.Ctx) error {
-		var booking models.Booking
-		if err := c.BodyParser(&booking); err != nil {
-			return c.Status(400).JSON(fiber.Map{
-				"error": "Invalid request body",
-			})
+	// Create a new booking for the authenticated tourist
+	bookingGroup.Post("/", protected, verified(config.FeatureBookings), func(c *fiber.Ctx) error {
+		var input dto.BookingCreateRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
 		}
 
-		// Set the booking time
-		booking.BookedAt = time.Now()
-		booking.Status = "pending"
+		booking := input.ToModel()
 
 		// Create the booking
-		if err := db.Create(&booking).Error; err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to create booking",
-			})
+		created, err := bookingService.CreateBooking(c.UserContext(), c.Locals("userID").(uint), &booking)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return apperror.TouristNotFound
+		case errors.Is(err, services.ErrUnknownDriver):
+			return apperror.ValidationFailed.WithFields(map[string]string{"driver_id": "validation.not_found"})
+		case err != nil:
+			return err
 		}
 
-		return c.Status(201).JSON(booking)
+		c.Set(fiber.HeaderETag, utils.VersionETag(created.Version))
+		return c.Status(201).JSON(dto.ToBookingResponse(created))
 	})
 
-	// Get all bookings for a driver
-	bookingGroup.Get("/driver/:id", func(c *fiber.Ctx) error {
-		driverID := c.Params("id")
-		var bookings []models.Booking
+	// Get all bookings for a driver, who must be the authenticated user
+	bookingGroup.Get("/driver/:id", protected, func(c *fiber.Ctx) error {
+		driverID, err := c.ParamsInt("id")
+		if err != nil || driverID <= 0 {
+			return apperror.InvalidID
+		}
 
-		if err := db.Preload("Driver").Preload("Tourist").Where("driver_id = ?", driverID).Find(&bookings).Error; err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to fetch bookings",
-			})
+		bookings, err := bookingService.GetDriverBookings(c.UserContext(), uint(driverID), c.Locals("userID").(uint))
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.DriverNotFound
+		}
+		if errors.Is(err, services.ErrNotBookingParty) {
+			return apperror.BookingAccessDenied
+		}
+		if err != nil {
+			return err
 		}
 
-		return c.JSON(bookings)
+		return c.JSON(dto.ToBookingResponses(bookings))
 	})
 
 	// Update booking status
-	bookingGroup.Patch("/:id/status", func(c *fiber.Ctx) error {
-		bookingID := c.Params("id")
-		var updateData struct {
-			Status string `json:"status"`
+	bookingGroup.Patch("/:id/status", protected, func(c *fiber.Ctx) error {
+		bookingID, err := c.ParamsInt("id")
+		if err != nil || bookingID <= 0 {
+			return apperror.BookingNotFound
+		}
+
+		var updateData dto.BookingStatusRequest
+
+		if err := parseBody(c, &updateData); err != nil {
+			return err
 		}
 
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(400).JSON(fiber.Map{
-				"error": "Invalid request body",
-			})
+		booking, err := bookingService.GetBookingForUser(c.UserContext(), uint(bookingID), c.Locals("userID").(uint))
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.BookingNotFound
+		}
+		if errors.Is(err, services.ErrNotBookingParty) {
+			return apperror.BookingAccessDenied
+		}
+		if err != nil {
+			return err
+		}
+		if !utils.IfMatchSatisfied(c, booking.Version) {
+			return utils.VersionConflict(c, booking.Version, dto.ToBookingResponse(booking))
 		}
 
-		if err := db.Model(&models.Booking{}).Where("id = ?", bookingID).Update("status", updateData.Status).Error; err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to update booking status",
-			})
+		updated, err := bookingService.UpdateStatus(c.UserContext(), booking, updateData.Status)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if current, err := bookingService.GetBooking(c.UserContext(), booking.ID); err == nil {
+				return utils.VersionConflict(c, current.Version, dto.ToBookingResponse(current))
+			}
+			return apperror.VersionConflict
+		}
+		if err != nil {
+			return err
 		}
 
+		c.Set(fiber.HeaderETag, utils.VersionETag(updated.Version))
 		return c.JSON(fiber.Map{
-			"message": "Booking status updated successfully",
+			"message": message(c, "messages.booking_status_updated"),
 		})
 	})
 
 	// Get booking details
-	bookingGroup.Get("/:id", func(c *fiber.Ctx) error {
-		bookingID := c.Params("id")
-		var booking models.Booking
+	bookingGroup.Get("/:id", protected, func(c *fiber.Ctx) error {
+		bookingID, err := c.ParamsInt("id")
+		if err != nil || bookingID <= 0 {
+			return apperror.BookingNotFound
+		}
 
-		if err := db.Preload("Driver").Preload("Tourist").First(&booking, bookingID).Error; err != nil {
-			return c.Status(404).JSON(fiber.Map{
-				"error": "Booking not found",
-			})
+		booking, err := bookingService.GetBookingForUser(c.UserContext(), uint(bookingID), c.Locals("userID").(uint))
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.BookingNotFound
+		}
+		if errors.Is(err, services.ErrNotBookingParty) {
+			return apperror.BookingAccessDenied
+		}
+		if err != nil {
+			return err
 		}
 
-		return c.JSON(booking)
+		c.Set(fiber.HeaderETag, utils.VersionETag(booking.Version))
+		return c.JSON(dto.ToBookingResponse(booking))
 	})
 }
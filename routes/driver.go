@@ -1,105 +1,180 @@
 package routes
 
 import (
-	"fiber-backend/middleware"
-	"fiber-backend/models"
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/config"
+	"fiber-backend/dto"
+	"fiber-backend/repository"
 	"fiber-backend/services"
-	"log"
+	"fiber-backend/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupDriverRoutes(app *fiber.App, driverService *services.DriverService) {
+// SetupDriverRoutes registers the driver profile routes; verified gates the ones
+// restricted to users with a verified email
+func SetupDriverRoutes(app *fiber.App, driverService *services.DriverService, protected fiber.Handler, verified func(feature string) fiber.Handler) {
 	driver := app.Group("/api/drivers")
 
 	// Get all drivers
 	driver.Get("/", func(c *fiber.Ctx) error {
-		drivers, err := driverService.GetAllDrivers()
+		drivers, err := driverService.GetAllDrivers(c.UserContext())
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al obtener los choferes",
-			})
+			return err
 		}
-		return c.JSON(drivers)
+		return c.JSON(dto.ToPublicProfiles(drivers))
 	})
 
 	// Create driver profile
-	driver.Post("/", middleware.Protected(), func(c *fiber.Ctx) error {
+	driver.Post("/", protected, verified(config.FeatureDriverProfile), func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		// Log raw request body
-		log.Println("Body crudo:", string(c.Body()))
-
-		var driver models.Driver
-		if err := c.BodyParser(&driver); err != nil {
-			log.Println("Error al parsear el body:", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Error al procesar los datos",
-			})
+		var input dto.DriverCreateRequest
+		if err := parseBody(c, &input); err != nil {
+			utils.LogErrorContext(c.UserContext(), "Error al parsear el body: %v", err)
+			return err
 		}
 
 		// Set the user ID from the authenticated user
-		driver.UserID = userID
+		driver := input.ToModel(userID)
 
 		// Create driver profile using service
-		if err := driverService.CreateDriver(&driver); err != nil {
-			log.Println("Error al crear el perfil de chofer:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al crear el perfil de chofer",
-			})
+		if err := driverService.CreateDriver(c.UserContext(), &driver); err != nil {
+			utils.LogErrorContext(c.UserContext(), "Error al crear el perfil de chofer: %v", err)
+			return err
 		}
 
-		return c.Status(fiber.StatusCreated).JSON(driver)
+		c.Set(fiber.HeaderETag, utils.VersionETag(driver.Version))
+		return c.Status(fiber.StatusCreated).JSON(dto.ToDriverResponse(&driver))
 	})
 
 	// Get driver profile
-	driver.Get("/me", middleware.Protected(), func(c *fiber.Ctx) error {
+	driver.Get("/me", protected, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		driver, err := driverService.GetDriverByUserID(userID)
+		driver, err := driverService.GetDriverByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.DriverNotFound
+		}
 		if err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Perfil de chofer no encontrado",
-			})
+			return err
 		}
 
-		return c.JSON(driver)
+		c.Set(fiber.HeaderETag, utils.VersionETag(driver.Version))
+		return c.JSON(dto.ToDriverResponse(driver))
 	})
 
 	// Get all available drivers
 	driver.Get("/available", func(c *fiber.Ctx) error {
-		drivers, err := driverService.GetAvailableDrivers()
+		drivers, err := driverService.GetAvailableDrivers(c.UserContext())
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al obtener los choferes disponibles",
-			})
+			return err
 		}
 
-		return c.JSON(drivers)
+		return c.JSON(dto.ToPublicProfiles(drivers))
+	})
+
+	// Update driver profile
+	driver.Patch("/me", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var update dto.DriverProfileUpdate
+		if err := parseBody(c, &update); err != nil {
+			return err
+		}
+
+		current, err := driverService.GetDriverByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.DriverNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !utils.IfMatchSatisfied(c, current.Version) {
+			return utils.VersionConflict(c, current.Version, dto.ToDriverResponse(current))
+		}
+
+		changes := services.DriverProfileChanges{
+			LicenseNumber: update.LicenseNumber,
+			VehicleType:   update.VehicleType,
+			VehicleModel:  update.VehicleModel,
+			VehicleColor:  update.VehicleColor,
+			Experience:    update.Experience,
+			PhotoURL:      update.PhotoURL,
+		}
+		if update.Languages != nil {
+			changes.Languages = dto.SplitLanguages(*update.Languages)
+		}
+		driver, err := driverService.UpdateDriverProfile(c.UserContext(), current, changes)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if current, err = driverService.GetDriverByUserID(c.UserContext(), userID); err == nil {
+				return utils.VersionConflict(c, current.Version, dto.ToDriverResponse(current))
+			}
+			return apperror.VersionConflict
+		}
+		if err != nil {
+			utils.LogErrorContext(c.UserContext(), "Error al actualizar el perfil de chofer: %v", err)
+			return err
+		}
+
+		c.Set(fiber.HeaderETag, utils.VersionETag(driver.Version))
+		return c.JSON(dto.ToDriverResponse(driver))
 	})
 
 	// Update driver availability
-	driver.Patch("/me/availability", middleware.Protected(), func(c *fiber.Ctx) error {
+	driver.Patch("/me/availability", protected, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		var updateData struct {
-			IsAvailable bool `json:"is_available"`
+		var updateData dto.DriverAvailabilityRequest
+
+		if err := parseBody(c, &updateData); err != nil {
+			return err
 		}
 
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Error al procesar los datos",
-			})
+		current, err := driverService.GetDriverByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.DriverNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !utils.IfMatchSatisfied(c, current.Version) {
+			return utils.VersionConflict(c, current.Version, dto.ToDriverResponse(current))
 		}
 
-		if err := driverService.UpdateDriverAvailability(userID, updateData.IsAvailable); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al actualizar la disponibilidad",
-			})
+		driver, err := driverService.UpdateDriverAvailability(c.UserContext(), current, *updateData.IsAvailable)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if current, err = driverService.GetDriverByUserID(c.UserContext(), userID); err == nil {
+				return utils.VersionConflict(c, current.Version, dto.ToDriverResponse(current))
+			}
+			return apperror.VersionConflict
+		}
+		if err != nil {
+			return err
 		}
 
+		c.Set(fiber.HeaderETag, utils.VersionETag(driver.Version))
 		return c.JSON(fiber.Map{
-			"message": "Disponibilidad actualizada exitosamente",
+			"message": message(c, "messages.availability_updated"),
 		})
 	})
+
+	// Get a driver's public profile
+	driver.Get("/:id<int>", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return apperror.InvalidID
+		}
+
+		driver, err := driverService.GetDriverByID(c.UserContext(), uint(id))
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.DriverNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(dto.ToPublicProfile(driver))
+	})
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fiber-backend/apperror"
+	"fiber-backend/i18n"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupErrorRoutes publishes the catalog of error codes clients may receive
+func SetupErrorRoutes(app *fiber.App) {
+	app.Get("/api/errors", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"errors": apperror.Catalog(i18n.FromContext(c.UserContext()))})
+	})
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/dto"
+	"fiber-backend/repository"
+	"fiber-backend/services"
+	"fiber-backend/utils"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupIdentityRoutes registers the routes signed-in users manage their ways
+// to sign in with: the provider accounts linked to them and a password
+func SetupIdentityRoutes(app *fiber.App, authService *services.AuthService, protected fiber.Handler, redirectURLs []string) {
+	utils.LogInfo("Setting up identity routes")
+
+	auth := app.Group("/auth")
+
+	auth.Get("/identities", protected, func(c *fiber.Ctx) error {
+		identities, err := authService.Identities(c.UserContext(), c.Locals("userID").(uint))
+		if err != nil {
+			return err
+		}
+		return c.JSON(dto.ToIdentityResponses(identities))
+	})
+
+	// Starts a sign-in with the provider that links the account to the user
+	// instead. It takes ?redirect_uri= and ?delivery= like /auth/google; the
+	// frontend sends the browser to the returned URL.
+	auth.Post("/identities/:provider", protected, func(c *fiber.Ctx) error {
+		provider := c.Params("provider")
+		options, err := signInOptions(c, redirectURLs)
+		if err != nil {
+			return err
+		}
+		options.LinkUserID = c.Locals("userID").(uint)
+
+		if provider == "google" {
+			authURL, flow, err := authService.GoogleAuthURL(options)
+			if errors.Is(err, services.ErrGoogleDisabled) {
+				return apperror.GoogleLoginDisabled
+			}
+			if err != nil {
+				return err
+			}
+			setGoogleFlowCookie(c, flow)
+			return c.JSON(dto.LinkStartedResponse{AuthURL: authURL})
+		}
+
+		// Known before the name goes into the cookie path
+		if !slices.Contains(authService.Providers(), provider) {
+			return apperror.OIDCProviderNotFound
+		}
+		authURL, flow, err := authService.OIDCAuthURL(c.UserContext(), provider, options)
+		if err != nil {
+			return apperror.OIDCLoginFailed.Wrap(err)
+		}
+		setOIDCFlowCookie(c, provider, flow)
+		return c.JSON(dto.LinkStartedResponse{AuthURL: authURL})
+	})
+
+	auth.Delete("/identities/:id", protected, func(c *fiber.Ctx) error {
+		identityID, err := c.ParamsInt("id")
+		if err != nil || identityID <= 0 {
+			return apperror.InvalidID
+		}
+
+		err = authService.UnlinkIdentity(c.UserContext(), c.Locals("userID").(uint), uint(identityID), c.IP())
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.IdentityNotFound
+		}
+		if errors.Is(err, services.ErrLastLoginMethod) {
+			return apperror.LastLoginMethod
+		}
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// Users who signed up through a provider set a password to sign in with
+	auth.Post("/password", protected, func(c *fiber.Ctx) error {
+		var input dto.SetPasswordRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
+
+		err := authService.SetPassword(c.UserContext(), c.Locals("userID").(uint), input.Password, c.IP())
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
+		}
+		if errors.Is(err, services.ErrPasswordAlreadySet) {
+			return apperror.PasswordAlreadySet
+		}
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"fiber-backend/apperror"
+	"fiber-backend/validate"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseBody decodes the request body into v and checks it against its
+// validate tags, reporting every invalid field at once
+func parseBody(c *fiber.Ctx, v interface{}) error {
+	if err := c.BodyParser(v); err != nil {
+		return apperror.InvalidRequestBody.Wrap(err)
+	}
+	if problems := validate.Struct(v); problems != nil {
+		return apperror.ValidationFailed.WithFields(problems)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"fiber-backend/i18n"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// message translates a success message into the language of the request
+func message(c *fiber.Ctx, key string) string {
+	return i18n.T(i18n.FromContext(c.UserContext()), key)
+}
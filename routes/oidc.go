@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/dto"
+	"fiber-backend/middleware"
+	"fiber-backend/services"
+	"fiber-backend/utils"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oidcFlowCookie carries the sealed state of an OIDC sign-in to the callback
+const oidcFlowCookie = "oidc_flow"
+
+// SetupOIDCRoutes registers the sign-in routes of the configured OpenID Connect
+// providers, /auth/oidc/<name>, and the list of every provider users can pick
+func SetupOIDCRoutes(app *fiber.App, authService *services.AuthService, redirectURLs []string) {
+	utils.LogInfo("Setting up OIDC sign-in routes for %v", authService.Providers())
+
+	auth := app.Group("/auth")
+
+	// The sign-in buttons the frontend shows
+	auth.Get("/providers", func(c *fiber.Ctx) error {
+		providers := []dto.ProviderResponse{}
+		if authService.GoogleEnabled() {
+			providers = append(providers, dto.ProviderResponse{Name: "google", AuthURL: "/auth/google"})
+		}
+		for _, name := range authService.Providers() {
+			providers = append(providers, dto.ProviderResponse{Name: name, AuthURL: "/auth/oidc/" + name})
+		}
+		return c.JSON(providers)
+	})
+
+	// Takes ?redirect_uri= and ?delivery= like /auth/google
+	auth.Get("/oidc/:provider", func(c *fiber.Ctx) error {
+		provider := c.Params("provider")
+		options, err := signInOptions(c, redirectURLs)
+		if err != nil {
+			return err
+		}
+
+		authURL, flow, err := authService.OIDCAuthURL(c.UserContext(), provider, options)
+		if errors.Is(err, services.ErrProviderNotFound) {
+			return apperror.OIDCProviderNotFound
+		}
+		if err != nil {
+			return apperror.OIDCLoginFailed.Wrap(err)
+		}
+		setOIDCFlowCookie(c, provider, flow)
+		return c.Redirect(authURL)
+	})
+
+	// Providers using response_mode=form_post post the callback instead
+	callback := func(c *fiber.Ctx) error {
+		// Known before the name goes into the cookie path
+		provider := c.Params("provider")
+		if !slices.Contains(authService.Providers(), provider) {
+			return apperror.OIDCProviderNotFound
+		}
+		code := c.FormValue("code")
+		if code == "" {
+			return apperror.OAuthCodeMissing
+		}
+
+		// A flow is good for one sign-in, whatever its outcome
+		flow := c.Cookies(oidcFlowCookie)
+		setOIDCFlowCookie(c, provider, "")
+
+		signIn, err := authService.HandleOIDCCallback(c.UserContext(), provider, code, c.FormValue("state"), flow, c.IP())
+		if err != nil {
+			return signInError(err, apperror.OIDCLoginFailed)
+		}
+		return redirectSignedIn(c, signIn, authService.TokenTTL())
+	}
+	auth.Get("/oidc/:provider/callback", middleware.Timeout(signInCallbackTimeout), callback)
+	auth.Post("/oidc/:provider/callback", middleware.Timeout(signInCallbackTimeout), callback)
+}
+
+// setOIDCFlowCookie stores the flow of a sign-in with provider, or clears it
+// when flow is empty. A form_post callback is a cross-site POST, which only
+// brings SameSite=None cookies along; the state in the flow is what ties the
+// callback to this browser, so the cookie doesn't need Lax's protection.
+// Browsers only accept None on secure cookies, so plain HTTP falls back to Lax.
+func setOIDCFlowCookie(c *fiber.Ctx, provider, flow string) {
+	cookie := &fiber.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    flow,
+		Path:     "/auth/oidc/" + provider,
+		MaxAge:   int(services.SignInFlowTTL.Seconds()),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+	if isHTTPS(c) {
+		cookie.Secure = true
+		cookie.SameSite = fiber.CookieSameSiteNoneMode
+	}
+	if flow == "" {
+		cookie.MaxAge = 0
+		cookie.Expires = time.Unix(0, 0)
+	}
+	c.Cookie(cookie)
+}
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/dto"
+	"fiber-backend/middleware"
+	"fiber-backend/ratelimit"
+	"fiber-backend/repository"
+	"fiber-backend/services"
+	"fiber-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupPasswordRoutes registers the routes users recover a forgotten password
+// and change their password with. Both sign the user out everywhere.
+func SetupPasswordRoutes(app *fiber.App, authService *services.AuthService, protected fiber.Handler) {
+	utils.LogInfo("Setting up password routes")
+
+	password := app.Group("/auth/password")
+
+	// Answers the same whether or not the address is registered
+	password.Post("/forgot", func(c *fiber.Ctx) error {
+		var input dto.ForgotPasswordRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
+
+		if err := authService.RequestPasswordReset(c.UserContext(), input.Email, c.IP()); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusAccepted).JSON(dto.MessageResponse{
+			Message: message(c, "messages.password_reset_requested"),
+		})
+	})
+
+	// The frontend page the emailed link opens posts its ?token= here with the
+	// new password
+	password.Post("/reset", func(c *fiber.Ctx) error {
+		var input dto.ResetPasswordRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
+
+		err := authService.ResetPassword(c.UserContext(), input.Token, input.Password, c.IP())
+		if errors.Is(err, services.ErrResetTokenInvalid) {
+			return apperror.ResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// The token in the response replaces the caller's, which stops working with
+	// every other one the user had
+	password.Post("/change", protected, func(c *fiber.Ctx) error {
+		var input dto.ChangePasswordRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
+
+		user, token, err := authService.ChangePassword(c.UserContext(), c.Locals("userID").(uint), input.CurrentPassword, input.NewPassword, c.IP())
+		var locked *ratelimit.LockedError
+		if errors.As(err, &locked) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+			return apperror.AccountLocked
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
+		}
+		if errors.Is(err, services.ErrNoPassword) {
+			return apperror.PasswordNotSet
+		}
+		if errors.Is(err, services.ErrPasswordIncorrect) {
+			return apperror.PasswordIncorrect
+		}
+		if err != nil {
+			return err
+		}
+
+		// Cookie sessions get the new token in their cookie
+		if c.Get(fiber.HeaderAuthorization) == "" && c.Cookies(middleware.AccessTokenCookie) != "" {
+			setAccessTokenCookie(c, token, authService.TokenTTL())
+		}
+		return c.JSON(dto.AuthResponse{
+			Token: token,
+			User:  dto.ToUserResponse(user),
+		})
+	})
+}
@@ -1,216 +1,180 @@
 package routes
 
 import (
-	"fiber-backend/database"
-	"fiber-backend/middleware"
-	"fiber-backend/models"
-	"time"
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/config"
+	"fiber-backend/dto"
+	"fiber-backend/repository"
+	"fiber-backend/services"
+	"fiber-backend/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupTouristRoutes(app *fiber.App) {
+// SetupTouristRoutes registers the tourist profile and booking routes;
+// verified gates the ones restricted to users with a verified email
+func SetupTouristRoutes(app *fiber.App, touristService *services.TouristService, protected fiber.Handler, verified func(feature string) fiber.Handler) {
 	tourist := app.Group("/api/tourists")
 
 	// Create tourist profile
-	tourist.Post("/", middleware.Protected(), func(c *fiber.Ctx) error {
+	tourist.Post("/", protected, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		var tourist models.Tourist
-		if err := c.BodyParser(&tourist); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Error al procesar los datos",
-			})
+		var input dto.TouristProfileRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
 		}
 
 		// Set the user ID from the authenticated user
-		tourist.UserID = userID
+		tourist := input.ToModel(userID)
 
 		// Create tourist profile
-		if err := database.DB.Create(&tourist).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al crear el perfil de turista",
-			})
+		if err := touristService.CreateProfile(c.UserContext(), &tourist); err != nil {
+			return err
 		}
 
-		return c.Status(fiber.StatusCreated).JSON(tourist)
+		c.Set(fiber.HeaderETag, utils.VersionETag(tourist.Version))
+		return c.Status(fiber.StatusCreated).JSON(dto.ToTouristResponse(&tourist))
 	})
 
 	// Get tourist profile
-	tourist.Get("/me", middleware.Protected(), func(c *fiber.Ctx) error {
+	tourist.Get("/me", protected, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		var tourist models.Tourist
-		if err := database.DB.Where("user_id = ?", userID).First(&tourist).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Perfil de turista no encontrado",
-			})
+		tourist, err := touristService.GetProfileByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.TouristNotFound
+		}
+		if err != nil {
+			return err
 		}
 
-		return c.JSON(tourist)
+		c.Set(fiber.HeaderETag, utils.VersionETag(tourist.Version))
+		return c.JSON(dto.ToTouristResponse(tourist))
 	})
 
-	// Update tourist profile
-	tourist.Put("/me", middleware.Protected(), func(c *fiber.Ctx) error {
+	// Update tourist profile (JSON Merge Patch). PUT is kept for older clients.
+	tourist.Patch("/me", protected, UpdateTouristProfile(touristService))
+	tourist.Put("/me", protected, UpdateTouristProfile(touristService))
+
+	// Book a driver
+	tourist.Post("/book-driver", protected, verified(config.FeatureBookings), func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
-		var tourist models.Tourist
-		if err := database.DB.Where("user_id = ?", userID).First(&tourist).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Perfil de turista no encontrado",
-			})
+		// Get the tourist profile
+		tourist, err := touristService.GetProfileByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.TouristNotFound
 		}
-
-		var updateData models.Tourist
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Error al procesar los datos",
-			})
+		if err != nil {
+			return err
 		}
 
-		// Update fields
-		tourist.Nationality = updateData.Nationality
-		tourist.Language = updateData.Language
-		tourist.ArrivalDate = updateData.ArrivalDate
-		tourist.DepartureDate = updateData.DepartureDate
-		tourist.Preferences = updateData.Preferences
-		tourist.SpecialNeeds = updateData.SpecialNeeds
+		// Parse request data
+		var requestData dto.BookDriverRequest
 
-		if err := database.DB.Save(&tourist).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al actualizar el perfil",
-			})
+		if err := parseBody(c, &requestData); err != nil {
+			return err
 		}
 
-		return c.JSON(tourist)
+		booking := requestData.ToModel()
+		booked, err := touristService.BookDriver(c.UserContext(), tourist, &booking)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return apperror.DriverNotFound
+		case errors.Is(err, services.ErrDriverUnavailable):
+			return apperror.DriverUnavailable
+		case errors.Is(err, repository.ErrVersionConflict):
+			return apperror.DriverBookedConcurrently
+		case err != nil:
+			return err
+		}
+
+		c.Set(fiber.HeaderETag, utils.VersionETag(booked.Version))
+		return c.Status(fiber.StatusCreated).JSON(dto.ToBookingResponse(booked))
 	})
 
-	// Book a driver
-	tourist.Post("/book-driver", middleware.Protected(), func(c *fiber.Ctx) error {
+	// Add the new route for requesting a driver
+	tourist.Post("/request", protected, verified(config.FeatureBookings), RequestDriver(touristService))
+}
+
+// RequestDriver handles the tourist's request for a driver
+func RequestDriver(touristService *services.TouristService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
 		// Get the tourist profile
-		var tourist models.Tourist
-		if err := database.DB.Where("user_id = ?", userID).First(&tourist).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Perfil de turista no encontrado",
-			})
+		tourist, err := touristService.GetProfileByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.TouristNotFound
+		}
+		if err != nil {
+			return err
 		}
 
 		// Parse request data
-		var requestData struct {
-			DriverID        uint   `json:"driverId"`
-			PickupLocation  string `json:"pickup_location"`
-			DropoffLocation string `json:"dropoff_location"`
-			DateTime        string `json:"date_time"`
-		}
+		var requestData dto.TouristRequestCreateRequest
 
-		if err := c.BodyParser(&requestData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Error al procesar los datos",
-			})
+		if err := parseBody(c, &requestData); err != nil {
+			return err
 		}
 
-		// Verify driver exists and is available
-		var driver models.Driver
-		if err := database.DB.First(&driver, requestData.DriverID).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Conductor no encontrado",
-			})
+		// Create the request
+		request := requestData.ToModel(tourist.ID)
+		if err := touristService.RequestDriver(c.UserContext(), &request); err != nil {
+			return err
 		}
 
-		if !driver.IsAvailable {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "El conductor no está disponible",
-			})
-		}
+		c.Set(fiber.HeaderETag, utils.VersionETag(request.Version))
+		return c.Status(fiber.StatusCreated).JSON(dto.TouristRequestCreatedResponse{
+			Message: message(c, "messages.request_sent"),
+			Request: dto.ToTouristRequestResponse(&request),
+		})
+	}
+}
 
-		// Create booking
-		booking := models.Booking{
-			TouristID:       tourist.ID,
-			DriverID:        driver.ID,
-			Status:          "pending",
-			BookedAt:        time.Now(),
-			PickupLocation:  requestData.PickupLocation,
-			DropoffLocation: requestData.DropoffLocation,
-			DateTime:        requestData.DateTime,
-		}
+// UpdateTouristProfile applies a JSON Merge Patch to the authenticated tourist's profile.
+// Clients send the ETag they last saw in If-Match so concurrent edits are not lost.
+func UpdateTouristProfile(touristService *services.TouristService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
 
-		if err := database.DB.Create(&booking).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al crear la reserva",
-			})
+		tourist, err := touristService.GetProfileByUserID(c.UserContext(), userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.TouristNotFound
 		}
-
-		// Update driver availability
-		driver.IsAvailable = false
-		if err := database.DB.Save(&driver).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al actualizar la disponibilidad del conductor",
-			})
+		if err != nil {
+			return err
 		}
 
-		// Load the driver information for the response
-		if err := database.DB.Preload("Driver").First(&booking, booking.ID).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Error al cargar los datos de la reserva",
-			})
+		if !utils.IfMatchSatisfied(c, tourist.Version) {
+			return utils.VersionConflict(c, tourist.Version, dto.ToTouristResponse(tourist))
 		}
 
-		return c.Status(fiber.StatusCreated).JSON(booking)
-	})
-
-	// Add the new route for requesting a driver
-	tourist.Post("/request", middleware.Protected(), RequestDriver)
-}
-
-// RequestDriver handles the tourist's request for a driver
-func RequestDriver(c *fiber.Ctx) error {
-	userID := c.Locals("userID").(uint)
-
-	// Get the tourist profile
-	var tourist models.Tourist
-	if err := database.DB.Where("user_id = ?", userID).First(&tourist).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Perfil de turista no encontrado",
-			"code":  "TOURIST_NOT_FOUND",
-		})
-	}
-
-	// Parse request data
-	var requestData struct {
-		PickupLocation  string `json:"pickup_location"`
-		DropoffLocation string `json:"dropoff_location"`
-		DateTime        string `json:"date_time"`
-		Notes           string `json:"notes"`
-	}
+		patch, err := dto.ParseTouristProfilePatch(c.Body())
+		if err != nil {
+			return apperror.InvalidRequestBody.Wrap(err)
+		}
 
-	if err := c.BodyParser(&requestData); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Error al procesar los datos",
-			"code":  "INVALID_REQUEST_DATA",
-		})
-	}
+		patched := *tourist
+		if fieldErrors := patch.Apply(&patched); len(fieldErrors) > 0 {
+			return apperror.ValidationFailed.WithFields(fieldErrors)
+		}
 
-	// Create the request
-	request := models.TouristRequest{
-		TouristID:       tourist.ID,
-		PickupLocation:  requestData.PickupLocation,
-		DropoffLocation: requestData.DropoffLocation,
-		DateTime:        requestData.DateTime,
-		Notes:           requestData.Notes,
-		Status:          "pending",
-	}
+		// Only written if nobody else updated the profile since we read it
+		err = touristService.UpdateProfile(c.UserContext(), &patched)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if current, err := touristService.GetProfileByUserID(c.UserContext(), userID); err == nil {
+				return utils.VersionConflict(c, current.Version, dto.ToTouristResponse(current))
+			}
+			return apperror.VersionConflict
+		}
+		if err != nil {
+			return err
+		}
 
-	if err := database.DB.Create(&request).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Error al crear la solicitud",
-			"code":  "DATABASE_ERROR",
-		})
+		c.Set(fiber.HeaderETag, utils.VersionETag(patched.Version))
+		return c.JSON(dto.ToTouristResponse(&patched))
 	}
-
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Solicitud enviada exitosamente",
-		"request": request,
-	})
 }
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/dto"
+	"fiber-backend/repository"
+	"fiber-backend/services"
+	"fiber-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupVerificationRoutes registers the routes users confirm their email
+// address with
+func SetupVerificationRoutes(app *fiber.App, authService *services.AuthService, protected fiber.Handler) {
+	utils.LogInfo("Setting up email verification routes")
+
+	auth := app.Group("/auth")
+
+	// The frontend page the emailed link opens posts its ?token= here
+	auth.Post("/verify-email", func(c *fiber.Ctx) error {
+		var input dto.VerifyEmailRequest
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
+
+		_, err := authService.VerifyEmail(c.UserContext(), input.Token, c.IP())
+		if errors.Is(err, services.ErrVerificationTokenInvalid) {
+			return apperror.VerificationTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	auth.Post("/verify-email/resend", protected, func(c *fiber.Ctx) error {
+		err := authService.ResendVerification(c.UserContext(), c.Locals("userID").(uint))
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.UserNotFound
+		}
+		if errors.Is(err, services.ErrEmailAlreadyVerified) {
+			return apperror.EmailAlreadyVerified
+		}
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
+
+// RequireVerifiedEmail returns a middleware for routes that go after protected:
+// it keeps users who haven't verified their email from feature when the config
+// restricts it
+func RequireVerifiedEmail(authService *services.AuthService) func(feature string) fiber.Handler {
+	return func(feature string) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			err := authService.CheckEmailVerified(c.UserContext(), c.Locals("userID").(uint), feature)
+			if errors.Is(err, services.ErrEmailUnverified) {
+				return apperror.EmailUnverified
+			}
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperror.UserNotFound
+			}
+			if err != nil {
+				return err
+			}
+			return c.Next()
+		}
+	}
+}
@@ -0,0 +1,1649 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/config"
+	"fiber-backend/i18n"
+	"fiber-backend/models"
+	"fiber-backend/oidc/oidctest"
+	"fiber-backend/repository"
+	"fiber-backend/server"
+	"fiber-backend/services"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A plain go test runs on the SQLite migrations, whose CHECK stands in for
+// the user_role enum of Postgres
+func TestHarnessRunsOnTheMigratedSchema(t *testing.T) {
+	if os.Getenv("TEST_DB_DRIVER") == testMemoryDriver {
+		t.Skip("the in-memory store has no schema")
+	}
+	h := newHarness(t)
+	ctx := context.Background()
+
+	if err := h.store.Users().Create(ctx, &models.User{Email: "pilot@example.com", Role: "pilot"}); err == nil {
+		t.Fatal("a role outside user_role was stored")
+	}
+	ana := h.registerTourist("ana@example.com")
+	if err := h.store.Users().Create(ctx, &models.User{Email: "ana@example.com"}); !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("a second user with the email: got %v, want ErrDuplicate", err)
+	}
+	if user, err := h.store.Users().FindByID(ctx, ana.User.ID); err != nil || user.Role != "tourist" {
+		t.Fatalf("registered user not stored: %+v, %v", user, err)
+	}
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	h := newHarness(t)
+
+	registered := h.registerTourist("ana@example.com")
+	if registered.Token == "" || registered.User.Role != "tourist" {
+		t.Fatalf("unexpected registration result: %+v", registered)
+	}
+
+	var login authResult
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+		"email":    "ana@example.com",
+		"password": "correct horse",
+	}}).expect(http.StatusOK).decode(&login)
+	if login.User.ID != registered.User.ID {
+		t.Fatalf("login returned user %d, registered %d", login.User.ID, registered.User.ID)
+	}
+
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+		"email":    "ana@example.com",
+		"password": "wrong",
+	}}).expect(http.StatusUnauthorized)
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+		"email":    "nobody@example.com",
+		"password": "correct horse",
+	}}).expect(http.StatusUnauthorized)
+
+	var me struct {
+		Email string `json:"email"`
+	}
+	h.send(call{method: "GET", path: "/auth/me", token: login.Token}).expect(http.StatusOK).decode(&me)
+	if me.Email != "ana@example.com" {
+		t.Fatalf("GET /auth/me returned %q", me.Email)
+	}
+
+	var profile struct {
+		Nationality string `json:"nationality"`
+	}
+	h.send(call{method: "GET", path: "/api/tourists/me", token: login.Token}).expect(http.StatusOK).decode(&profile)
+	if profile.Nationality != "CL" {
+		t.Fatalf("tourist profile was not created with the account: %+v", profile)
+	}
+}
+
+func TestLoginOnlyAllowsConfiguredOrigins(t *testing.T) {
+	h := newHarness(t)
+	h.registerTourist("ana@example.com")
+
+	login := func(origin string) *response {
+		return h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+			"email":    "ana@example.com",
+			"password": "correct horse",
+		}, headers: map[string]string{"Origin": origin}}).expect(http.StatusOK)
+	}
+
+	allowed := login("http://localhost:5173")
+	if got := allowed.header.Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+		t.Fatalf("allowed origin got Access-Control-Allow-Origin %q", got)
+	}
+	if got := allowed.header.Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Fatalf("allowed origin got Access-Control-Allow-Credentials %q", got)
+	}
+
+	other := login("https://evil.example.com")
+	if got := other.header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("unlisted origin got Access-Control-Allow-Origin %q", got)
+	}
+}
+
+func TestRegistrationRejectsBadDates(t *testing.T) {
+	h := newHarness(t)
+
+	register := func(arrival, departure string) apperror.Response {
+		return h.send(call{method: "POST", path: "/auth/register", body: map[string]interface{}{
+			"email":    "bad@example.com",
+			"password": "correct horse",
+			"name":     "Bad",
+			"role":     "tourist",
+			"tourist":  map[string]string{"nationality": "CL", "language": "es", "arrival_date": arrival, "departure_date": departure},
+		}}).expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	}
+
+	if body := register("01/07/2025", "2025-07-10"); len(body.Fields) != 1 || body.Fields["tourist.arrival_date"] == "" {
+		t.Fatalf("expected only the arrival date to be rejected, got %v", body.Fields)
+	}
+	if body := register("2025-07-10", "2025-07-01"); body.Fields["tourist.departure_date"] != "must be after arrival_date" {
+		t.Fatalf("expected the departure date to be rejected, got %v", body.Fields)
+	}
+
+	if _, err := h.store.Users().FindByEmail(context.Background(), "bad@example.com"); err == nil {
+		t.Fatal("user was created despite the invalid tourist profile")
+	}
+}
+
+func TestRoleUpdate(t *testing.T) {
+	h := newHarness(t)
+
+	var account authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]string{
+		"email":    "new@example.com",
+		"password": "correct horse",
+		"name":     "New",
+	}}).expect(http.StatusCreated).decode(&account)
+
+	h.send(call{method: "POST", path: "/auth/update-role", body: map[string]string{"role": "driver"}}).
+		expect(http.StatusUnauthorized)
+	h.send(call{method: "POST", path: "/auth/update-role", token: account.Token, body: map[string]string{"role": "admin"}}).
+		expect(http.StatusBadRequest)
+
+	var updated authResult
+	h.send(call{method: "POST", path: "/auth/update-role", token: account.Token, body: map[string]string{"role": "driver"}}).
+		expect(http.StatusOK).decode(&updated)
+	if updated.User.Role != "driver" {
+		t.Fatalf("role not updated: %+v", updated.User)
+	}
+
+	rejected := h.send(call{method: "POST", path: "/auth/update-role", token: account.Token, body: map[string]string{"role": "tourist"}}).
+		expectError(http.StatusBadRequest, "ROLE_ALREADY_ASSIGNED")
+	if current, _ := rejected.Current.(map[string]interface{}); current["role"] != "driver" {
+		t.Fatalf("expected the current role in the rejection, got %v", rejected.Current)
+	}
+}
+
+func TestGoogleOAuthLinksExistingAccountByEmail(t *testing.T) {
+	h := newHarness(t)
+	registered := h.registerTourist("ana@example.com")
+
+	h.google.authorize("code-ana", services.GoogleUserInfo{
+		ID:            "google-ana",
+		Email:         "ana@example.com",
+		VerifiedEmail: true,
+		Name:          "Ana",
+	})
+
+	res := h.googleLogin("code-ana").expect(http.StatusFound)
+	location, err := url.Parse(res.header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	if location.Host != "frontend.test" || location.Path != "/success" || location.Query().Get("code") == "" {
+		t.Fatalf("unexpected redirect %s", location)
+	}
+	var login authResult
+	h.send(call{method: "POST", path: "/auth/exchange", body: map[string]string{"code": location.Query().Get("code")}}).
+		expect(http.StatusOK).decode(&login)
+	if login.Token == "" || login.User.ID != registered.User.ID {
+		t.Fatalf("login code exchanged for %+v", login)
+	}
+
+	identity, err := h.store.Identities().FindBySubject(context.Background(), "google", "google-ana")
+	if err != nil || identity.UserID != registered.User.ID {
+		t.Fatalf("Google identity was not linked to the existing account: %+v, %v", identity, err)
+	}
+
+	// Signing in again finds the account by its Google ID
+	h.googleLogin("code-ana").expect(http.StatusFound)
+
+	// An unknown email creates a new account
+	h.google.authorize("code-bob", services.GoogleUserInfo{ID: "google-bob", Email: "bob@example.com", Name: "Bob"})
+	h.googleLogin("code-bob").expect(http.StatusFound)
+	if _, err := h.store.Identities().FindBySubject(context.Background(), "google", "google-bob"); err != nil {
+		t.Fatalf("new Google user was not created: %v", err)
+	}
+
+	// A code Google does not recognise is rejected
+	h.googleLogin("forged").expectError(http.StatusBadGateway, "GOOGLE_LOGIN_FAILED")
+	h.send(call{method: "GET", path: "/auth/google/callback"}).expectError(http.StatusBadRequest, "OAUTH_CODE_MISSING")
+}
+
+func TestBookingLifecycle(t *testing.T) {
+	h := newHarness(t)
+	tourist := h.registerTourist("ana@example.com")
+	driver, driverID := h.registerDriver("dan@example.com")
+
+	var booking struct {
+		ID     uint   `json:"id"`
+		Status string `json:"status"`
+		Driver struct {
+			ID          uint `json:"id"`
+			IsAvailable bool `json:"is_available"`
+		} `json:"driver"`
+	}
+	res := h.send(call{method: "POST", path: "/api/tourists/book-driver", token: tourist.Token, body: map[string]interface{}{
+		"driverId":         driverID,
+		"pickup_location":  "Airport",
+		"dropoff_location": "Hotel",
+		"date_time":        "2025-07-01T10:00",
+	}}).expect(http.StatusCreated)
+	res.decode(&booking)
+	if booking.Status != "pending" || booking.Driver.ID != driverID || booking.Driver.IsAvailable {
+		t.Fatalf("unexpected booking: %+v", booking)
+	}
+	if etag := res.header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %q", etag)
+	}
+
+	// The driver is taken until the booking is done
+	h.send(call{method: "POST", path: "/api/tourists/book-driver", token: tourist.Token, body: map[string]interface{}{
+		"driverId":         driverID,
+		"pickup_location":  "Hotel",
+		"dropoff_location": "Museum",
+		"date_time":        "2025-07-02T09:00",
+	}}).expectError(http.StatusBadRequest, "DRIVER_UNAVAILABLE")
+
+	var touristBookings []struct {
+		ID uint `json:"id"`
+	}
+	h.send(call{method: "GET", path: "/api/bookings/tourist", token: tourist.Token}).
+		expect(http.StatusOK).decode(&touristBookings)
+	if len(touristBookings) != 1 || touristBookings[0].ID != booking.ID {
+		t.Fatalf("unexpected tourist bookings: %+v", touristBookings)
+	}
+
+	statusPath := "/api/bookings/" + strconv.Itoa(int(booking.ID)) + "/status"
+	// If-Match compares strongly, so a weak tag for the same version doesn't match
+	h.send(call{method: "PATCH", path: statusPath, token: driver.Token, headers: map[string]string{"If-Match": `W/"1"`},
+		body: map[string]string{"status": "confirmed"}}).expect(http.StatusConflict)
+	res = h.send(call{method: "PATCH", path: statusPath, token: driver.Token, headers: map[string]string{"If-Match": `"1"`},
+		body: map[string]string{"status": "confirmed"}}).expect(http.StatusOK)
+	if etag := res.header.Get("ETag"); etag != `"2"` {
+		t.Fatalf("expected ETag \"2\" after the update, got %q", etag)
+	}
+
+	// A second client still holding version 1 gets the current state back
+	var conflict struct {
+		Current struct {
+			Status string `json:"status"`
+		} `json:"current"`
+	}
+	h.send(call{method: "PATCH", path: statusPath, token: tourist.Token, headers: map[string]string{"If-Match": `"1"`},
+		body: map[string]string{"status": "cancelled"}}).expect(http.StatusConflict).decode(&conflict)
+	if conflict.Current.Status != "confirmed" {
+		t.Fatalf("conflict should carry the current status, got %q", conflict.Current.Status)
+	}
+
+	h.send(call{method: "PATCH", path: statusPath, token: driver.Token, body: map[string]string{"status": "completed"}}).expect(http.StatusOK)
+
+	var driverBookings []struct {
+		Status string `json:"status"`
+	}
+	h.send(call{method: "GET", path: "/api/bookings/driver/" + strconv.Itoa(int(driverID)), token: driver.Token}).
+		expect(http.StatusOK).decode(&driverBookings)
+	if len(driverBookings) != 1 || driverBookings[0].Status != "completed" {
+		t.Fatalf("unexpected driver bookings: %+v", driverBookings)
+	}
+}
+
+func TestCreateBookingEndpoint(t *testing.T) {
+	h := newHarness(t)
+	tourist := h.registerTourist("ana@example.com")
+	_, driverID := h.registerDriver("dan@example.com")
+
+	var profile struct {
+		ID uint `json:"id"`
+	}
+	h.send(call{method: "GET", path: "/api/tourists/me", token: tourist.Token}).expect(http.StatusOK).decode(&profile)
+
+	// The booking is for the caller's tourist profile, whatever the body says
+	create := call{method: "POST", path: "/api/bookings/", token: tourist.Token, body: map[string]interface{}{
+		"tourist_id":       profile.ID + 1,
+		"driver_id":        driverID,
+		"pickup_location":  "Port",
+		"dropoff_location": "Old town",
+		"date_time":        "2025-07-03T08:30",
+	}}
+	var booking struct {
+		ID      uint   `json:"id"`
+		Status  string `json:"status"`
+		Tourist struct {
+			ID uint `json:"id"`
+		} `json:"tourist"`
+	}
+	h.send(create).expect(http.StatusCreated).decode(&booking)
+	if booking.Status != "pending" || booking.Tourist.ID != profile.ID {
+		t.Fatalf("new bookings should be pending and the caller's, got %+v", booking)
+	}
+	anonymous := create
+	anonymous.token = ""
+	h.send(anonymous).expectError(http.StatusUnauthorized, "AUTH_HEADER_MISSING")
+
+	bookingPath := "/api/bookings/" + strconv.Itoa(int(booking.ID))
+	h.send(call{method: "GET", path: bookingPath, token: tourist.Token}).expect(http.StatusOK)
+	h.send(call{method: "GET", path: "/api/bookings/9999", token: tourist.Token}).expect(http.StatusNotFound)
+
+	// Nobody but the tourist and the driver of the booking gets to see or change it
+	stranger := h.registerTourist("eve@example.com")
+	h.send(call{method: "GET", path: bookingPath}).expectError(http.StatusUnauthorized, "AUTH_HEADER_MISSING")
+	h.send(call{method: "GET", path: bookingPath, token: stranger.Token}).expectError(http.StatusForbidden, "BOOKING_ACCESS_DENIED")
+	h.send(call{method: "PATCH", path: bookingPath + "/status", token: stranger.Token, body: map[string]string{"status": "cancelled"}}).
+		expectError(http.StatusForbidden, "BOOKING_ACCESS_DENIED")
+	h.send(call{method: "GET", path: "/api/bookings/driver/" + strconv.Itoa(int(driverID)), token: stranger.Token}).
+		expectError(http.StatusForbidden, "BOOKING_ACCESS_DENIED")
+}
+
+func TestTouristProfilePatchesMergeIntoTheStoredProfile(t *testing.T) {
+	h := newHarness(t)
+	tourist := h.registerTourist("ana@example.com")
+	type profile struct {
+		Nationality   string    `json:"nationality"`
+		ArrivalDate   time.Time `json:"arrival_date"`
+		DepartureDate time.Time `json:"departure_date"`
+		Preferences   string    `json:"preferences"`
+		SpecialNeeds  string    `json:"special_needs"`
+	}
+	patch := func(body map[string]interface{}) *response {
+		return h.send(call{method: "PATCH", path: "/api/tourists/me", token: tourist.Token, body: body})
+	}
+
+	var got profile
+	patch(map[string]interface{}{"preferences": "quiet car", "special_needs": "child seat"}).expect(http.StatusOK).decode(&got)
+
+	// Members left out of the patch keep their stored values
+	patch(map[string]interface{}{"nationality": "AR"}).expect(http.StatusOK).decode(&got)
+	want := profile{
+		Nationality:   "AR",
+		ArrivalDate:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		DepartureDate: time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC),
+		Preferences:   "quiet car",
+		SpecialNeeds:  "child seat",
+	}
+	if !got.ArrivalDate.Equal(want.ArrivalDate) || !got.DepartureDate.Equal(want.DepartureDate) {
+		t.Fatalf("omitted dates changed: %+v", got)
+	}
+	got.ArrivalDate, got.DepartureDate = want.ArrivalDate, want.DepartureDate
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// Departure must follow arrival, whichever of them the patch carries
+	for name, body := range map[string]map[string]interface{}{
+		"departure before the stored arrival": {"departure_date": "2025-06-30"},
+		"arrival after the stored departure":  {"arrival_date": "2025-07-15"},
+		"both dates out of order":             {"arrival_date": "2025-08-02", "departure_date": "2025-08-01"},
+	} {
+		res := patch(body).expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+		if want := map[string]string{"departure_date": "debe ser posterior a arrival_date"}; !reflect.DeepEqual(res.Fields, want) {
+			t.Errorf("%s: got fields %v, want %v", name, res.Fields, want)
+		}
+	}
+	patch(map[string]interface{}{"departure_date": "2025-07-20"}).expect(http.StatusOK).decode(&got)
+	if !got.DepartureDate.Equal(time.Date(2025, 7, 20, 0, 0, 0, 0, time.UTC)) || !got.ArrivalDate.Equal(want.ArrivalDate) {
+		t.Fatalf("moving the departure alone: %+v", got)
+	}
+
+	// null clears an optional member
+	patch(map[string]interface{}{"preferences": nil}).expect(http.StatusOK).decode(&got)
+	if got.Preferences != "" || got.SpecialNeeds != "child seat" {
+		t.Fatalf("null should clear only preferences: %+v", got)
+	}
+}
+
+func TestStaleTouristProfileEditsConflict(t *testing.T) {
+	h := newHarness(t)
+	tourist := h.registerTourist("ana@example.com")
+
+	res := h.send(call{method: "GET", path: "/api/tourists/me", token: tourist.Token}).expect(http.StatusOK)
+	seen := res.header.Get("ETag")
+	res = h.send(call{method: "PATCH", path: "/api/tourists/me", token: tourist.Token, headers: map[string]string{"If-Match": seen},
+		body: map[string]string{"nationality": "AR"}}).expect(http.StatusOK)
+	if res.header.Get("ETag") == seen {
+		t.Fatalf("the update should change the ETag from %s", seen)
+	}
+
+	// Another tab still holding the first version gets the current profile back
+	var conflict struct {
+		Code    string `json:"code"`
+		Current struct {
+			Nationality string `json:"nationality"`
+		} `json:"current"`
+	}
+	res = h.send(call{method: "PATCH", path: "/api/tourists/me", token: tourist.Token, headers: map[string]string{"If-Match": seen},
+		body: map[string]string{"nationality": "PE"}})
+	res.expect(http.StatusConflict).decode(&conflict)
+	if conflict.Code != "VERSION_CONFLICT" || conflict.Current.Nationality != "AR" {
+		t.Fatalf("conflict should carry the current profile, got %+v", conflict)
+	}
+	if res.header.Get("ETag") == seen {
+		t.Fatal("the conflict should report the current ETag")
+	}
+}
+
+func TestStaleDriverEditsConflict(t *testing.T) {
+	h := newHarness(t)
+	driver, driverID := h.registerDriver("dan@example.com")
+	ctx := context.Background()
+
+	seen := h.send(call{method: "GET", path: "/api/drivers/me", token: driver.Token}).expect(http.StatusOK).header.Get("ETag")
+
+	// An admin suspends the driver while their app still shows the old profile
+	suspended, err := h.store.Drivers().FindByID(ctx, driverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suspended.Status = "suspended"
+	if err := h.store.Drivers().Update(ctx, suspended); err != nil {
+		t.Fatal(err)
+	}
+
+	var conflict struct {
+		Current struct {
+			Status      string `json:"status"`
+			IsAvailable bool   `json:"is_available"`
+		} `json:"current"`
+	}
+	for _, stale := range []call{
+		{method: "PATCH", path: "/api/drivers/me/availability", body: map[string]bool{"is_available": false}},
+		{method: "PATCH", path: "/api/drivers/me", body: map[string]string{"vehicle_color": "black"}},
+	} {
+		stale.token, stale.headers = driver.Token, map[string]string{"If-Match": seen}
+		res := h.send(stale)
+		res.expectError(http.StatusConflict, "VERSION_CONFLICT")
+		res.decode(&conflict)
+		if conflict.Current.Status != "suspended" || !conflict.Current.IsAvailable || res.header.Get("ETag") == seen {
+			t.Fatalf("%s %s: conflict should carry the admin's version, got %+v and ETag %s", stale.method, stale.path, conflict, res.header.Get("ETag"))
+		}
+	}
+
+	// Nothing the stale requests sent was written
+	stored, err := h.store.Drivers().FindByID(ctx, driverID)
+	if err != nil || stored.Status != "suspended" || !stored.IsAvailable || stored.VehicleColor != "white" {
+		t.Fatalf("stale writes changed the driver: %+v, %v", stored, err)
+	}
+
+	// With the current ETag the driver goes off duty
+	current := h.send(call{method: "GET", path: "/api/drivers/me", token: driver.Token}).expect(http.StatusOK).header.Get("ETag")
+	h.send(call{method: "PATCH", path: "/api/drivers/me/availability", token: driver.Token, headers: map[string]string{"If-Match": current},
+		body: map[string]bool{"is_available": false}}).expect(http.StatusOK)
+	if stored, err := h.store.Drivers().FindByID(ctx, driverID); err != nil || stored.IsAvailable || stored.Status != "suspended" {
+		t.Fatalf("availability not updated: %+v, %v", stored, err)
+	}
+}
+
+// Two copies read at the same version: the store takes the first write and
+// refuses the second, whichever model it is
+func TestStaleWritesLoseToNewerOnes(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	tourist := h.registerTourist("ana@example.com")
+	_, driverID := h.registerDriver("dan@example.com")
+	var created struct {
+		Request struct {
+			ID uint `json:"id"`
+		} `json:"request"`
+	}
+	h.send(call{method: "POST", path: "/api/tourists/request", token: tourist.Token, body: map[string]string{
+		"pickup_location": "Airport", "dropoff_location": "Hotel", "date_time": "2025-07-01T10:00",
+	}}).expect(http.StatusCreated).decode(&created)
+
+	t.Run("driver", func(t *testing.T) {
+		first, _ := h.store.Drivers().FindByID(ctx, driverID)
+		second, _ := h.store.Drivers().FindByID(ctx, driverID)
+		first.Status, second.IsAvailable = "suspended", false
+		if err := h.store.Drivers().Update(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.store.Drivers().Update(ctx, second); !errors.Is(err, repository.ErrVersionConflict) {
+			t.Fatalf("got %v, want ErrVersionConflict", err)
+		}
+		if stored, _ := h.store.Drivers().FindByID(ctx, driverID); stored.Status != "suspended" || !stored.IsAvailable || stored.Version != first.Version {
+			t.Fatalf("stored %+v", stored)
+		}
+	})
+	t.Run("tourist", func(t *testing.T) {
+		first, _ := h.store.Tourists().FindByUserID(ctx, tourist.User.ID)
+		second, _ := h.store.Tourists().FindByUserID(ctx, tourist.User.ID)
+		first.Nationality, second.Preferences = "AR", "quiet car"
+		if err := h.store.Tourists().Update(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.store.Tourists().Update(ctx, second); !errors.Is(err, repository.ErrVersionConflict) {
+			t.Fatalf("got %v, want ErrVersionConflict", err)
+		}
+		if stored, _ := h.store.Tourists().FindByUserID(ctx, tourist.User.ID); stored.Nationality != "AR" || stored.Preferences != "" {
+			t.Fatalf("stored %+v", stored)
+		}
+	})
+	t.Run("tourist request", func(t *testing.T) {
+		first, _ := h.store.TouristRequests().FindByID(ctx, created.Request.ID)
+		second, _ := h.store.TouristRequests().FindByID(ctx, created.Request.ID)
+		first.Status, second.Status = "accepted", "rejected"
+		if err := h.store.TouristRequests().Update(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.store.TouristRequests().Update(ctx, second); !errors.Is(err, repository.ErrVersionConflict) {
+			t.Fatalf("got %v, want ErrVersionConflict", err)
+		}
+		if stored, _ := h.store.TouristRequests().FindByID(ctx, created.Request.ID); stored.Status != "accepted" {
+			t.Fatalf("stored %+v", stored)
+		}
+	})
+}
+
+func TestDriversEditTheirProfile(t *testing.T) {
+	h := newHarness(t)
+	driver, _ := h.registerDriver("dan@example.com")
+	var profile struct {
+		LicenseNumber string   `json:"license_number"`
+		VehicleColor  string   `json:"vehicle_color"`
+		Languages     []string `json:"languages"`
+		Status        string   `json:"status"`
+	}
+	update := func(body map[string]interface{}) *response {
+		return h.send(call{method: "PATCH", path: "/api/drivers/me", token: driver.Token, body: body})
+	}
+
+	// Omitted fields are kept, and resending the same license keeps the status
+	update(map[string]interface{}{"license_number": " LIC-dan@example.com ", "vehicle_color": "black", "languages": "es, pt"}).
+		expect(http.StatusOK).decode(&profile)
+	if profile.Status != "active" || profile.VehicleColor != "black" || profile.LicenseNumber != "LIC-dan@example.com" ||
+		!reflect.DeepEqual(profile.Languages, []string{"es", "pt"}) {
+		t.Fatalf("unexpected profile after the update: %+v", profile)
+	}
+
+	body := update(map[string]interface{}{"license_number": " ", "experience": -1, "photo_url": "ftp://example.com/me.png"}).
+		expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	if want := map[string]string{"license_number": "cannot be empty", "experience": "must be at least 0", "photo_url": "must be an http(s) URL"}; !reflect.DeepEqual(body.Fields, want) {
+		t.Fatalf("got fields %v, want %v", body.Fields, want)
+	}
+
+	// A new license has to be verified again
+	update(map[string]interface{}{"license_number": "LIC-NEW"}).expect(http.StatusOK).decode(&profile)
+	if profile.Status != "pending" || profile.LicenseNumber != "LIC-NEW" {
+		t.Fatalf("a license change should send the profile back to pending: %+v", profile)
+	}
+	h.send(call{method: "GET", path: "/api/drivers/me", token: driver.Token}).expect(http.StatusOK).decode(&profile)
+	if profile.Status != "pending" || profile.VehicleColor != "black" {
+		t.Fatalf("the update was not stored: %+v", profile)
+	}
+}
+
+func TestDriverPublicProfilesShowOnlyWhatTouristsNeed(t *testing.T) {
+	h := newHarness(t)
+	_, driverID := h.registerDriver("dan@example.com")
+
+	var profile map[string]interface{}
+	h.send(call{method: "GET", path: "/api/drivers/" + strconv.Itoa(int(driverID))}).expect(http.StatusOK).decode(&profile)
+	keys := func(object map[string]interface{}) []string {
+		var names []string
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	if got, want := keys(profile), []string{"id", "is_available", "languages", "name", "photo_url", "rating", "review_count", "vehicle"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("public profile has keys %v, want %v", got, want)
+	}
+	vehicle, _ := profile["vehicle"].(map[string]interface{})
+	if got, want := keys(vehicle), []string{"color", "model", "type"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("vehicle has keys %v, want %v", got, want)
+	}
+	if profile["name"] != "Driver dan@example.com" || vehicle["model"] != "Corolla" ||
+		!reflect.DeepEqual(profile["languages"], []interface{}{"es", "en"}) {
+		t.Fatalf("unexpected public profile %v", profile)
+	}
+
+	h.send(call{method: "GET", path: "/api/drivers/" + strconv.Itoa(int(driverID+1))}).expectError(http.StatusNotFound, "DRIVER_NOT_FOUND")
+}
+
+func TestRequestIDIsEchoedOrGenerated(t *testing.T) {
+	h := newHarness(t)
+
+	res := h.send(call{method: "GET", path: "/api/drivers/", headers: map[string]string{"X-Request-ID": "trace-123"}}).expect(http.StatusOK)
+	if got := res.header.Get("X-Request-ID"); got != "trace-123" {
+		t.Fatalf("inbound request ID was not echoed, got %q", got)
+	}
+
+	res = h.send(call{method: "GET", path: "/api/drivers/", headers: map[string]string{"X-Request-ID": "bad id\n"}}).expect(http.StatusOK)
+	if got := res.header.Get("X-Request-ID"); got == "" || got == "bad id\n" {
+		t.Fatalf("invalid request ID should be replaced, got %q", got)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	h := newHarness(t)
+	tourist := h.registerTourist("ana@example.com")
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+		"email":    "ana@example.com",
+		"password": "wrong",
+	}}).expect(http.StatusUnauthorized)
+	h.send(call{method: "POST", path: "/api/tourists/request", token: tourist.Token, body: map[string]string{
+		"pickup_location":  "Hotel",
+		"dropoff_location": "Airport",
+		"date_time":        "2025-07-02T10:00:00Z",
+	}}).expect(http.StatusCreated)
+	h.send(call{method: "GET", path: "/api/bookings/42", token: tourist.Token}).expect(http.StatusNotFound)
+
+	body := string(h.send(call{method: "GET", path: "/metrics"}).expect(http.StatusOK).body)
+	for _, want := range []string{
+		`fiber_backend_http_requests_total{method="POST",route="/auth/register",status="201"}`,
+		`fiber_backend_http_request_duration_seconds_bucket{method="GET",route="/api/bookings/:id",status="404"`,
+		`fiber_backend_logins_total{method="password",result="failure"}`,
+		`fiber_backend_tourist_requests_pending 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	h := newHarness(t)
+
+	h.send(call{method: "GET", path: "/healthz"}).expect(http.StatusOK)
+	var ready struct {
+		Status string `json:"status"`
+	}
+	h.send(call{method: "GET", path: "/readyz"}).expect(http.StatusOK).decode(&ready)
+	if ready.Status != "ready" {
+		t.Fatalf("unexpected readiness %+v", ready)
+	}
+
+	res := h.send(call{method: "GET", path: "/healthz"})
+	if res.header.Get("X-Request-ID") != "" {
+		t.Fatal("probes should bypass the request middleware")
+	}
+}
+
+// slowStore makes driver listings wait until the request's deadline passes
+type slowStore struct {
+	repository.Store
+}
+
+func (s slowStore) Drivers() repository.DriverRepository {
+	return slowDrivers{s.Store.Drivers()}
+}
+
+type slowDrivers struct {
+	repository.DriverRepository
+}
+
+func (slowDrivers) List(ctx context.Context) ([]models.Driver, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSlowQueriesTimeOut(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.RequestTimeout = config.Duration(50 * time.Millisecond)
+		opts.Store = slowStore{opts.Store}
+	})
+
+	start := time.Now()
+	res := h.send(call{method: "GET", path: "/api/drivers/"})
+	res.expectError(http.StatusServiceUnavailable, "REQUEST_TIMEOUT")
+	if res.header.Get("Retry-After") == "" {
+		t.Fatalf("timeouts should tell clients when to retry, headers %v", res.header)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request ran for %v despite a 50ms deadline", elapsed)
+	}
+
+	// Queries that finish in time are unaffected
+	h.send(call{method: "GET", path: "/api/drivers/available"}).expect(http.StatusOK)
+}
+
+func TestErrorsShareOneShapeAndAreCatalogued(t *testing.T) {
+	h := newHarness(t)
+	account := h.registerTourist("ana@example.com")
+
+	failures := []struct {
+		call   call
+		status int
+		code   string
+	}{
+		{call{method: "GET", path: "/api/nothing-here"}, http.StatusNotFound, "ROUTE_NOT_FOUND"},
+		{call{method: "GET", path: "/auth/me"}, http.StatusUnauthorized, "AUTH_HEADER_MISSING"},
+		{call{method: "GET", path: "/auth/me", token: "not-a-jwt"}, http.StatusUnauthorized, "TOKEN_INVALID"},
+		{call{method: "GET", path: "/api/drivers/me", token: account.Token}, http.StatusNotFound, "DRIVER_NOT_FOUND"},
+		{call{method: "GET", path: "/api/bookings/999", token: account.Token}, http.StatusNotFound, "BOOKING_NOT_FOUND"},
+		{call{method: "POST", path: "/auth/login", body: map[string]string{"email": "ana@example.com", "password": "nope"}}, http.StatusUnauthorized, "INVALID_CREDENTIALS"},
+		{call{method: "POST", path: "/auth/register", body: map[string]string{"email": "ana@example.com", "password": "another horse", "name": "Ana", "role": "driver"}}, http.StatusConflict, "EMAIL_ALREADY_REGISTERED"},
+		{call{method: "PATCH", path: "/api/tourists/me", token: account.Token, body: map[string]int{"nationality": 7}}, http.StatusUnprocessableEntity, "VALIDATION_FAILED"},
+	}
+
+	var catalog struct {
+		Errors []apperror.CatalogEntry `json:"errors"`
+	}
+	h.send(call{method: "GET", path: "/api/errors"}).expect(http.StatusOK).decode(&catalog)
+	published := map[string]apperror.CatalogEntry{}
+	for _, entry := range catalog.Errors {
+		published[entry.Code] = entry
+	}
+
+	for _, f := range failures {
+		res := h.send(f.call)
+		body := res.expectError(f.status, f.code)
+		entry, ok := published[body.Code]
+		if !ok {
+			t.Errorf("%s is not in the published catalog", body.Code)
+			continue
+		}
+		if entry.Status != body.Status || entry.MessageKey != body.MessageKey {
+			t.Errorf("%s response %+v does not match its catalog entry %+v", body.Code, body, entry)
+		}
+		// The tourist registered with Spanish as their language, so their errors are in Spanish
+		if want := i18n.T(res.header.Get("Content-Language"), body.MessageKey); body.Error != want {
+			t.Errorf("%s response says %q, want %q", body.Code, body.Error, want)
+		}
+	}
+}
+
+func TestMessagesFollowTheClientLanguage(t *testing.T) {
+	h := newHarness(t)
+
+	// Anonymous requests negotiate from Accept-Language
+	res := h.send(call{method: "GET", path: "/api/drivers/999", headers: map[string]string{"Accept-Language": "de-DE, pt-BR;q=0.8, en;q=0.5"}})
+	if body := res.expectError(http.StatusNotFound, "DRIVER_NOT_FOUND"); body.Error != "Motorista não encontrado" {
+		t.Fatalf("expected a Portuguese message, got %q", body.Error)
+	}
+	if lang := res.header.Get("Content-Language"); lang != "pt" {
+		t.Fatalf("Content-Language = %q, want pt", lang)
+	}
+	res = h.send(call{method: "GET", path: "/api/drivers/999", headers: map[string]string{"Accept-Language": "ja"}})
+	if body := res.expectError(http.StatusNotFound, "DRIVER_NOT_FOUND"); body.Error != "Driver not found" {
+		t.Fatalf("unsupported languages should get English, got %q", body.Error)
+	}
+
+	// Validation problems are translated field by field
+	account := h.registerTourist("ana@example.com")
+	body := h.send(call{method: "PATCH", path: "/api/tourists/me", token: account.Token, body: map[string]interface{}{"nationality": 7, "status": "done"}}).
+		expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	if body.Fields["nationality"] != "debe ser texto" || body.Fields["status"] != "no se puede modificar" {
+		t.Fatalf("expected Spanish field errors, got %v", body.Fields)
+	}
+
+	// The stored language wins over Accept-Language, and can be changed
+	var me struct {
+		Language string `json:"language"`
+	}
+	h.send(call{method: "GET", path: "/auth/me", token: account.Token, headers: map[string]string{"Accept-Language": "fr"}}).
+		expect(http.StatusOK).decode(&me)
+	if me.Language != "es" {
+		t.Fatalf("tourists should keep the language of their profile, got %q", me.Language)
+	}
+
+	h.send(call{method: "POST", path: "/auth/update-language", token: account.Token, body: map[string]string{"language": "tlh"}}).
+		expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+
+	var updated struct {
+		Message string `json:"message"`
+		Token   string `json:"token"`
+	}
+	h.send(call{method: "POST", path: "/auth/update-language", token: account.Token, body: map[string]string{"language": "fr-CA"}}).
+		expect(http.StatusOK).decode(&updated)
+	if updated.Message != "Langue mise à jour" {
+		t.Fatalf("the confirmation should use the new language, got %q", updated.Message)
+	}
+	body = h.send(call{method: "GET", path: "/api/drivers/me", token: updated.Token, headers: map[string]string{"Accept-Language": "es"}}).
+		expectError(http.StatusNotFound, "DRIVER_NOT_FOUND")
+	if body.Error != "Chauffeur introuvable" {
+		t.Fatalf("expected the stored French to win over Accept-Language, got %q", body.Error)
+	}
+}
+
+func TestInputsAreValidatedFieldByField(t *testing.T) {
+	h := newHarness(t)
+	tourist := h.registerTourist("ana@example.com")
+	driver, driverID := h.registerDriver("dan@example.com")
+
+	cases := []struct {
+		name   string
+		call   call
+		fields map[string]string
+	}{
+		{
+			"empty registration",
+			call{method: "POST", path: "/auth/register", body: map[string]string{"email": "", "password": ""}},
+			map[string]string{"email": "is required", "password": "is required", "name": "is required"},
+		},
+		{
+			"malformed registration",
+			call{method: "POST", path: "/auth/register", body: map[string]string{"email": "ana@", "password": "password", "name": "Ana", "role": "admin"}},
+			map[string]string{
+				"email":    "must be a valid email address",
+				"password": "must be 8 to 72 characters long and mix letters with digits or symbols",
+				"role":     "must be one of: tourist, driver",
+			},
+		},
+		{
+			"tourist without a profile",
+			call{method: "POST", path: "/auth/register", body: map[string]string{"email": "tom@example.com", "password": "correct horse", "name": "Tom", "role": "tourist"}},
+			map[string]string{"tourist": "is required"},
+		},
+		{
+			"empty driver request",
+			call{method: "POST", path: "/api/tourists/request", token: tourist.Token,
+				body: map[string]string{"pickup_location": " ", "dropoff_location": "Hotel", "date_time": "tomorrow"}},
+			// Requests with the tourist's token are answered in Spanish, the language they registered with
+			map[string]string{"pickup_location": "es obligatorio", "date_time": "debe tener formato AAAA-MM-DDTHH:MM"},
+		},
+		{
+			"booking with nobody",
+			call{method: "POST", path: "/api/bookings/", token: tourist.Token, body: map[string]interface{}{
+				"driver_id": driverID + 1, "pickup_location": "Port", "dropoff_location": "Hotel", "date_time": "2025-07-03T08:30", "status": "completed",
+			}},
+			map[string]string{"driver_id": "no existe"},
+		},
+		{
+			"driver profile out of range",
+			call{method: "POST", path: "/api/drivers/", token: tourist.Token, body: map[string]interface{}{
+				"license_number": "LIC-1", "vehicle_type": "sedan", "vehicle_model": "Corolla", "vehicle_color": "white",
+				"languages": "es", "experience": 90, "photo_url": "ftp://example.com/me.png",
+			}},
+			map[string]string{"experience": "no puede ser mayor que 70", "photo_url": "debe ser una URL http(s)"},
+		},
+		{
+			"availability without a value",
+			call{method: "PATCH", path: "/api/drivers/me/availability", token: tourist.Token, body: map[string]string{}},
+			map[string]string{"is_available": "es obligatorio"},
+		},
+	}
+
+	for _, tc := range cases {
+		body := h.send(tc.call).expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+		if !reflect.DeepEqual(body.Fields, tc.fields) {
+			t.Errorf("%s: got fields %v, want %v", tc.name, body.Fields, tc.fields)
+		}
+	}
+
+	// Booking statuses are limited to the known ones
+	var booking struct {
+		ID uint `json:"id"`
+	}
+	h.send(call{method: "POST", path: "/api/tourists/book-driver", token: tourist.Token, body: map[string]interface{}{
+		"driverId": driverID, "pickup_location": "Airport", "dropoff_location": "Hotel", "date_time": "2025-07-01T10:00",
+	}}).expect(http.StatusCreated).decode(&booking)
+	body := h.send(call{method: "PATCH", path: "/api/bookings/" + strconv.Itoa(int(booking.ID)) + "/status", token: driver.Token, body: map[string]string{"status": "paid"}}).
+		expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	if body.Fields["status"] != "must be one of: pending, confirmed, completed, cancelled" {
+		t.Fatalf("unexpected status problem %v", body.Fields)
+	}
+}
+
+func TestAuthEndpointsAreRateLimited(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.RateLimit.Auth = config.Limit{Requests: 5, Window: config.Duration(time.Minute)}
+		opts.Config.RateLimit.Account = config.Limit{Requests: 2, Window: config.Duration(time.Minute)}
+	})
+	login := func(email string) *response {
+		return h.send(call{method: "POST", path: "/auth/login", body: map[string]string{"email": email, "password": "wrong password 1"}})
+	}
+
+	// Each account gets its own budget, whatever the casing of the address
+	res := login("ana@example.com").expect(http.StatusUnauthorized)
+	if res.header.Get("X-RateLimit-Limit") == "" || res.header.Get("X-RateLimit-Remaining") == "" {
+		t.Fatalf("rate limited responses should report the budget, headers %v", res.header)
+	}
+	login("ANA@example.com").expect(http.StatusUnauthorized)
+	res = login("ana@example.com")
+	res.expectError(http.StatusTooManyRequests, "RATE_LIMITED")
+	if res.header.Get("Retry-After") == "" {
+		t.Fatalf("429s should tell clients when to retry, headers %v", res.header)
+	}
+	login("bob@example.com").expect(http.StatusUnauthorized)
+
+	// Every address shares the client's budget for /auth
+	login("eve@example.com").expect(http.StatusUnauthorized)
+	login("max@example.com").expectError(http.StatusTooManyRequests, "RATE_LIMITED")
+	h.send(call{method: "GET", path: "/auth/google"}).expectError(http.StatusTooManyRequests, "RATE_LIMITED")
+
+	// Other route groups have budgets of their own
+	h.send(call{method: "GET", path: "/api/drivers/available"}).expect(http.StatusOK)
+}
+
+func TestRateLimitsCanBeDisabled(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.RateLimit.Enabled = false
+		opts.Config.RateLimit.Auth = config.Limit{Requests: 1, Window: config.Duration(time.Minute)}
+		opts.Config.RateLimit.Lockout.MaxFailures = 1
+	})
+	for i := 0; i < 3; i++ {
+		res := h.send(call{method: "POST", path: "/auth/login", body: map[string]string{"email": "ana@example.com", "password": "wrong password 1"}})
+		res.expectError(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		if res.header.Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("disabled rate limits should not be reported, headers %v", res.header)
+		}
+	}
+}
+
+func TestRateLimitsKeyOnTheAddressTrustedProxiesForward(t *testing.T) {
+	behind := func(trusted string) *harness {
+		return newHarness(t, func(opts *server.Options) {
+			opts.Config.RateLimit.Auth = config.Limit{Requests: 1, Window: config.Duration(time.Minute)}
+			opts.Config.Proxy = config.ProxyConfig{Header: "X-Real-IP", TrustedProxies: []string{trusted}}
+		})
+	}
+	login := func(h *harness, client string) *response {
+		return h.send(call{method: "POST", path: "/auth/login", headers: map[string]string{"X-Real-IP": client},
+			body: map[string]string{"email": "ana@example.com", "password": "wrong password 1"}})
+	}
+
+	// Requests come from 0.0.0.0 in tests; each client the proxy names gets its own budget
+	h := behind("0.0.0.0/8")
+	login(h, "203.0.113.7").expect(http.StatusUnauthorized)
+	login(h, "203.0.113.7").expectError(http.StatusTooManyRequests, "RATE_LIMITED")
+	login(h, "198.51.100.4").expect(http.StatusUnauthorized)
+
+	// From anywhere else the header is ignored, so it can't buy a fresh budget
+	h = behind("10.0.0.0/8")
+	login(h, "203.0.113.7").expect(http.StatusUnauthorized)
+	login(h, "198.51.100.4").expectError(http.StatusTooManyRequests, "RATE_LIMITED")
+}
+
+func TestRepeatedFailedLoginsLockTheAccount(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.RateLimit.Lockout = config.LockoutConfig{
+			MaxFailures: 3,
+			Window:      config.Duration(15 * time.Minute),
+			Duration:    config.Duration(time.Minute),
+			MaxDuration: config.Duration(time.Hour),
+		}
+	})
+	h.registerTourist("ana@example.com")
+	admin := h.registerTourist("root@example.com")
+	login := func(email, password string) *response {
+		return h.send(call{method: "POST", path: "/auth/login", body: map[string]string{"email": email, "password": password}})
+	}
+
+	login("ana@example.com", "wrong password 1").expectError(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+	login("ana@example.com", "wrong password 2").expectError(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+	res := login("ana@example.com", "wrong password 3")
+	res.expectError(http.StatusTooManyRequests, "ACCOUNT_LOCKED")
+	if retry, _ := strconv.Atoi(res.header.Get("Retry-After")); retry < 1 || retry > 60 {
+		t.Fatalf("Retry-After should cover the one minute lock, got %q", res.header.Get("Retry-After"))
+	}
+
+	// Even the right password is refused until the lock is over, and other
+	// accounts are unaffected
+	login("ana@example.com", "correct horse").expectError(http.StatusTooManyRequests, "ACCOUNT_LOCKED")
+	login("root@example.com", "correct horse").expect(http.StatusOK)
+
+	events, err := h.store.AuditEvents().List(context.Background(), "ana@example.com", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, event := range events {
+		got = append(got, event.Event)
+		if event.IP == "" {
+			t.Errorf("audit event %s should record the client IP: %+v", event.Event, event)
+		}
+		// Blocked attempts are refused before the user is looked up
+		if event.Event != models.AuditLoginBlocked && event.UserID == nil {
+			t.Errorf("audit event %s should record the user: %+v", event.Event, event)
+		}
+	}
+	want := []string{models.AuditLoginBlocked, models.AuditAccountLocked, models.AuditLoginFailed, models.AuditLoginFailed, models.AuditLoginFailed, models.AuditEmailVerified}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit trail %v, want %v", got, want)
+	}
+
+	// Admins can read the trail over the API
+	user, err := h.store.Users().FindByEmail(context.Background(), "root@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Role = "admin"
+	if err := h.store.Users().Update(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	var trail []struct {
+		Event string `json:"event"`
+		Email string `json:"email"`
+	}
+	h.send(call{method: "GET", path: "/admin/audit-events?email=ANA@example.com&limit=2", token: admin.Token}).
+		expect(http.StatusOK).decode(&trail)
+	if len(trail) != 2 || trail[0].Event != models.AuditLoginBlocked || trail[0].Email != "ana@example.com" {
+		t.Fatalf("unexpected audit events %+v", trail)
+	}
+	h.send(call{method: "GET", path: "/admin/audit-events?limit=0", token: admin.Token}).
+		expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+}
+
+func TestProviderSignInsDoNotClaimUnverifiedAccounts(t *testing.T) {
+	h := newHarness(t)
+	// Anyone can register an address they don't own and wait for its owner
+	var registered authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]string{
+		"email":    "ana@example.com",
+		"password": "correct horse",
+		"name":     "Ana",
+		"role":     "driver",
+	}}).expect(http.StatusCreated).decode(&registered)
+
+	h.google.authorize("code-ana", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", VerifiedEmail: true, Name: "Ana"})
+	h.googleLogin("code-ana").expectError(http.StatusConflict, "ACCOUNT_EMAIL_UNVERIFIED")
+	if _, err := h.store.Identities().FindBySubject(context.Background(), "google", "google-ana"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("the unverified account was linked: %v", err)
+	}
+
+	// Whoever can sign in to the account links the provider from it instead
+	var started map[string]string
+	res := h.send(call{method: "POST", path: "/auth/identities/google", token: registered.Token}).expect(http.StatusOK)
+	res.decode(&started)
+	cookie, _, _ := strings.Cut(res.header.Get("Set-Cookie"), ";")
+	authURL, err := url.Parse(started["auth_url"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.google.issue("code-ana", authURL.Query().Get("code_challenge"))
+	if linked := h.exchangeLogin(h.send(googleCallback("code-ana", authURL.Query().Get("state"), cookie))); linked.User.ID != registered.User.ID {
+		t.Fatalf("linking signed in user %d, want %d", linked.User.ID, registered.User.ID)
+	}
+	if again := h.exchangeLogin(h.googleLogin("code-ana")); again.User.ID != registered.User.ID {
+		t.Fatalf("signing in after linking got user %d, want %d", again.User.ID, registered.User.ID)
+	}
+}
+
+func TestGoogleSignInIsBoundToTheBrowserThatStartedIt(t *testing.T) {
+	h := newHarness(t)
+	h.google.authorize("code-ana", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", Name: "Ana"})
+
+	query, cookie := h.startGoogleLogin(nil)
+	if query.Get("state") == "" || query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "test-client" {
+		t.Fatalf("authorize URL lacks state or PKCE: %v", query)
+	}
+	if !strings.HasPrefix(cookie, "google_oauth_flow=") {
+		t.Fatalf("the flow should be kept in a cookie, got %q", cookie)
+	}
+	h.google.issue("code-ana", query.Get("code_challenge"))
+	state := query.Get("state")
+
+	// An attacker's callback link lacks the victim's cookie, or carries a state
+	// from another sign-in
+	other, otherCookie := h.startGoogleLogin(nil)
+	for name, callback := range map[string]call{
+		"no cookie":       googleCallback("code-ana", state, ""),
+		"no state":        googleCallback("code-ana", "", cookie),
+		"other state":     googleCallback("code-ana", other.Get("state"), cookie),
+		"other cookie":    googleCallback("code-ana", state, otherCookie),
+		"forged cookie":   googleCallback("code-ana", state, "google_oauth_flow=forged"),
+		"cookie as state": googleCallback("code-ana", strings.TrimPrefix(cookie, "google_oauth_flow="), cookie),
+	} {
+		if res := h.send(callback); res.status != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", name, res.status)
+		} else {
+			res.expectError(http.StatusBadRequest, "OAUTH_STATE_INVALID")
+		}
+	}
+	if _, err := h.store.Identities().FindBySubject(context.Background(), "google", "google-ana"); err == nil {
+		t.Fatal("a rejected callback created the account")
+	}
+
+	// The browser that started the sign-in completes it, and the cookie is cleared
+	res := h.send(googleCallback("code-ana", state, cookie)).expect(http.StatusFound)
+	if !strings.Contains(res.header.Get("Set-Cookie"), "google_oauth_flow=;") {
+		t.Fatalf("the callback should clear the flow cookie, got %q", res.header.Get("Set-Cookie"))
+	}
+
+	// Google refuses a code exchanged without the verifier it was issued for
+	query, cookie = h.startGoogleLogin(nil)
+	h.google.issue("code-ana", "challenge-of-another-sign-in")
+	h.send(googleCallback("code-ana", query.Get("state"), cookie)).expectError(http.StatusBadGateway, "GOOGLE_LOGIN_FAILED")
+}
+
+func TestGoogleSignInFailsWhenUserInfoDoes(t *testing.T) {
+	h := newHarness(t)
+	ana := h.registerTourist("ana@example.com")
+	h.google.authorize("code-ana", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", Name: "Ana"})
+	h.google.failUserInfo()
+
+	h.googleLogin("code-ana").expectError(http.StatusBadGateway, "GOOGLE_LOGIN_FAILED")
+
+	// Linking asks for no email, so an error body read as an empty profile
+	// would link an account with no Google ID
+	var started map[string]string
+	res := h.send(call{method: "POST", path: "/auth/identities/google", token: ana.Token}).expect(http.StatusOK)
+	res.decode(&started)
+	cookie, _, _ := strings.Cut(res.header.Get("Set-Cookie"), ";")
+	authURL, err := url.Parse(started["auth_url"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.google.issue("code-ana", authURL.Query().Get("code_challenge"))
+	h.send(googleCallback("code-ana", authURL.Query().Get("state"), cookie)).expectError(http.StatusBadGateway, "GOOGLE_LOGIN_FAILED")
+	if _, err := h.store.Identities().FindBySubject(context.Background(), "google", ""); err == nil {
+		t.Fatal("the error response was linked as a Google account")
+	}
+}
+
+func TestGoogleSignInKeepsTheTokenOutOfURLs(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.OAuthRedirectURLs = []string{"http://frontend.test/auth/done", "http://admin.test/login?from=google"}
+	})
+	h.google.authorize("code-ana", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", Name: "Ana"})
+
+	// Only allowlisted pages can receive the sign-in
+	for _, redirect := range []string{"http://evil.test/auth/done", "http://frontend.test/auth/done/../../steal", "http://frontend.test/success"} {
+		h.send(call{method: "GET", path: "/auth/google?" + url.Values{"redirect_uri": {redirect}}.Encode()}).
+			expectError(http.StatusBadRequest, "REDIRECT_URI_NOT_ALLOWED")
+	}
+	h.send(call{method: "GET", path: "/auth/google?delivery=fragment"}).expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+
+	// The default is the first allowlisted page, with a login code
+	res := h.googleLogin("code-ana").expect(http.StatusFound)
+	location, _ := url.Parse(res.header.Get("Location"))
+	if location.Host != "frontend.test" || location.Path != "/auth/done" {
+		t.Fatalf("unexpected redirect %s", location)
+	}
+	if strings.Contains(location.String(), "eyJ") || location.Query().Has("token") || location.Query().Has("email") {
+		t.Fatalf("the redirect carries the token or profile: %s", location)
+	}
+
+	// Codes work once, and only real ones
+	code := location.Query().Get("code")
+	var login authResult
+	h.send(call{method: "POST", path: "/auth/exchange", body: map[string]string{"code": code}}).expect(http.StatusOK).decode(&login)
+	h.send(call{method: "GET", path: "/auth/me", token: login.Token}).expect(http.StatusOK)
+	h.send(call{method: "POST", path: "/auth/exchange", body: map[string]string{"code": code}}).expectError(http.StatusBadRequest, "LOGIN_CODE_INVALID")
+	h.send(call{method: "POST", path: "/auth/exchange", body: map[string]string{"code": "guess"}}).expectError(http.StatusBadRequest, "LOGIN_CODE_INVALID")
+	h.send(call{method: "POST", path: "/auth/exchange", body: map[string]string{}}).expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+
+	// Expired codes are refused too
+	stale := models.LoginCode{CodeHash: "0000", UserID: login.User.ID, ExpiresAt: time.Now().Add(-time.Second)}
+	if err := h.store.LoginCodes().Create(context.Background(), &stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.store.LoginCodes().Consume(context.Background(), "0000", time.Now()); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("consuming an expired code: got %v", err)
+	}
+
+	// Cookie delivery sets an HttpOnly cookie that authenticates the browser,
+	// and returns to the page that was asked for, keeping its query
+	query, cookie := h.startGoogleLogin(url.Values{"redirect_uri": {"http://admin.test/login?from=google"}, "delivery": {"cookie"}})
+	h.google.issue("code-ana", query.Get("code_challenge"))
+	res = h.send(googleCallback("code-ana", query.Get("state"), cookie)).expect(http.StatusFound)
+	if got := res.header.Get("Location"); got != "http://admin.test/login?from=google" {
+		t.Fatalf("cookie sign-in redirected to %q", got)
+	}
+	var session string
+	for _, set := range res.header.Values("Set-Cookie") {
+		if strings.HasPrefix(set, "access_token=") {
+			session = set
+		}
+	}
+	if !strings.Contains(session, "HttpOnly") || !strings.Contains(strings.ToLower(session), "samesite=lax") {
+		t.Fatalf("access token cookie should be HttpOnly and SameSite=Lax, got %q", session)
+	}
+	sessionCookie, _, _ := strings.Cut(session, ";")
+	var me struct {
+		Email string `json:"email"`
+	}
+	h.send(call{method: "GET", path: "/auth/me", headers: map[string]string{"Cookie": sessionCookie}}).expect(http.StatusOK).decode(&me)
+	if me.Email != "ana@example.com" {
+		t.Fatalf("cookie authenticated as %q", me.Email)
+	}
+
+	res = h.send(call{method: "POST", path: "/auth/logout", headers: map[string]string{"Cookie": sessionCookie}}).expect(http.StatusNoContent)
+	if !strings.Contains(res.header.Get("Set-Cookie"), "access_token=;") {
+		t.Fatalf("logout should clear the cookie, got %q", res.header.Get("Set-Cookie"))
+	}
+}
+
+func TestOIDCSignInLinksProviderAccountsToUsers(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	h := newHarness(t, withOIDCProvider("corp", issuer))
+
+	var providers []map[string]string
+	h.send(call{method: "GET", path: "/auth/providers"}).expect(http.StatusOK).decode(&providers)
+	want := []map[string]string{{"name": "google", "auth_url": "/auth/google"}, {"name": "corp", "auth_url": "/auth/oidc/corp"}}
+	if !reflect.DeepEqual(providers, want) {
+		t.Fatalf("providers: got %v, want %v", providers, want)
+	}
+
+	// The first sign-in creates the user
+	issuer.Login(map[string]any{"sub": "corp-1", "email": "new@example.com", "name": "New User"})
+	created := h.exchangeLogin(h.oidcLogin("corp"))
+	if created.User.Email != "new@example.com" || created.User.Name != "New User" {
+		t.Fatalf("unexpected user %+v", created.User)
+	}
+	if authorization := issuer.LastAuthorization(); authorization.Get("nonce") == "" || authorization.Get("code_challenge_method") != "S256" {
+		t.Fatalf("sign-in should send a nonce and a PKCE challenge: %v", authorization)
+	}
+
+	// Later ones find the user by subject, whatever the email says now
+	issuer.Login(map[string]any{"sub": "corp-1", "email": "renamed@example.com"})
+	if again := h.exchangeLogin(h.oidcLogin("corp")); again.User.ID != created.User.ID {
+		t.Fatalf("second sign-in got user %d, want %d", again.User.ID, created.User.ID)
+	}
+
+	// A verified email links the provider account to the existing user
+	ana := h.registerTourist("ana@example.com")
+	issuer.Login(map[string]any{"sub": "corp-2", "email": "ana@example.com"})
+	if linked := h.exchangeLogin(h.oidcLogin("corp")); linked.User.ID != ana.User.ID || linked.User.Role != "tourist" {
+		t.Fatalf("sign-in should link to user %d, got %+v", ana.User.ID, linked.User)
+	}
+
+	// An unverified one could belong to anyone, so it links nothing
+	h.registerTourist("bob@example.com")
+	issuer.Login(map[string]any{"sub": "corp-3", "email": "bob@example.com", "email_verified": false})
+	h.oidcLogin("corp").expectError(http.StatusConflict, "IDENTITY_EMAIL_UNVERIFIED")
+	if _, err := h.store.Identities().FindBySubject(context.Background(), "corp", "corp-3"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("the unverified account was linked: %v", err)
+	}
+}
+
+func TestOIDCSignInRejectsForgedAndMisroutedCallbacks(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	other := oidctest.NewIssuer(t)
+	h := newHarness(t, withOIDCProvider("corp", issuer), withOIDCProvider("other", other))
+
+	h.send(call{method: "GET", path: "/auth/oidc/nope"}).expectError(http.StatusNotFound, "OIDC_PROVIDER_NOT_FOUND")
+	h.send(call{method: "GET", path: "/auth/oidc/nope/callback?code=c&state=s"}).expectError(http.StatusNotFound, "OIDC_PROVIDER_NOT_FOUND")
+
+	// A callback without the browser's flow cookie, or with another provider's
+	callback, cookie := h.startOIDCLogin("corp")
+	h.send(call{method: "GET", path: callback.RequestURI()}).expectError(http.StatusBadRequest, "OAUTH_STATE_INVALID")
+	_, otherCookie := h.startOIDCLogin("other")
+	h.send(call{method: "GET", path: callback.RequestURI(), headers: map[string]string{"Cookie": otherCookie}}).
+		expectError(http.StatusBadRequest, "OAUTH_STATE_INVALID")
+	res := h.send(call{method: "GET", path: callback.RequestURI(), headers: map[string]string{"Cookie": cookie}})
+	if !strings.Contains(res.header.Get("Set-Cookie"), "oidc_flow=;") {
+		t.Fatalf("the callback should clear the flow cookie, got %q", res.header.Get("Set-Cookie"))
+	}
+
+	// ID tokens signed with a key the issuer doesn't publish are refused
+	issuer.SignWithUnpublishedKey()
+	h.oidcLogin("corp").expectError(http.StatusBadGateway, "OIDC_LOGIN_FAILED")
+}
+
+func TestOIDCSignInAcceptsFormPostCallbacks(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	h := newHarness(t, withOIDCProvider("apple", issuer, func(p *config.OIDCProviderConfig) { p.ResponseMode = "form_post" }))
+
+	// Over HTTPS the flow cookie must survive the provider's cross-site POST
+	res := h.send(call{method: "GET", path: "/auth/oidc/apple", headers: map[string]string{"X-Forwarded-Proto": "https"}}).expect(http.StatusFound)
+	if set := strings.ToLower(res.header.Get("Set-Cookie")); !strings.Contains(set, "samesite=none") || !strings.Contains(set, "secure") {
+		t.Fatalf("flow cookie should be SameSite=None and Secure over HTTPS, got %q", set)
+	}
+
+	callback, cookie := h.startOIDCLogin("apple")
+	if issuer.LastAuthorization().Get("response_mode") != "form_post" {
+		t.Fatalf("response_mode not requested: %v", issuer.LastAuthorization())
+	}
+	login := h.exchangeLogin(h.send(call{
+		method:  "POST",
+		path:    callback.Path,
+		form:    callback.Query(),
+		headers: map[string]string{"Cookie": cookie},
+	}))
+	if login.User.Email != "user@example.com" {
+		t.Fatalf("unexpected user %+v", login.User)
+	}
+}
+
+func TestUsersLinkAndUnlinkProviderAccounts(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	h := newHarness(t, withOIDCProvider("corp", issuer))
+	ana := h.registerTourist("ana@example.com")
+
+	// startLink asks the API to link the provider and returns the authorization
+	// URL and the flow cookie, like the frontend does before sending the browser
+	startLink := func(token, provider string) (string, string) {
+		t.Helper()
+		var started map[string]string
+		res := h.send(call{method: "POST", path: "/auth/identities/" + provider, token: token}).expect(http.StatusOK)
+		res.decode(&started)
+		cookie, _, _ := strings.Cut(res.header.Get("Set-Cookie"), ";")
+		return started["auth_url"], cookie
+	}
+
+	// Linking is explicit, so the provider's email needn't match or be verified
+	issuer.Login(map[string]any{"sub": "corp-ana", "email": "ana@corp.example", "email_verified": false})
+	authURL, cookie := startLink(ana.Token, "corp")
+	callback := h.authorizeAtIssuer(authURL)
+	linked := h.exchangeLogin(h.send(call{method: "GET", path: callback.RequestURI(), headers: map[string]string{"Cookie": cookie}}))
+	if linked.User.ID != ana.User.ID {
+		t.Fatalf("linking signed in user %d, want %d", linked.User.ID, ana.User.ID)
+	}
+
+	authURL, cookie = startLink(ana.Token, "google")
+	google, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.google.authorize("code-link", services.GoogleUserInfo{ID: "google-ana", Email: "ana@gmail.example"})
+	h.google.issue("code-link", google.Query().Get("code_challenge"))
+	h.exchangeLogin(h.send(googleCallback("code-link", google.Query().Get("state"), cookie)))
+
+	var identities []map[string]interface{}
+	h.send(call{method: "GET", path: "/auth/identities", token: ana.Token}).expect(http.StatusOK).decode(&identities)
+	if len(identities) != 2 || identities[0]["provider"] != "corp" || identities[1]["provider"] != "google" ||
+		identities[0]["email"] != "ana@corp.example" || identities[0]["linked_at"] == nil {
+		t.Fatalf("unexpected identities %v", identities)
+	}
+	var me map[string]interface{}
+	h.send(call{method: "GET", path: "/auth/me", token: ana.Token}).expect(http.StatusOK).decode(&me)
+	if me["google_id"] != "google-ana" || me["has_password"] != true {
+		t.Fatalf("unexpected account %v", me)
+	}
+
+	// Another user can neither take the provider account nor unlink it
+	bob := h.registerTourist("bob@example.com")
+	authURL, cookie = startLink(bob.Token, "corp")
+	callback = h.authorizeAtIssuer(authURL)
+	h.send(call{method: "GET", path: callback.RequestURI(), headers: map[string]string{"Cookie": cookie}}).
+		expectError(http.StatusConflict, "IDENTITY_LINKED_ELSEWHERE")
+	corpPath := "/auth/identities/" + strconv.Itoa(int(identities[0]["id"].(float64)))
+	h.send(call{method: "DELETE", path: corpPath, token: bob.Token}).
+		expectError(http.StatusNotFound, "IDENTITY_NOT_FOUND")
+
+	h.send(call{method: "DELETE", path: corpPath, token: ana.Token}).expect(http.StatusNoContent)
+	if _, err := h.store.Identities().FindBySubject(context.Background(), "corp", "corp-ana"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("the identity was not unlinked: %v", err)
+	}
+
+	h.send(call{method: "POST", path: "/auth/identities/nope", token: ana.Token}).expectError(http.StatusNotFound, "OIDC_PROVIDER_NOT_FOUND")
+	h.send(call{method: "POST", path: "/auth/identities/corp"}).expect(http.StatusUnauthorized)
+}
+
+func TestProviderOnlyUsersCanSetAPassword(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	h := newHarness(t, withOIDCProvider("corp", issuer))
+
+	issuer.Login(map[string]any{"sub": "corp-new", "email": "new@example.com"})
+	created := h.exchangeLogin(h.oidcLogin("corp"))
+	login := map[string]string{"email": "new@example.com", "password": "correct horse"}
+	h.send(call{method: "POST", path: "/auth/login", body: login}).expectError(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+
+	// The provider account is the only way in until the user sets a password
+	var identities []map[string]interface{}
+	h.send(call{method: "GET", path: "/auth/identities", token: created.Token}).expect(http.StatusOK).decode(&identities)
+	unlink := call{method: "DELETE", path: "/auth/identities/" + strconv.Itoa(int(identities[0]["id"].(float64))), token: created.Token}
+	h.send(unlink).expectError(http.StatusConflict, "LAST_LOGIN_METHOD")
+
+	h.send(call{method: "POST", path: "/auth/password", token: created.Token, body: map[string]string{"password": "short"}}).
+		expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	h.send(call{method: "POST", path: "/auth/password", token: created.Token, body: login}).expect(http.StatusNoContent)
+	h.send(call{method: "POST", path: "/auth/password", token: created.Token, body: login}).
+		expectError(http.StatusConflict, "PASSWORD_ALREADY_SET")
+
+	var signedIn authResult
+	h.send(call{method: "POST", path: "/auth/login", body: login}).expect(http.StatusOK).decode(&signedIn)
+	if signedIn.User.ID != created.User.ID {
+		t.Fatalf("password login got user %d, want %d", signedIn.User.ID, created.User.ID)
+	}
+	h.send(unlink).expect(http.StatusNoContent)
+}
+
+func TestRegistrationEmailsAVerificationLink(t *testing.T) {
+	h := newHarness(t)
+	var registered authResult
+	h.send(call{method: "POST", path: "/auth/register", headers: map[string]string{"Accept-Language": "es"}, body: map[string]interface{}{
+		"email":    "ana@example.com",
+		"password": "correct horse",
+		"name":     "Ana",
+		"role":     "tourist",
+		"tourist": map[string]string{
+			"nationality":    "CL",
+			"language":       "es",
+			"arrival_date":   "2025-07-01",
+			"departure_date": "2025-07-10",
+		},
+	}}).expect(http.StatusCreated).decode(&registered)
+
+	sent := h.outbox.sent("ana@example.com")
+	if len(sent) != 1 || sent[0].Subject != i18n.T("es", "emails.verify_subject") ||
+		!strings.Contains(sent[0].Text, "http://frontend.test/verify-email?token=") {
+		t.Fatalf("registration should email a verification link in the user's language, sent %+v", sent)
+	}
+	me := func() map[string]interface{} {
+		var user map[string]interface{}
+		h.send(call{method: "GET", path: "/auth/me", token: registered.Token}).expect(http.StatusOK).decode(&user)
+		return user
+	}
+	if me()["email_verified"] != false {
+		t.Fatalf("a new account should start unverified: %v", me())
+	}
+
+	// Unverified accounts can't book until they open the link
+	_, driverID := h.registerDriver("dan@example.com")
+	book := call{method: "POST", path: "/api/tourists/book-driver", token: registered.Token, body: map[string]interface{}{
+		"driverId":         driverID,
+		"pickup_location":  "Airport",
+		"dropoff_location": "Hotel",
+		"date_time":        "2025-07-01T10:00",
+	}}
+	h.send(book).expectError(http.StatusForbidden, "EMAIL_UNVERIFIED")
+	h.send(call{method: "POST", path: "/api/bookings/", token: registered.Token, body: map[string]interface{}{
+		"driver_id":        driverID,
+		"pickup_location":  "Port",
+		"dropoff_location": "Old town",
+		"date_time":        "2025-07-03T08:30",
+	}}).expectError(http.StatusForbidden, "EMAIL_UNVERIFIED")
+
+	// Asking again replaces the link sent before
+	first := h.outbox.token(t, "ana@example.com")
+	h.send(call{method: "POST", path: "/auth/verify-email/resend", token: registered.Token}).expect(http.StatusNoContent)
+	second := h.outbox.token(t, "ana@example.com")
+	verify := func(token string) *response {
+		return h.send(call{method: "POST", path: "/auth/verify-email", body: map[string]string{"token": token}})
+	}
+	verify(first).expectError(http.StatusBadRequest, "VERIFICATION_TOKEN_INVALID")
+	verify(second).expect(http.StatusNoContent)
+	verify(second).expectError(http.StatusBadRequest, "VERIFICATION_TOKEN_INVALID")
+	verify("").expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+
+	if me()["email_verified"] != true {
+		t.Fatalf("the account should be verified: %v", me())
+	}
+	h.send(call{method: "POST", path: "/auth/verify-email/resend", token: registered.Token}).
+		expectError(http.StatusConflict, "EMAIL_ALREADY_VERIFIED")
+	h.send(book).expect(http.StatusCreated)
+}
+
+func TestVerificationRestrictionsAreConfigurable(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.EmailVerification.Restrict = []string{config.FeatureDriverProfile}
+	})
+	var driver authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]string{
+		"email":    "dan@example.com",
+		"password": "correct horse",
+		"name":     "Dan",
+		"role":     "driver",
+	}}).expect(http.StatusCreated).decode(&driver)
+
+	profile := call{method: "POST", path: "/api/drivers/", token: driver.Token, body: map[string]interface{}{
+		"license_number": "LIC-dan",
+		"vehicle_type":   "sedan",
+		"vehicle_model":  "Corolla",
+		"vehicle_color":  "white",
+		"languages":      "es,en",
+		"experience":     4,
+	}}
+	h.send(profile).expectError(http.StatusForbidden, "EMAIL_UNVERIFIED")
+	h.verifyEmail("dan@example.com")
+	h.send(profile).expect(http.StatusCreated)
+}
+
+func TestProvidersVouchForTheEmailsTheyVerified(t *testing.T) {
+	h := newHarness(t)
+	me := func(token string) map[string]interface{} {
+		var user map[string]interface{}
+		h.send(call{method: "GET", path: "/auth/me", token: token}).expect(http.StatusOK).decode(&user)
+		return user
+	}
+
+	h.google.authorize("code-ana", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", VerifiedEmail: true, Name: "Ana"})
+	ana := h.exchangeLogin(h.googleLogin("code-ana"))
+	if me(ana.Token)["email_verified"] != true || len(h.outbox.sent("ana@example.com")) != 0 {
+		t.Fatalf("a verified Google address needs no link: %v", me(ana.Token))
+	}
+
+	// Without the provider's word the user gets a link like any other
+	h.google.authorize("code-bob", services.GoogleUserInfo{ID: "google-bob", Email: "bob@example.com", Name: "Bob"})
+	bob := h.exchangeLogin(h.googleLogin("code-bob"))
+	if me(bob.Token)["email_verified"] != false || len(h.outbox.sent("bob@example.com")) != 1 {
+		t.Fatalf("an unverified Google address should get a link: %v", me(bob.Token))
+	}
+}
+
+func TestVerificationEmailsAreRateLimited(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.RateLimit.Email = config.Limit{Requests: 2, Window: config.Duration(time.Minute)}
+	})
+	var registered authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]string{
+		"email":    "dan@example.com",
+		"password": "correct horse",
+		"name":     "Dan",
+		"role":     "driver",
+	}}).expect(http.StatusCreated).decode(&registered)
+
+	resend := call{method: "POST", path: "/auth/verify-email/resend", token: registered.Token}
+	h.send(resend).expect(http.StatusNoContent)
+	h.send(resend).expect(http.StatusNoContent)
+	h.send(resend).expectError(http.StatusTooManyRequests, "RATE_LIMITED")
+	if sent := h.outbox.sent("dan@example.com"); len(sent) != 3 {
+		t.Fatalf("the limited request should send nothing, sent %d emails", len(sent))
+	}
+	h.verifyEmail("dan@example.com")
+}
+
+func TestForgottenPasswordsAreResetByEmail(t *testing.T) {
+	h := newHarness(t)
+	registered := h.registerTourist("ana@example.com")
+	forgot := func(email string) map[string]string {
+		var body map[string]string
+		h.send(call{method: "POST", path: "/auth/password/forgot", body: map[string]string{"email": email}}).
+			expect(http.StatusAccepted).decode(&body)
+		return body
+	}
+
+	// Unknown addresses get the same answer and no email
+	if known, unknown := forgot("ana@example.com"), forgot("nobody@example.com"); !reflect.DeepEqual(known, unknown) {
+		t.Fatalf("the answer should not reveal which address is registered: %v vs %v", known, unknown)
+	}
+	if sent := h.outbox.sent("nobody@example.com"); len(sent) != 0 {
+		t.Fatalf("an unknown address was emailed: %+v", sent)
+	}
+	sent := h.outbox.sent("ana@example.com")
+	if last := sent[len(sent)-1]; last.Subject != i18n.T("es", "emails.reset_subject") ||
+		!strings.Contains(last.Text, "http://frontend.test/reset-password?token=") {
+		t.Fatalf("expected a reset link in the user's language, got %+v", last)
+	}
+
+	// Asking again replaces the link sent before
+	first := h.outbox.token(t, "ana@example.com")
+	forgot("ana@example.com")
+	second := h.outbox.token(t, "ana@example.com")
+	reset := func(token, password string) *response {
+		return h.send(call{method: "POST", path: "/auth/password/reset", body: map[string]string{"token": token, "password": password}})
+	}
+	reset(first, "battery staple").expectError(http.StatusBadRequest, "RESET_TOKEN_INVALID")
+	reset(second, "short").expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	reset(second, "battery staple").expect(http.StatusNoContent)
+	reset(second, "another password").expectError(http.StatusBadRequest, "RESET_TOKEN_INVALID")
+
+	// Every session from before is signed out and only the new password works
+	h.send(call{method: "GET", path: "/auth/me", token: registered.Token}).expectError(http.StatusUnauthorized, "TOKEN_INVALID")
+	login := func(password string) *response {
+		return h.send(call{method: "POST", path: "/auth/login", body: map[string]string{"email": "ana@example.com", "password": password}})
+	}
+	login("correct horse").expectError(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+	var signedIn authResult
+	login("battery staple").expect(http.StatusOK).decode(&signedIn)
+	h.send(call{method: "GET", path: "/auth/me", token: signedIn.Token}).expect(http.StatusOK)
+
+	events, err := h.store.AuditEvents().List(context.Background(), "", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trail []string
+	for _, event := range events {
+		if strings.HasPrefix(event.Event, "password.") {
+			trail = append(trail, event.Email+" "+event.Event+" "+event.Detail)
+		}
+	}
+	want := []string{
+		"ana@example.com password.reset ",
+		"ana@example.com password.reset_requested ",
+		"nobody@example.com password.reset_requested unknown email",
+		"ana@example.com password.reset_requested ",
+	}
+	if !reflect.DeepEqual(trail, want) {
+		t.Fatalf("audit trail %q, want %q", trail, want)
+	}
+}
+
+func TestChangingThePasswordSignsOutOtherSessions(t *testing.T) {
+	h := newHarness(t)
+	h.registerTourist("ana@example.com")
+	login := func(password string) *response {
+		return h.send(call{method: "POST", path: "/auth/login", body: map[string]string{"email": "ana@example.com", "password": password}})
+	}
+	var laptop, phone authResult
+	login("correct horse").expect(http.StatusOK).decode(&laptop)
+	login("correct horse").expect(http.StatusOK).decode(&phone)
+
+	change := func(token, current, next string) *response {
+		return h.send(call{method: "POST", path: "/auth/password/change", token: token, body: map[string]string{
+			"current_password": current,
+			"new_password":     next,
+		}})
+	}
+	change(laptop.Token, "wrong password", "battery staple").expectError(http.StatusForbidden, "PASSWORD_INCORRECT")
+	change(laptop.Token, "correct horse", "short").expectError(http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+	h.send(call{method: "GET", path: "/auth/me", token: phone.Token}).expect(http.StatusOK)
+
+	// A copy of the user read before the change and saved after it can't bring
+	// the old password or sessions back
+	stale, err := h.store.Users().FindByEmail(context.Background(), "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed authResult
+	change(laptop.Token, "correct horse", "battery staple").expect(http.StatusOK).decode(&changed)
+	if err := h.store.Users().Update(context.Background(), stale); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{laptop.Token, phone.Token} {
+		h.send(call{method: "GET", path: "/auth/me", token: token}).expectError(http.StatusUnauthorized, "TOKEN_INVALID")
+	}
+	h.send(call{method: "GET", path: "/auth/me", token: changed.Token}).expect(http.StatusOK)
+	login("correct horse").expectError(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+	login("battery staple").expect(http.StatusOK)
+
+	// Users who signed up through a provider set a password instead
+	h.google.authorize("code-bob", services.GoogleUserInfo{ID: "google-bob", Email: "bob@example.com", VerifiedEmail: true, Name: "Bob"})
+	bob := h.exchangeLogin(h.googleLogin("code-bob"))
+	change(bob.Token, "anything", "battery staple").expectError(http.StatusConflict, "PASSWORD_NOT_SET")
+}
+
+func TestWrongCurrentPasswordsCountTowardTheLockout(t *testing.T) {
+	h := newHarness(t, func(opts *server.Options) {
+		opts.Config.RateLimit.Lockout = config.LockoutConfig{
+			MaxFailures: 2,
+			Window:      config.Duration(time.Minute),
+			Duration:    config.Duration(time.Minute),
+			MaxDuration: config.Duration(time.Hour),
+		}
+	})
+	registered := h.registerTourist("ana@example.com")
+	change := call{method: "POST", path: "/auth/password/change", token: registered.Token, body: map[string]string{
+		"current_password": "wrong password",
+		"new_password":     "battery staple",
+	}}
+	h.send(change).expectError(http.StatusForbidden, "PASSWORD_INCORRECT")
+	h.send(change).expectError(http.StatusTooManyRequests, "ACCOUNT_LOCKED")
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{"email": "ana@example.com", "password": "correct horse"}}).
+		expectError(http.StatusTooManyRequests, "ACCOUNT_LOCKED")
+}
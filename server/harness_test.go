@@ -0,0 +1,505 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fiber-backend/apperror"
+	"fiber-backend/config"
+	"fiber-backend/database"
+	"fiber-backend/mail"
+	"fiber-backend/oidc/oidctest"
+	"fiber-backend/repository"
+	"fiber-backend/server"
+	"fiber-backend/services"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// harness runs the app exactly as main.go builds it, backed by an ephemeral
+// database, a fake Google OAuth server and an outbox in place of the mailer.
+// The database is in-memory SQLite with the real migrations applied;
+// TEST_DB_DRIVER=memory swaps in the in-memory store for a faster run.
+type harness struct {
+	t      *testing.T
+	app    *fiber.App
+	store  repository.Store
+	google *fakeGoogle
+	outbox *outbox
+}
+
+// newHarness builds the app; configure functions may adjust the options first
+func newHarness(t *testing.T, configure ...func(*server.Options)) *harness {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database = config.DatabaseConfig{Driver: database.DriverSQLite, URL: database.InMemoryDSN}
+	cfg.JWT.Secret = "test-secret"
+	cfg.FrontendURL = "http://frontend.test"
+	cfg.Google = config.GoogleConfig{
+		ClientID:     "test-client",
+		ClientSecret: "test-client-secret",
+		RedirectURL:  "http://api.test/auth/google/callback",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	google := newFakeGoogle(t)
+	outbox := &outbox{}
+	opts := server.Options{
+		Config: &cfg,
+		Store:  newTestStore(t),
+		Google: google.endpoints(),
+		Mailer: outbox,
+	}
+	for _, fn := range configure {
+		fn(&opts)
+	}
+	app, err := server.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &harness{t: t, app: app, store: opts.Store, google: google, outbox: outbox}
+}
+
+// testMemoryDriver is the TEST_DB_DRIVER value that runs the tests on
+// repository.NewMemoryStore instead of SQLite
+const testMemoryDriver = "memory"
+
+func newTestStore(t *testing.T) repository.Store {
+	t.Helper()
+	if os.Getenv("TEST_DB_DRIVER") == testMemoryDriver {
+		return repository.NewMemoryStore()
+	}
+
+	db, err := database.OpenWith(database.DriverSQLite, database.InMemoryDSN)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return repository.NewGormStore(db)
+}
+
+// call describes one HTTP request against the app
+type call struct {
+	method  string
+	path    string
+	token   string
+	body    interface{}
+	form    url.Values // sent form-encoded instead of body
+	headers map[string]string
+}
+
+type response struct {
+	t      *testing.T
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (h *harness) send(c call) *response {
+	h.t.Helper()
+
+	var body io.Reader
+	if c.body != nil {
+		raw, err := json.Marshal(c.body)
+		if err != nil {
+			h.t.Fatalf("marshal request body: %v", err)
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	contentType := "application/json"
+	if c.form != nil {
+		body, contentType = strings.NewReader(c.form.Encode()), "application/x-www-form-urlencoded"
+	}
+
+	req := httptest.NewRequest(c.method, c.path, body)
+	req.Header.Set("Content-Type", contentType)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := h.app.Test(req, -1)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", c.method, c.path, err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		h.t.Fatalf("read response body: %v", err)
+	}
+	return &response{t: h.t, status: res.StatusCode, header: res.Header, body: raw}
+}
+
+// expect fails the test unless the response has the given status
+func (r *response) expect(status int) *response {
+	r.t.Helper()
+	if r.status != status {
+		r.t.Fatalf("expected status %d, got %d: %s", status, r.status, r.body)
+	}
+	return r
+}
+
+// expectError fails the test unless the response is the given error
+func (r *response) expectError(status int, code string) apperror.Response {
+	r.t.Helper()
+	r.expect(status)
+	var body apperror.Response
+	r.decode(&body)
+	if body.Code != code || body.Status != status || body.RequestID == "" {
+		r.t.Fatalf("expected error %s, got %s", code, r.body)
+	}
+	return body
+}
+
+// decode unmarshals the JSON body into v
+func (r *response) decode(v interface{}) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		r.t.Fatalf("decode %s: %v", r.body, err)
+	}
+}
+
+type authResult struct {
+	Token string `json:"token"`
+	User  struct {
+		ID    uint   `json:"id"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+		Role  string `json:"role"`
+	} `json:"user"`
+}
+
+// startGoogleLogin sends the browser to Google, with the given query for
+// /auth/google, and returns the query of the authorize URL and the cookie the
+// API set for the callback
+func (h *harness) startGoogleLogin(params url.Values) (url.Values, string) {
+	h.t.Helper()
+	res := h.send(call{method: "GET", path: "/auth/google?" + params.Encode()}).expect(http.StatusFound)
+	location, err := url.Parse(res.header.Get("Location"))
+	if err != nil {
+		h.t.Fatalf("invalid redirect to Google: %v", err)
+	}
+	cookie, _, _ := strings.Cut(res.header.Get("Set-Cookie"), ";")
+	return location.Query(), cookie
+}
+
+// googleLogin signs in through Google as the user authorized for code, the way
+// a browser does: to Google and back to the callback with the state and cookie
+func (h *harness) googleLogin(code string) *response {
+	h.t.Helper()
+	query, cookie := h.startGoogleLogin(nil)
+	h.google.issue(code, query.Get("code_challenge"))
+	return h.send(googleCallback(code, query.Get("state"), cookie))
+}
+
+// googleCallback is the request Google sends the browser back with
+func googleCallback(code, state, cookie string) call {
+	return call{
+		method:  "GET",
+		path:    "/auth/google/callback?" + url.Values{"code": {code}, "state": {state}}.Encode(),
+		headers: map[string]string{"Cookie": cookie},
+	}
+}
+
+// withOIDCProvider configures the issuer as the OIDC provider called name
+func withOIDCProvider(name string, issuer *oidctest.Issuer, configure ...func(*config.OIDCProviderConfig)) func(*server.Options) {
+	return func(opts *server.Options) {
+		provider := config.OIDCProviderConfig{
+			Name:         name,
+			Issuer:       issuer.URL,
+			ClientID:     issuer.ClientID,
+			ClientSecret: issuer.ClientSecret,
+			RedirectURL:  "http://api.test/auth/oidc/" + name + "/callback",
+		}
+		for _, fn := range configure {
+			fn(&provider)
+		}
+		opts.Config.OIDCProviders = append(opts.Config.OIDCProviders, provider)
+	}
+}
+
+// startOIDCLogin sends the browser to the provider, which signs in whoever the
+// issuer's Login names, and returns where the provider sends the browser back
+// to and the cookie the API set for the callback
+func (h *harness) startOIDCLogin(provider string) (*url.URL, string) {
+	h.t.Helper()
+	res := h.send(call{method: "GET", path: "/auth/oidc/" + provider}).expect(http.StatusFound)
+	cookie, _, _ := strings.Cut(res.header.Get("Set-Cookie"), ";")
+	return h.authorizeAtIssuer(res.header.Get("Location")), cookie
+}
+
+// authorizeAtIssuer follows an authorization URL of an oidctest issuer and
+// returns where it sends the browser back to
+func (h *harness) authorizeAtIssuer(authURL string) *url.URL {
+	h.t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authorized, err := client.Get(authURL)
+	if err != nil {
+		h.t.Fatalf("authorize at the issuer: %v", err)
+	}
+	authorized.Body.Close()
+	callback, err := url.Parse(authorized.Header.Get("Location"))
+	if err != nil || authorized.StatusCode != http.StatusFound {
+		h.t.Fatalf("issuer did not redirect back (status %d): %v", authorized.StatusCode, err)
+	}
+	return callback
+}
+
+// oidcLogin signs in with the provider the way a browser does and returns the
+// callback's response
+func (h *harness) oidcLogin(provider string) *response {
+	h.t.Helper()
+	callback, cookie := h.startOIDCLogin(provider)
+	return h.send(call{method: "GET", path: callback.RequestURI(), headers: map[string]string{"Cookie": cookie}})
+}
+
+// exchangeLogin trades the login code a sign-in redirected with for a login
+func (h *harness) exchangeLogin(res *response) authResult {
+	h.t.Helper()
+	location, err := url.Parse(res.expect(http.StatusFound).header.Get("Location"))
+	if err != nil || !location.Query().Has("code") {
+		h.t.Fatalf("sign-in redirected without a code: %q", res.header.Get("Location"))
+	}
+	var login authResult
+	h.send(call{method: "POST", path: "/auth/exchange", body: map[string]string{"code": location.Query().Get("code")}}).
+		expect(http.StatusOK).decode(&login)
+	return login
+}
+
+// registerTourist creates a tourist account with a profile and returns its login
+func (h *harness) registerTourist(email string) authResult {
+	h.t.Helper()
+	var result authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]interface{}{
+		"email":    email,
+		"password": "correct horse",
+		"name":     "Tourist " + email,
+		"role":     "tourist",
+		"tourist": map[string]string{
+			"nationality":    "CL",
+			"language":       "es",
+			"arrival_date":   "2025-07-01",
+			"departure_date": "2025-07-10",
+		},
+	}}).expect(http.StatusCreated).decode(&result)
+	h.verifyEmail(email)
+	return result
+}
+
+// verifyEmail opens the last verification link emailed to the address
+func (h *harness) verifyEmail(email string) {
+	h.t.Helper()
+	h.send(call{method: "POST", path: "/auth/verify-email", body: map[string]string{
+		"token": h.outbox.token(h.t, email),
+	}}).expect(http.StatusNoContent)
+}
+
+// outbox keeps the emails the app sends instead of delivering them
+type outbox struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (o *outbox) Send(_ context.Context, message mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, message)
+	return nil
+}
+
+// sent returns the emails sent to the address, oldest first
+func (o *outbox) sent(to string) []mail.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var sent []mail.Message
+	for _, message := range o.messages {
+		if message.To == to {
+			sent = append(sent, message)
+		}
+	}
+	return sent
+}
+
+// token returns the ?token= of the link in the last email sent to the address
+func (o *outbox) token(t *testing.T, to string) string {
+	t.Helper()
+	sent := o.sent(to)
+	if len(sent) == 0 {
+		t.Fatalf("no email was sent to %s", to)
+	}
+	text := sent[len(sent)-1].Text
+	start := strings.Index(text, "http")
+	if start < 0 {
+		t.Fatalf("the email to %s has no link: %q", to, text)
+	}
+	link, err := url.Parse(strings.Fields(text[start:])[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+// registerDriver creates a driver account with an active profile and returns its
+// login and driver ID
+func (h *harness) registerDriver(email string) (authResult, uint) {
+	h.t.Helper()
+	var result authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]string{
+		"email":    email,
+		"password": "correct horse",
+		"name":     "Driver " + email,
+		"role":     "driver",
+	}}).expect(http.StatusCreated).decode(&result)
+
+	var driver struct {
+		ID uint `json:"id"`
+	}
+	h.send(call{method: "POST", path: "/api/drivers/", token: result.Token, body: map[string]interface{}{
+		"license_number": "LIC-" + email,
+		"vehicle_type":   "sedan",
+		"vehicle_model":  "Corolla",
+		"vehicle_color":  "white",
+		"languages":      "es,en",
+		"experience":     4,
+	}}).expect(http.StatusCreated).decode(&driver)
+	return result, driver.ID
+}
+
+// fakeGoogle stands in for Google's token and userinfo endpoints. Each
+// authorization code maps to the profile Google would return for it, and is
+// only exchanged with the PKCE verifier of the sign-in it was issued to.
+type fakeGoogle struct {
+	server      *httptest.Server
+	mu          sync.Mutex
+	users       map[string]services.GoogleUserInfo
+	challenges  map[string]string
+	traceparent string
+	// userInfoDown makes the userinfo endpoint answer 503 with a JSON body
+	userInfoDown bool
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	g := &fakeGoogle{users: map[string]services.GoogleUserInfo{}, challenges: map[string]string{}}
+
+	mux := http.NewServeMux()
+	record := func(r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.traceparent = r.Header.Get("traceparent")
+	}
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		code := r.Form.Get("code")
+		if _, ok := g.user(code); !ok || !g.verify(code, r.Form.Get("code_verifier")) {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access-" + code, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if g.down() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"backend_error"}`))
+			return
+		}
+		code := strings.TrimPrefix(r.URL.Query().Get("access_token"), "access-")
+		user, ok := g.user(code)
+		if !ok {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	})
+
+	g.server = httptest.NewServer(mux)
+	t.Cleanup(g.server.Close)
+	return g
+}
+
+func (g *fakeGoogle) endpoints() services.GoogleEndpoints {
+	return services.GoogleEndpoints{
+		AuthURL:     g.server.URL + "/auth",
+		TokenURL:    g.server.URL + "/token",
+		UserInfoURL: g.server.URL + "/userinfo",
+	}
+}
+
+// lastTraceparent returns the trace context header of the latest call Google received
+func (g *fakeGoogle) lastTraceparent() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.traceparent
+}
+
+// authorize registers the profile Google returns for the given code
+func (g *fakeGoogle) authorize(code string, user services.GoogleUserInfo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.users[code] = user
+}
+
+// issue ties code to the PKCE challenge of the sign-in it is sent back to
+func (g *fakeGoogle) issue(code, challenge string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.challenges[code] = challenge
+}
+
+// verify checks verifier against the S256 challenge code was issued with
+func (g *fakeGoogle) verify(code, verifier string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier != "" && g.challenges[code] == base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// failUserInfo makes every later userinfo request fail
+func (g *fakeGoogle) failUserInfo() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.userInfoDown = true
+}
+
+func (g *fakeGoogle) down() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.userInfoDown
+}
+
+func (g *fakeGoogle) user(code string) (services.GoogleUserInfo, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	user, ok := g.users[code]
+	return user, ok
+}
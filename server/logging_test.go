@@ -0,0 +1,104 @@
+package server_test
+
+import (
+	"bytes"
+	"fiber-backend/services"
+	"fiber-backend/utils"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends every log line to a buffer at debug level for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := utils.SetupLogging(utils.LogOptions{Level: "debug", Format: "json", Output: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		utils.SetupLogging(utils.LogOptions{Level: "info", Format: "json"})
+	})
+	return &buf
+}
+
+func TestHandlerLogsDoNotLeakSecrets(t *testing.T) {
+	// Run on SQLite so GORM's query logging is exercised too
+	t.Setenv("TEST_DB_DRIVER", "sqlite")
+	logs := captureLogs(t)
+	h := newHarness(t)
+
+	var tourist authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]interface{}{
+		"email":    "ana@example.com",
+		"password": "correct horse",
+		"name":     "Ana",
+		"role":     "tourist",
+		"tourist": map[string]string{
+			"nationality":    "CL",
+			"language":       "es",
+			"arrival_date":   "2025-07-01",
+			"departure_date": "2025-07-10",
+			"special_needs":  "insulin kept refrigerated",
+		},
+	}}).expect(http.StatusCreated).decode(&tourist)
+
+	var login authResult
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+		"email":    "ana@example.com",
+		"password": "correct horse",
+	}}).expect(http.StatusOK).decode(&login)
+	h.send(call{method: "POST", path: "/auth/login", body: map[string]string{
+		"email":    "ana@example.com",
+		"password": "wrong horse",
+	}}).expect(http.StatusUnauthorized)
+
+	h.send(call{method: "PATCH", path: "/api/tourists/me", token: login.Token, body: map[string]string{
+		"special_needs": "wheelchair ramp",
+	}}).expect(http.StatusOK)
+
+	var driver authResult
+	h.send(call{method: "POST", path: "/auth/register", body: map[string]string{
+		"email":    "dan@example.com",
+		"password": "driver pass",
+		"name":     "Dan",
+		"role":     "driver",
+	}}).expect(http.StatusCreated).decode(&driver)
+	h.send(call{method: "POST", path: "/api/drivers/", token: driver.Token, body: map[string]interface{}{
+		"license_number": "LIC-SECRET-42",
+		"vehicle_type":   "sedan",
+		"vehicle_model":  "Corolla",
+		"vehicle_color":  "white",
+		"languages":      "es",
+		"experience":     3,
+	}}).expect(http.StatusCreated)
+
+	h.verifyEmail("ana@example.com")
+	h.google.authorize("oauth-code-77", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", VerifiedEmail: true, Name: "Ana"})
+	h.googleLogin("oauth-code-77").expect(http.StatusFound)
+
+	output := logs.String()
+	if !strings.Contains(output, "a***@example.com") {
+		t.Fatalf("expected masked emails in the logs, got:\n%s", output)
+	}
+	for _, secret := range []string{
+		"correct horse",
+		"wrong horse",
+		"driver pass",
+		"$2a$",
+		tourist.Token,
+		login.Token,
+		driver.Token,
+		"ana@example.com",
+		"dan@example.com",
+		"LIC-SECRET-42",
+		"insulin kept refrigerated",
+		"wheelchair ramp",
+		"oauth-code-77",
+		"test-client-secret",
+	} {
+		if strings.Contains(output, secret) {
+			t.Errorf("logs leaked %q", secret)
+		}
+	}
+}
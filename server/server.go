@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fiber-backend/config"
+	"fiber-backend/health"
+	"fiber-backend/mail"
+	"fiber-backend/metrics"
+	"fiber-backend/middleware"
+	"fiber-backend/oidc"
+	"fiber-backend/ratelimit"
+	"fiber-backend/repository"
+	"fiber-backend/routes"
+	"fiber-backend/services"
+	"fiber-backend/tracing"
+	"fiber-backend/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Options are the pieces of the app that differ between production and tests
+type Options struct {
+	Config *config.Config
+	Store  repository.Store
+	Google services.GoogleEndpoints
+	// DB is the pool behind Store, reported in /metrics; nil for in-memory stores
+	DB *sql.DB
+	// Health holds the readiness checks; a checker without checks is used when nil
+	Health *health.Checker
+	// RateLimits keeps the rate limit and lockout counters; an in-memory store
+	// is used when nil. Share one store between instances behind a load balancer.
+	RateLimits ratelimit.Store
+	// Mailer sends the emails; the one cfg.Mail picks is used when nil, which
+	// sends them while the request waits. main.go passes a mail.Queue.
+	Mailer mail.Sender
+}
+
+// New builds the Fiber app with its middleware, services and routes.
+// opts.Config is expected to have been validated already.
+func New(opts Options) (*fiber.App, error) {
+	cfg := opts.Config
+	tokens, err := utils.NewJWTManager(cfg.JWT.Secret, time.Duration(cfg.JWT.TTL))
+	if err != nil {
+		return nil, err
+	}
+	flows, err := utils.NewSealer(cfg.JWT.Secret, "sign-in-flow")
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new Fiber instance with custom config
+	app := fiber.New(fiber.Config{
+		AppName:      "Fiber Auth API",
+		ErrorHandler: utils.ErrorHandler,
+		// c.IP(), which rate limits and the audit log key on, reads the client
+		// address from the proxy header only on requests from a trusted proxy
+		ProxyHeader:             cfg.Proxy.Header,
+		EnableTrustedProxyCheck: cfg.Proxy.Header != "",
+		TrustedProxies:          cfg.Proxy.TrustedProxies,
+		EnableIPValidation:      true,
+	})
+
+	// Probes are registered ahead of the middleware so they are not logged,
+	// traced or counted every few seconds
+	checker := opts.Health
+	if checker == nil {
+		checker = health.NewChecker()
+	}
+	app.Get("/healthz", checker.Liveness)
+	app.Get("/readyz", checker.Readiness)
+
+	// Middleware
+
+	app.Use(middleware.RequestID())
+	app.Use(middleware.Locale())
+	app.Use(tracing.Middleware())
+	app.Use(middleware.AccessLog())
+	app.Use(metrics.Middleware())
+
+	corsConfig := cors.Config{
+		AllowOrigins:     strings.Join(cfg.CORS.AllowedOrigins, ","),
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With,X-CSRF-Token,X-Request-ID,If-Match,traceparent,tracestate",
+		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
+		AllowCredentials: true,
+		ExposeHeaders:    "Content-Length, Authorization, ETag, X-Request-ID",
+		MaxAge:           86400, // 24 hours cache for preflight requests
+	}
+
+	utils.Logger().Debug("CORS configuration",
+		"allowed_origins", corsConfig.AllowOrigins,
+		"allowed_headers", corsConfig.AllowHeaders,
+		"allowed_methods", corsConfig.AllowMethods,
+		"allow_credentials", corsConfig.AllowCredentials,
+	)
+
+	app.Use(cors.New(corsConfig))
+
+	// Rate limits go after CORS so rejected requests still carry its headers
+	var lockout *ratelimit.Lockout
+	if limits := cfg.RateLimit; limits.Enabled {
+		store := opts.RateLimits
+		if store == nil {
+			store = ratelimit.NewMemoryStore()
+		}
+		account := ratelimit.Middleware(store, "account", limit(limits.Account), ratelimit.ByAccount)
+		app.Use("/auth", ratelimit.Middleware(store, "auth", limit(limits.Auth), ratelimit.ByIP))
+		app.Post("/auth/login", account)
+		app.Post("/auth/register", account)
+		// Requests that send email have a budget of their own; checking a
+		// token only counts against /auth
+		email := ratelimit.Middleware(store, "email", limit(limits.Email), ratelimit.ByIP)
+		app.Post("/auth/verify-email/resend", email)
+		app.Post("/auth/password/forgot", email, account)
+		app.Use("/api", ratelimit.Middleware(store, "api", limit(limits.API), ratelimit.ByIP))
+
+		lockout = ratelimit.NewLockout(store, ratelimit.LockoutPolicy{
+			MaxFailures: limits.Lockout.MaxFailures,
+			Window:      time.Duration(limits.Lockout.Window),
+			Duration:    time.Duration(limits.Lockout.Duration),
+			MaxDuration: time.Duration(limits.Lockout.MaxDuration),
+		})
+	}
+
+	registry := metrics.NewRegistry(metrics.Options{
+		DB: opts.DB,
+		PendingRequests: func(ctx context.Context) (int64, error) {
+			return opts.Store.TouristRequests().CountByStatus(ctx, "pending")
+		},
+	})
+	app.Get("/metrics", metrics.Handler(registry))
+
+	// Initialize services
+	providers := oidc.NewRegistry(cfg.OIDCProviders, &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		Timeout:   10 * time.Second,
+	})
+	mailer := opts.Mailer
+	if mailer == nil {
+		if mailer, err = mail.New(cfg.Mail); err != nil {
+			return nil, err
+		}
+	}
+	emails := services.EmailSettings{
+		Mailer:     mailer,
+		VerifyURL:  cfg.VerifyEmailURL(),
+		VerifyTTL:  time.Duration(cfg.EmailVerification.TokenTTL),
+		Restricted: cfg.EmailVerification.Restrict,
+		ResetURL:   cfg.ResetPasswordURL(),
+		ResetTTL:   time.Duration(cfg.PasswordReset.TokenTTL),
+	}
+	authService := services.NewAuthService(opts.Store, tokens, flows, cfg.Google, opts.Google, providers, lockout, emails)
+	driverService := services.NewDriverService(opts.Store)
+	touristService := services.NewTouristService(opts.Store)
+	bookingService := services.NewBookingService(opts.Store)
+
+	// Every route below gets the default deadline unless it sets its own
+	app.Use(middleware.Timeout(time.Duration(cfg.RequestTimeout)))
+
+	// Setup routes
+	utils.LogInfo("Setting up routes")
+	protected := middleware.Protected(tokens, authService)
+	routes.SetupAuthRoutes(app, authService, protected, cfg.RedirectURLs())
+	routes.SetupOIDCRoutes(app, authService, cfg.RedirectURLs())
+	routes.SetupIdentityRoutes(app, authService, protected, cfg.RedirectURLs())
+	routes.SetupVerificationRoutes(app, authService, protected)
+	routes.SetupPasswordRoutes(app, authService, protected)
+	verified := routes.RequireVerifiedEmail(authService)
+	routes.SetupTouristRoutes(app, touristService, protected, verified)
+	routes.SetupDriverRoutes(app, driverService, protected, verified)
+	routes.SetupBookingRoutes(app, bookingService, protected, verified)
+	routes.SetupAdminRoutes(app, authService, protected)
+	routes.SetupErrorRoutes(app)
+
+	return app, nil
+}
+
+func limit(l config.Limit) ratelimit.Limit {
+	return ratelimit.Limit{Requests: l.Requests, Window: time.Duration(l.Window)}
+}
@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"context"
+	"fiber-backend/services"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// The global provider cannot be reset; once shut down it records nothing
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func TestGoogleLoginIsTracedEndToEnd(t *testing.T) {
+	t.Setenv("TEST_DB_DRIVER", "sqlite")
+	recorder := recordSpans(t)
+	h := newHarness(t)
+
+	h.google.authorize("code-ana", services.GoogleUserInfo{ID: "google-ana", Email: "ana@example.com", Name: "Ana"})
+	query, cookie := h.startGoogleLogin(nil)
+	h.google.issue("code-ana", query.Get("code_challenge"))
+	started := len(recorder.Ended())
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callback := googleCallback("code-ana", query.Get("state"), cookie)
+	callback.headers["traceparent"] = "00-" + traceID + "-00f067aa0ba902b7-01"
+	h.send(callback).expect(http.StatusFound)
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended()[started:] {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("span %q is not part of the incoming trace", span.Name())
+		}
+		names[span.Name()] = true
+	}
+
+	for _, want := range []string{
+		"GET /auth/google/callback",
+		"google.login",
+		"google.find_or_create_user",
+		"HTTP POST",
+		"HTTP GET",
+	} {
+		if !names[want] {
+			t.Errorf("missing span %q, got %v", want, names)
+		}
+	}
+	hasQuery := false
+	for name := range names {
+		hasQuery = hasQuery || strings.HasPrefix(name, "db.query users")
+	}
+	if !hasQuery {
+		t.Errorf("no database span for the user lookup, got %v", names)
+	}
+
+	if got := h.google.lastTraceparent(); !strings.Contains(got, traceID) {
+		t.Errorf("outbound calls to Google did not carry the trace context, got %q", got)
+	}
+}
@@ -1,24 +1,349 @@
 package services
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fiber-backend/config"
+	"fiber-backend/i18n"
+	"fiber-backend/metrics"
 	"fiber-backend/models"
+	"fiber-backend/oidc"
+	"fiber-backend/ratelimit"
+	"fiber-backend/repository"
+	"fiber-backend/tracing"
 	"fiber-backend/utils"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
 
-	"gorm.io/gorm"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
 )
 
+var (
+	// ErrInvalidCredentials is returned when the email is unknown or the password is wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrRoleAlreadyAssigned is returned when a user who already has a role tries to pick one
+	ErrRoleAlreadyAssigned = errors.New("user already has a role assigned")
+)
+
+// ErrGoogleDisabled is returned when no Google OAuth client is configured
+var ErrGoogleDisabled = errors.New("google login is not configured")
+
+// ErrOAuthState is returned when a sign-in comes back without the state it was
+// started with, or too late: it may have been started by someone else
+var ErrOAuthState = errors.New("oauth state is missing, expired or does not match")
+
+// ErrLoginCodeInvalid is returned for login codes that are unknown, expired or already used
+var ErrLoginCodeInvalid = errors.New("login code is invalid or expired")
+
+var (
+	// ErrProviderNotFound is returned for sign-ins with an OIDC provider that isn't configured
+	ErrProviderNotFound = errors.New("sign-in provider is not configured")
+	// ErrIdentityEmailUnverified is returned when a provider account would be
+	// linked to an existing user by an email the provider hasn't verified
+	ErrIdentityEmailUnverified = errors.New("provider did not verify the email of an existing user")
+	// ErrAccountEmailUnverified is returned when a provider account would be
+	// linked by email to an existing user who hasn't verified that email
+	ErrAccountEmailUnverified = errors.New("existing user has not verified their email")
+	// ErrIdentityLinkedElsewhere is returned when linking a provider account that
+	// already belongs to another user
+	ErrIdentityLinkedElsewhere = errors.New("provider account is linked to another user")
+	// ErrLastLoginMethod is returned when unlinking the only way a user without a password can sign in
+	ErrLastLoginMethod = errors.New("cannot unlink the last way to sign in")
+	// ErrPasswordAlreadySet is returned when setting a password on a user who has one
+	ErrPasswordAlreadySet = errors.New("user already has a password")
+)
+
+const (
+	// SignInFlowTTL is how long users have to sign in on the provider's side
+	SignInFlowTTL = 10 * time.Minute
+	// LoginCodeTTL is how long the frontend has to exchange a login code
+	LoginCodeTTL = time.Minute
+)
+
+// How a finished sign-in hands the access token to the frontend
+const (
+	// DeliverCode appends a single-use code to the redirect, which the frontend
+	// exchanges for the token at POST /auth/exchange
+	DeliverCode = "code"
+	// DeliverCookie sets the token in an HttpOnly cookie
+	DeliverCookie = "cookie"
+)
+
+// SignInOptions are what a sign-in is started with: the frontend page it
+// returns to and how the token gets there. LinkUserID is set when a signed-in
+// user links a provider account instead.
+type SignInOptions struct {
+	RedirectURL string `json:"redirect_url"`
+	Delivery    string `json:"delivery"`
+	LinkUserID  uint   `json:"link_user_id,omitempty"`
+}
+
+// signInFlow is what the browser keeps, sealed, between leaving for a provider
+// and coming back to its callback
+type signInFlow struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce,omitempty"`
+	SignInOptions
+}
+
+// SignIn is a finished sign-in with Google or an OIDC provider. Code is set for
+// DeliverCode and Token for DeliverCookie.
+type SignIn struct {
+	User        *models.User
+	RedirectURL string
+	Delivery    string
+	Code        string
+	Token       string
+}
+
+// GoogleEndpoints are the Google OAuth URLs the service talks to. Tests point
+// them at a local fake server.
+type GoogleEndpoints struct {
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+}
+
+// GoogleProductionEndpoints are Google's real OAuth endpoints
+var GoogleProductionEndpoints = GoogleEndpoints{
+	AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+	TokenURL:    "https://oauth2.googleapis.com/token",
+	UserInfoURL: "https://www.googleapis.com/oauth2/v2/userinfo",
+}
+
 type AuthService struct {
-	db *gorm.DB
+	store      repository.Store
+	tokens     *utils.JWTManager
+	flows      *utils.Sealer
+	google     config.GoogleConfig
+	oauth      *oauth2.Config
+	endpoints  GoogleEndpoints
+	providers  *oidc.Registry
+	lockout    *ratelimit.Lockout
+	emails     EmailSettings
+	httpClient *http.Client
+}
+
+// NewAuthService builds the service. flows seals the state of Google and OIDC
+// sign-ins in progress. A nil lockout never locks accounts.
+func NewAuthService(store repository.Store, tokens *utils.JWTManager, flows *utils.Sealer, google config.GoogleConfig, endpoints GoogleEndpoints, providers *oidc.Registry, lockout *ratelimit.Lockout, emails EmailSettings) *AuthService {
+	return &AuthService{
+		store:  store,
+		tokens: tokens,
+		flows:  flows,
+		google: google,
+		oauth: google.OAuthConfig(oauth2.Endpoint{
+			AuthURL:   endpoints.AuthURL,
+			TokenURL:  endpoints.TokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		}),
+		endpoints: endpoints,
+		providers: providers,
+		lockout:   lockout,
+		emails:    emails,
+		// Outbound calls get client spans and carry the trace context to the other side
+		httpClient: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// Register creates the user, and their tourist profile when one is given, in one
+// transaction and returns a token for immediate login. Users who don't pick a
+// language keep the one of the request. The user is emailed a link to verify
+// their address; when that fails they can ask for another.
+func (s *AuthService) Register(ctx context.Context, user *models.User, password string, tourist *models.Tourist) (string, error) {
+	if user.Language == "" {
+		user.Language = i18n.FromContext(ctx)
+	}
+
+	// Hash password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		utils.LogErrorContext(ctx, "Failed to hash password: %v", err)
+		return "", err
+	}
+	user.Password = string(hashedPassword)
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().Create(ctx, user); err != nil {
+			utils.LogErrorContext(ctx, "Failed to create user in database: %v", err)
+			return err
+		}
+		utils.LogInfoContext(ctx, "User created successfully in database - ID: %d", user.ID)
+
+		if tourist == nil {
+			return nil
+		}
+		tourist.UserID = user.ID
+		if err := tx.Tourists().Create(ctx, tourist); err != nil {
+			utils.LogErrorContext(ctx, "Failed to create tourist profile: %v", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.sendVerification(ctx, user); err != nil {
+		utils.LogErrorContext(ctx, "Failed to send the verification email to user %d: %v", user.ID, err)
+	}
+	return s.tokens.Generate(user)
+}
+
+// Login checks the credentials and returns the user with a fresh token. Failed
+// attempts count toward locking the account; while it is locked a
+// *ratelimit.LockedError is returned without checking the password. Every
+// outcome is audited with the client's IP.
+func (s *AuthService) Login(ctx context.Context, email, password, ip string) (*models.User, string, error) {
+	account := ratelimit.Account(email)
+	if err := s.lockout.Check(ctx, account); err != nil {
+		var locked *ratelimit.LockedError
+		if errors.As(err, &locked) {
+			s.audit(ctx, models.AuditLoginBlocked, nil, account, ip, "locked until "+locked.Until.UTC().Format(time.RFC3339))
+		}
+		return nil, "", err
+	}
+
+	user, err := s.store.Users().FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.LogErrorContext(ctx, "Login failed - User not found in database: %s", email)
+		return nil, "", s.loginFailed(ctx, nil, account, ip, "unknown email")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	utils.LogInfoContext(ctx, "User found in database - ID: %d, Email: %s", user.ID, user.Email)
+	if !user.HasPassword() {
+		return nil, "", s.loginFailed(ctx, &user.ID, account, ip, "no password set")
+	}
+
+	// Compare passwords
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		utils.LogErrorContext(ctx, "Password comparison failed - Error: %v", err)
+		return nil, "", s.loginFailed(ctx, &user.ID, account, ip, "wrong password")
+	}
+
+	utils.LogInfoContext(ctx, "Password verified successfully")
+
+	token, err := s.tokens.Generate(user)
+	if err != nil {
+		utils.LogErrorContext(ctx, "Failed to generate token for user: %s", user.Email)
+		return nil, "", err
+	}
+	if err := s.lockout.Succeed(ctx, account); err != nil {
+		utils.LogErrorContext(ctx, "Failed to reset login failures of user %d: %v", user.ID, err)
+	}
+	s.audit(ctx, models.AuditLoginSucceeded, &user.ID, account, ip, "")
+	metrics.Logins.WithLabelValues("password", "success").Inc()
+	return user, token, nil
 }
 
-func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{db: db}
+// loginFailed records a failed login and returns the error for the caller:
+// ErrInvalidCredentials, or a *ratelimit.LockedError when this failure locked the account
+func (s *AuthService) loginFailed(ctx context.Context, userID *uint, account, ip, reason string) error {
+	metrics.Logins.WithLabelValues("password", "failure").Inc()
+	s.audit(ctx, models.AuditLoginFailed, userID, account, ip, reason)
+
+	err := s.lockout.Fail(ctx, account)
+	var locked *ratelimit.LockedError
+	if errors.As(err, &locked) {
+		metrics.AccountLockouts.Inc()
+		utils.LogInfoContext(ctx, "Account %s locked until %s after repeated failed logins", account, locked.Until.UTC().Format(time.RFC3339))
+		s.audit(ctx, models.AuditAccountLocked, userID, account, ip, "locked until "+locked.Until.UTC().Format(time.RFC3339))
+		return err
+	}
+	if err != nil {
+		utils.LogErrorContext(ctx, "Failed to record a failed login: %v", err)
+	}
+	return ErrInvalidCredentials
+}
+
+// AuditEvents lists the newest audit events, only those for email unless it is empty
+func (s *AuthService) AuditEvents(ctx context.Context, email string, limit int) ([]models.AuditEvent, error) {
+	return s.store.AuditEvents().List(ctx, ratelimit.Account(email), limit)
+}
+
+// audit records a security event. A failure to write it is logged but does not
+// fail the request that triggered it.
+func (s *AuthService) audit(ctx context.Context, event string, userID *uint, email, ip, detail string) {
+	record := models.AuditEvent{Event: event, UserID: userID, Email: email, IP: ip, Detail: detail}
+	if err := s.store.AuditEvents().Create(ctx, &record); err != nil {
+		utils.LogErrorContext(ctx, "Failed to record audit event %s: %v", event, err)
+	}
+}
+
+// UpdateRole assigns the first role of a user. The user is returned alongside
+// ErrRoleAlreadyAssigned so callers can report the current role.
+func (s *AuthService) UpdateRole(ctx context.Context, userID uint, role string) (*models.User, error) {
+	var user *models.User
+
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		if user, err = tx.Users().FindByID(ctx, userID); err != nil {
+			utils.LogErrorContext(ctx, "Failed to find user %d: %v", userID, err)
+			return err
+		}
+
+		// Check if user already has a role
+		if user.Role != "" {
+			utils.LogInfoContext(ctx, "User %d already has role: %s", userID, user.Role)
+			return ErrRoleAlreadyAssigned
+		}
+
+		user.Role = role
+		if err := tx.Users().Update(ctx, user); err != nil {
+			utils.LogErrorContext(ctx, "Failed to update role for user %d to %s: %v", userID, role, err)
+			return err
+		}
+		return nil
+	})
+	return user, err
+}
+
+// UpdateLanguage stores the language the user wants API messages in and returns
+// the user with a token carrying it
+func (s *AuthService) UpdateLanguage(ctx context.Context, userID uint, language string) (*models.User, string, error) {
+	user, err := s.store.Users().FindByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user.Language = language
+	if err := s.store.Users().Update(ctx, user); err != nil {
+		utils.LogErrorContext(ctx, "Failed to update language for user %d to %s: %v", userID, language, err)
+		return nil, "", err
+	}
+
+	token, err := s.tokens.Generate(user)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// GetUser retrieves a user by ID
+func (s *AuthService) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	return s.store.Users().FindByID(ctx, id)
 }
 
 type GoogleUserInfo struct {
@@ -31,61 +356,249 @@ type GoogleUserInfo struct {
 	Picture       string `json:"picture"`
 }
 
-func (s *AuthService) HandleGoogleAuth(code string) (*models.User, string, error) {
+// GoogleAuthURL starts a Google sign-in with the given options, whose redirect
+// URL must already be checked against the allowlist. It returns where to send
+// the user and the sealed flow, a random state and PKCE verifier, that the
+// browser must bring back to the callback.
+func (s *AuthService) GoogleAuthURL(options SignInOptions) (authURL, flow string, err error) {
+	if s.google.ClientID == "" {
+		return "", "", ErrGoogleDisabled
+	}
+	started := signInFlow{
+		Provider:      "google",
+		State:         utils.GenerateRandomToken(),
+		Verifier:      oauth2.GenerateVerifier(),
+		SignInOptions: options,
+	}
+	flow, err = s.flows.Seal(started, SignInFlowTTL)
+	if err != nil {
+		return "", "", err
+	}
+	return s.oauth.AuthCodeURL(started.State, oauth2.S256ChallengeOption(started.Verifier)), flow, nil
+}
+
+// HandleGoogleAuth signs in the Google user behind an authorization code. state
+// is the one Google sent back and flow the one GoogleAuthURL returned; a sign-in
+// whose state doesn't match fails with ErrOAuthState. Accounts are linked like
+// in HandleOIDCCallback. Each step gets its own span under ctx so slow logins
+// can be pinned down.
+func (s *AuthService) HandleGoogleAuth(ctx context.Context, code, state, flow, ip string) (*SignIn, error) {
+	if s.google.ClientID == "" {
+		return nil, ErrGoogleDisabled
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "google.login")
+	defer span.End()
+
+	started, err := s.openFlow("google", state, flow)
+	if err != nil {
+		return nil, signInFailure(span, "google", "state", err)
+	}
+
 	// Exchange code for tokens
-	token, err := s.getGoogleToken(code)
+	token, err := s.getGoogleToken(ctx, code, started.Verifier)
 	if err != nil {
-		return nil, "", err
+		return nil, signInFailure(span, "google", "token_exchange", err)
 	}
 
 	// Get user info from Google
-	userInfo, err := s.getGoogleUserInfo(token)
+	userInfo, err := s.getGoogleUserInfo(ctx, token)
 	if err != nil {
-		return nil, "", err
+		return nil, signInFailure(span, "google", "userinfo", err)
 	}
 
 	// Find or create user
-	user, err := s.findOrCreateGoogleUser(userInfo)
+	user, err := s.signInIdentity(ctx, &oidc.Identity{
+		Provider:      "google",
+		Subject:       userInfo.ID,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.VerifiedEmail,
+		Name:          userInfo.Name,
+	}, started.LinkUserID, ip)
 	if err != nil {
-		return nil, "", err
+		return nil, signInFailure(span, "google", "account", err)
 	}
 
-	// Generate JWT
-	jwtToken, err := utils.GenerateJWT(user)
+	signIn, err := s.finishSignIn(ctx, user, started)
 	if err != nil {
-		return nil, "", err
+		return nil, signInFailure(span, "google", "token", err)
 	}
 
-	return user, jwtToken, nil
+	metrics.Logins.WithLabelValues("google", "success").Inc()
+	return signIn, nil
 }
 
-func (s *AuthService) getGoogleToken(code string) (string, error) {
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
-	redirectURI := os.Getenv("GOOGLE_REDIRECT_URI")
+// GoogleEnabled reports whether users can sign in with Google
+func (s *AuthService) GoogleEnabled() bool {
+	return s.google.ClientID != ""
+}
 
-	url := "https://oauth2.googleapis.com/token"
-	data := fmt.Sprintf(
-		"code=%s&client_id=%s&client_secret=%s&redirect_uri=%s&grant_type=authorization_code",
-		code, clientID, clientSecret, redirectURI,
-	)
+// Providers lists the OIDC providers users can sign in with
+func (s *AuthService) Providers() []string {
+	return s.providers.Names()
+}
 
-	resp, err := http.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data))
+// OIDCAuthURL starts a sign-in with the named OIDC provider, like GoogleAuthURL
+// does for Google. The flow also carries the nonce the ID token must echo.
+func (s *AuthService) OIDCAuthURL(ctx context.Context, providerName string, options SignInOptions) (authURL, flow string, err error) {
+	provider := s.providers.Get(providerName)
+	if provider == nil {
+		return "", "", ErrProviderNotFound
+	}
+	started := signInFlow{
+		Provider:      providerName,
+		State:         utils.GenerateRandomToken(),
+		Verifier:      oauth2.GenerateVerifier(),
+		Nonce:         utils.GenerateRandomToken(),
+		SignInOptions: options,
+	}
+	if authURL, err = provider.AuthURL(ctx, started.State, started.Verifier, started.Nonce); err != nil {
+		return "", "", err
+	}
+	if flow, err = s.flows.Seal(started, SignInFlowTTL); err != nil {
+		return "", "", err
+	}
+	return authURL, flow, nil
+}
+
+// HandleOIDCCallback signs in the user behind an authorization code from the
+// named provider, linking the provider account to a user the first time (see
+// signInIdentity). It fails with ErrOAuthState like HandleGoogleAuth.
+func (s *AuthService) HandleOIDCCallback(ctx context.Context, providerName, code, state, flow, ip string) (*SignIn, error) {
+	provider := s.providers.Get(providerName)
+	if provider == nil {
+		return nil, ErrProviderNotFound
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "oidc.login", trace.WithAttributes(attribute.String("oidc.provider", providerName)))
+	defer span.End()
+
+	started, err := s.openFlow(providerName, state, flow)
 	if err != nil {
-		return "", err
+		return nil, signInFailure(span, providerName, "state", err)
 	}
-	defer resp.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	identity, err := provider.Exchange(ctx, code, started.Verifier, started.Nonce)
+	if err != nil {
+		return nil, signInFailure(span, providerName, "id_token", err)
+	}
+
+	user, err := s.signInIdentity(ctx, identity, started.LinkUserID, ip)
+	if err != nil {
+		return nil, signInFailure(span, providerName, "account", err)
+	}
+
+	signIn, err := s.finishSignIn(ctx, user, started)
+	if err != nil {
+		return nil, signInFailure(span, providerName, "token", err)
+	}
+
+	metrics.Logins.WithLabelValues(providerName, "success").Inc()
+	return signIn, nil
+}
+
+// openFlow unseals the flow a sign-in with provider was started with, failing
+// with ErrOAuthState unless state is the one it was started with
+func (s *AuthService) openFlow(provider, state, flow string) (*signInFlow, error) {
+	var started signInFlow
+	if err := s.flows.Open(flow, &started); err != nil || state == "" || started.Provider != provider ||
+		subtle.ConstantTimeCompare([]byte(state), []byte(started.State)) != 1 {
+		return nil, ErrOAuthState
+	}
+	return &started, nil
+}
+
+// finishSignIn gets the user's token ready to be delivered the way the flow asked for
+func (s *AuthService) finishSignIn(ctx context.Context, user *models.User, started *signInFlow) (*SignIn, error) {
+	signIn := &SignIn{User: user, RedirectURL: started.RedirectURL, Delivery: started.Delivery}
+	var err error
+	if started.Delivery == DeliverCookie {
+		signIn.Token, err = s.tokens.Generate(user)
+	} else {
+		signIn.Code, err = s.IssueLoginCode(ctx, user.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signIn, nil
+}
+
+// IssueLoginCode returns a single-use code the frontend exchanges for a token
+// of the user within LoginCodeTTL
+func (s *AuthService) IssueLoginCode(ctx context.Context, userID uint) (string, error) {
+	now := time.Now()
+	// Codes that were never exchanged are swept as new ones are issued
+	if err := s.store.LoginCodes().DeleteExpired(ctx, now); err != nil {
 		return "", err
 	}
+	code := utils.GenerateRandomToken()
+	record := models.LoginCode{CodeHash: hashToken(code), UserID: userID, ExpiresAt: now.Add(LoginCodeTTL)}
+	if err := s.store.LoginCodes().Create(ctx, &record); err != nil {
+		return "", err
+	}
+	return code, nil
+}
 
-	return result["access_token"].(string), nil
+// ExchangeLoginCode trades a login code for its user and a fresh token. Unknown,
+// expired and already used codes fail with ErrLoginCodeInvalid.
+func (s *AuthService) ExchangeLoginCode(ctx context.Context, code string) (*models.User, string, error) {
+	record, err := s.store.LoginCodes().Consume(ctx, hashToken(code), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", ErrLoginCodeInvalid
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	user, err := s.store.Users().FindByID(ctx, record.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := s.tokens.Generate(user)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// TokenTTL is how long the tokens the service issues stay valid
+func (s *AuthService) TokenTTL() time.Duration {
+	return s.tokens.TTL()
+}
+
+// hashToken is how login codes and emailed tokens are stored
+func hashToken(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// signInFailure records a sign-in with provider that failed at the given stage and returns err
+func signInFailure(span trace.Span, provider, stage string, err error) error {
+	metrics.OAuthFailures.WithLabelValues(provider, stage).Inc()
+	metrics.Logins.WithLabelValues(provider, "failure").Inc()
+	span.SetAttributes(attribute.String("signin.failed_stage", stage))
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
+// getGoogleToken exchanges the code, proving with the PKCE verifier that this
+// server started the sign-in
+func (s *AuthService) getGoogleToken(ctx context.Context, code, verifier string) (string, error) {
+	// The oauth2 package makes its calls with the client found in the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.httpClient)
+	token, err := s.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return "", fmt.Errorf("google token exchange failed: %w", err)
+	}
+	return token.AccessToken, nil
 }
 
-func (s *AuthService) getGoogleUserInfo(token string) (*GoogleUserInfo, error) {
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + token)
+func (s *AuthService) getGoogleUserInfo(ctx context.Context, token string) (*GoogleUserInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoints.UserInfoURL+"?access_token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -95,6 +608,10 @@ func (s *AuthService) getGoogleUserInfo(token string) (*GoogleUserInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Error bodies are JSON too, and would decode into an empty profile
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("google userinfo responded %s: %s", resp.Status, body)
+	}
 
 	var userInfo GoogleUserInfo
 	if err := json.Unmarshal(body, &userInfo); err != nil {
@@ -104,47 +621,155 @@ func (s *AuthService) getGoogleUserInfo(token string) (*GoogleUserInfo, error) {
 	return &userInfo, nil
 }
 
-func (s *AuthService) findOrCreateGoogleUser(userInfo *GoogleUserInfo) (*models.User, error) {
-	var user models.User
-
-	// Try to find existing user by Google ID first
-	result := s.db.Where("google_id = ?", userInfo.ID).First(&user)
-	if result.Error == nil {
-		utils.LogInfo("Found existing user by Google ID: %s", user.Email)
-		return &user, nil
+// signInIdentity returns the user the provider account is linked to. A flow
+// started to link links it to that user, unless it belongs to someone else
+// (ErrIdentityLinkedElsewhere). Otherwise the first sign-in links it to the
+// user with the same email, or to a new user without a password when there is
+// none. Linking by email needs the provider to have verified it
+// (ErrIdentityEmailUnverified), or anyone who can register that address at
+// the provider could take the account, and the user to have verified it too
+// (ErrAccountEmailUnverified), or whoever registered the address here first
+// would share the account with its owner; they sign in and link explicitly
+// instead. A verified email also verifies the user's own when they match.
+func (s *AuthService) signInIdentity(ctx context.Context, identity *oidc.Identity, linkUserID uint, ip string) (*models.User, error) {
+	// Named after the login span it is part of, google.login or oidc.login
+	spanName := "oidc.find_or_create_user"
+	if identity.Provider == "google" {
+		spanName = "google.find_or_create_user"
 	}
+	ctx, span := tracing.Tracer().Start(ctx, spanName)
+	defer span.End()
+
+	var user *models.User
+	linked, created := false, false
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		existing, err := tx.Identities().FindBySubject(ctx, identity.Provider, identity.Subject)
+		if err == nil {
+			if linkUserID != 0 && existing.UserID != linkUserID {
+				return ErrIdentityLinkedElsewhere
+			}
+			user, err = tx.Users().FindByID(ctx, existing.UserID)
+			return err
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		switch {
+		case linkUserID != 0:
+			if user, err = tx.Users().FindByID(ctx, linkUserID); err != nil {
+				return err
+			}
+		case identity.Email == "":
+			return fmt.Errorf("%s did not share an email for subject %s", identity.Provider, identity.Subject)
+		default:
+			user, err = tx.Users().FindByEmail(ctx, identity.Email)
+			if err == nil && !identity.EmailVerified {
+				utils.LogInfoContext(ctx, "Refused to link %s account to user %d by an unverified email", identity.Provider, user.ID)
+				return ErrIdentityEmailUnverified
+			}
+			if err == nil && !user.EmailVerified() {
+				utils.LogInfoContext(ctx, "Refused to link %s account to user %d, who hasn't verified their email", identity.Provider, user.ID)
+				return ErrAccountEmailUnverified
+			}
+			if errors.Is(err, repository.ErrNotFound) {
+				user = &models.User{Email: identity.Email, Name: identity.Name, Language: i18n.FromContext(ctx)}
+				err = tx.Users().Create(ctx, user)
+				created = err == nil
+				utils.LogInfoContext(ctx, "Created new user from %s sign-in", identity.Provider)
+			}
+			if err != nil {
+				return err
+			}
+		}
 
-	// If not found by Google ID, try to find by email
-	result = s.db.Where("email = ?", userInfo.Email).First(&user)
-	if result.Error == nil {
-		// Update existing user with Google ID
-		googleID := userInfo.ID
-		user.GoogleID = &googleID
-		if err := s.db.Save(&user).Error; err != nil {
-			utils.LogError("Failed to update existing user with Google ID: %v", err)
-			return nil, err
+		link := models.UserIdentity{UserID: user.ID, Provider: identity.Provider, Subject: identity.Subject, Email: identity.Email}
+		if err := tx.Identities().Create(ctx, &link); err != nil {
+			return err
+		}
+		linked = true
+		return nil
+	})
+	switch {
+	case err != nil || user.EmailVerified():
+	case identity.EmailVerified && strings.EqualFold(identity.Email, user.Email):
+		// The provider already confirmed the user owns the address
+		err = s.markEmailVerified(ctx, user, ip, identity.Provider)
+	case created:
+		if err := s.sendVerification(ctx, user); err != nil {
+			utils.LogErrorContext(ctx, "Failed to send the verification email to user %d: %v", user.ID, err)
 		}
-		utils.LogInfo("Updated existing user with Google ID: %s", user.Email)
-		return &user, nil
 	}
+	if err != nil {
+		utils.LogErrorContext(ctx, "Failed to find or create user for %s sign-in: %v", identity.Provider, err)
+		return nil, err
+	}
+	if linked {
+		utils.LogInfoContext(ctx, "Linked %s account to user %d", identity.Provider, user.ID)
+		s.audit(ctx, models.AuditIdentityLinked, &user.ID, user.Email, ip, identity.Provider)
+	}
+	return user, nil
+}
+
+// Identities lists the provider accounts linked to the user
+func (s *AuthService) Identities(ctx context.Context, userID uint) ([]models.UserIdentity, error) {
+	return s.store.Identities().ListByUser(ctx, userID)
+}
 
-	// Generate a random password for the new user
-	randomPassword := utils.GenerateRandomPassword()
+// UnlinkIdentity removes one of the user's provider accounts. Identities of
+// other users are ErrNotFound, and a user without a password keeps their last
+// one (ErrLastLoginMethod).
+func (s *AuthService) UnlinkIdentity(ctx context.Context, userID, identityID uint, ip string) error {
+	var user *models.User
+	var unlinked models.UserIdentity
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		identities, err := tx.Identities().ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		index := slices.IndexFunc(identities, func(identity models.UserIdentity) bool { return identity.ID == identityID })
+		if index < 0 {
+			return repository.ErrNotFound
+		}
+		unlinked = identities[index]
 
-	// Create new user if not found
-	googleID := userInfo.ID
-	newUser := models.User{
-		Email:    userInfo.Email,
-		Name:     userInfo.Name,
-		GoogleID: &googleID,
-		Password: randomPassword, // Set the random password
+		if user, err = tx.Users().FindByID(ctx, userID); err != nil {
+			return err
+		}
+		if !user.HasPassword() && len(identities) == 1 {
+			return ErrLastLoginMethod
+		}
+		return tx.Identities().Delete(ctx, identityID)
+	})
+	if err != nil {
+		return err
 	}
+	utils.LogInfoContext(ctx, "Unlinked %s account from user %d", unlinked.Provider, userID)
+	s.audit(ctx, models.AuditIdentityUnlinked, &userID, user.Email, ip, unlinked.Provider)
+	return nil
+}
 
-	if err := s.db.Create(&newUser).Error; err != nil {
-		utils.LogError("Failed to create new user from Google OAuth: %v", err)
-		return nil, err
+// SetPassword gives a user who signed up through a provider a password to sign
+// in with. Users who have one already get ErrPasswordAlreadySet.
+func (s *AuthService) SetPassword(ctx context.Context, userID uint, password, ip string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return err
 	}
 
-	utils.LogInfo("Created new user from Google OAuth: %s", newUser.Email)
-	return &newUser, nil
+	var user *models.User
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if user, err = tx.Users().FindByID(ctx, userID); err != nil {
+			return err
+		}
+		if user.HasPassword() {
+			return ErrPasswordAlreadySet
+		}
+		return tx.Users().UpdatePassword(ctx, userID, string(hashedPassword))
+	})
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, models.AuditPasswordSet, &userID, user.Email, ip, "")
+	return nil
 }
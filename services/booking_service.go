@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/metrics"
+	"fiber-backend/models"
+	"fiber-backend/repository"
+	"time"
+)
+
+var (
+	// ErrUnknownDriver is returned when a booking names a driver that doesn't exist
+	ErrUnknownDriver = errors.New("unknown driver")
+	// ErrNotBookingParty is returned when a user who is neither the tourist nor
+	// the driver of a booking reads or changes it
+	ErrNotBookingParty = errors.New("user is not the tourist or driver of the booking")
+)
+
+type BookingService struct {
+	store repository.Store
+}
+
+func NewBookingService(store repository.Store) *BookingService {
+	return &BookingService{store: store}
+}
+
+// GetBooking retrieves a booking with its driver and tourist
+func (s *BookingService) GetBooking(ctx context.Context, id uint) (*models.Booking, error) {
+	return s.store.Bookings().FindByID(ctx, id)
+}
+
+// GetBookingForUser retrieves a booking the user is the tourist or driver of.
+// Other users get ErrNotBookingParty.
+func (s *BookingService) GetBookingForUser(ctx context.Context, id, userID uint) (*models.Booking, error) {
+	booking, err := s.store.Bookings().FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Tourist.UserID != userID && booking.Driver.UserID != userID {
+		return nil, ErrNotBookingParty
+	}
+	return booking, nil
+}
+
+// GetTouristBookings retrieves the bookings of the tourist owned by userID
+func (s *BookingService) GetTouristBookings(ctx context.Context, userID uint) ([]models.Booking, error) {
+	tourist, err := s.store.Tourists().FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Bookings().ListByTourist(ctx, tourist.ID)
+}
+
+// GetDriverBookings retrieves the bookings of a driver, which only the driver's
+// own user may list; others get ErrNotBookingParty
+func (s *BookingService) GetDriverBookings(ctx context.Context, driverID, userID uint) ([]models.Booking, error) {
+	driver, err := s.store.Drivers().FindByID(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	if driver.UserID != userID {
+		return nil, ErrNotBookingParty
+	}
+	return s.store.Bookings().ListByDriver(ctx, driverID)
+}
+
+// CreateBooking stores a new pending booking for the tourist owned by userID
+// and returns it with its relationships. Returns repository.ErrNotFound when
+// the user has no tourist profile.
+func (s *BookingService) CreateBooking(ctx context.Context, userID uint, booking *models.Booking) (*models.Booking, error) {
+	tourist, err := s.store.Tourists().FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	booking.TouristID = tourist.ID
+	if _, err := s.store.Drivers().FindByID(ctx, booking.DriverID); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUnknownDriver
+	} else if err != nil {
+		return nil, err
+	}
+
+	booking.BookedAt = time.Now()
+	booking.Status = "pending"
+
+	if err := s.store.Bookings().Create(ctx, booking); err != nil {
+		return nil, err
+	}
+	metrics.BookingsCreated.Inc()
+	return s.store.Bookings().FindByID(ctx, booking.ID)
+}
+
+// UpdateStatus changes the status of a booking as it was read.
+// Returns repository.ErrVersionConflict if the booking changed since it was read.
+func (s *BookingService) UpdateStatus(ctx context.Context, booking *models.Booking, status string) (*models.Booking, error) {
+	updated := *booking
+	updated.Status = status
+	if err := s.store.Bookings().Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+	if status == "completed" && booking.Status != "completed" {
+		metrics.BookingsCompleted.Inc()
+	}
+	return &updated, nil
+}
@@ -1,69 +1,100 @@
 package services
 
 import (
+	"context"
 	"fiber-backend/models"
-	"fmt"
-
-	"gorm.io/gorm"
+	"fiber-backend/repository"
+	"strings"
 )
 
+// DriverProfileChanges are the fields a driver changes on their profile; nil
+// fields are left as they are
+type DriverProfileChanges struct {
+	LicenseNumber *string
+	VehicleType   *string
+	VehicleModel  *string
+	VehicleColor  *string
+	Languages     []string
+	Experience    *int
+	PhotoURL      *string
+}
+
 type DriverService struct {
-	db *gorm.DB
+	store repository.Store
 }
 
-func NewDriverService(db *gorm.DB) *DriverService {
-	return &DriverService{db: db}
+func NewDriverService(store repository.Store) *DriverService {
+	return &DriverService{store: store}
 }
 
 // CreateDriver creates a new driver profile
-func (s *DriverService) CreateDriver(driver *models.Driver) error {
+func (s *DriverService) CreateDriver(ctx context.Context, driver *models.Driver) error {
 	driver.Status = "active"
-	return s.db.Create(driver).Error
+	return s.store.Drivers().Create(ctx, driver)
 }
 
 // GetDriverByUserID retrieves a driver by user ID
-func (s *DriverService) GetDriverByUserID(userID uint) (*models.Driver, error) {
-	var driver models.Driver
-	err := s.db.Where("user_id = ?", userID).First(&driver).Error
-	if err != nil {
-		return nil, err
-	}
-	return &driver, nil
+func (s *DriverService) GetDriverByUserID(ctx context.Context, userID uint) (*models.Driver, error) {
+	return s.store.Drivers().FindByUserID(ctx, userID)
+}
+
+// GetDriverByID retrieves a driver by its ID along with its user
+func (s *DriverService) GetDriverByID(ctx context.Context, id uint) (*models.Driver, error) {
+	return s.store.Drivers().FindByID(ctx, id)
 }
 
 // GetAllDrivers retrieves all drivers
-func (s *DriverService) GetAllDrivers() ([]models.Driver, error) {
-	var drivers []models.Driver
-	err := s.db.Find(&drivers).Error
-	return drivers, err
+func (s *DriverService) GetAllDrivers(ctx context.Context) ([]models.Driver, error) {
+	return s.store.Drivers().List(ctx)
 }
 
 // GetAvailableDrivers retrieves all available and active drivers
-func (s *DriverService) GetAvailableDrivers() ([]models.Driver, error) {
-	var drivers []models.Driver
-	err := s.db.Preload("User").Where("status = ?", "active").Find(&drivers).Error
-	if err != nil {
-		return nil, err
-	}
+func (s *DriverService) GetAvailableDrivers(ctx context.Context) ([]models.Driver, error) {
+	return s.store.Drivers().ListByStatus(ctx, "active")
+}
 
-	// Format drivers for frontend
-	for i := range drivers {
-		if drivers[i].User.Name == "" {
-			drivers[i].User.Name = "Driver " + fmt.Sprint(drivers[i].ID) // Default name if empty
-		}
+// UpdateDriverAvailability updates a driver's availability status.
+// Returns repository.ErrVersionConflict if the driver changed since it was read.
+func (s *DriverService) UpdateDriverAvailability(ctx context.Context, driver *models.Driver, isAvailable bool) (*models.Driver, error) {
+	updated := *driver
+	updated.IsAvailable = isAvailable
+	if err := s.store.Drivers().Update(ctx, &updated); err != nil {
+		return nil, err
 	}
-
-	return drivers, nil
+	return &updated, nil
 }
 
-// UpdateDriver updates a driver's profile
-func (s *DriverService) UpdateDriver(driver *models.Driver) error {
-	return s.db.Save(driver).Error
-}
+// UpdateDriverProfile applies a partial update to the driver as it was read.
+// Changing the license number sends the profile back to verification.
+// Returns repository.ErrVersionConflict if the driver changed since it was read.
+func (s *DriverService) UpdateDriverProfile(ctx context.Context, driver *models.Driver, update DriverProfileChanges) (*models.Driver, error) {
+	updated := *driver
 
-// UpdateDriverAvailability updates a driver's availability status
-func (s *DriverService) UpdateDriverAvailability(userID uint, isAvailable bool) error {
-	return s.db.Model(&models.Driver{}).
-		Where("user_id = ?", userID).
-		Update("is_available", isAvailable).Error
+	if update.LicenseNumber != nil && strings.TrimSpace(*update.LicenseNumber) != driver.LicenseNumber {
+		updated.LicenseNumber = strings.TrimSpace(*update.LicenseNumber)
+		updated.Status = "pending"
+	}
+	if update.VehicleType != nil {
+		updated.VehicleType = strings.TrimSpace(*update.VehicleType)
+	}
+	if update.VehicleModel != nil {
+		updated.VehicleModel = strings.TrimSpace(*update.VehicleModel)
+	}
+	if update.VehicleColor != nil {
+		updated.VehicleColor = strings.TrimSpace(*update.VehicleColor)
+	}
+	if update.Languages != nil {
+		updated.Languages = strings.Join(update.Languages, ",")
+	}
+	if update.Experience != nil {
+		updated.Experience = *update.Experience
+	}
+	if update.PhotoURL != nil {
+		updated.PhotoURL = strings.TrimSpace(*update.PhotoURL)
+	}
+
+	if err := s.store.Drivers().Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
 }
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/i18n"
+	"fiber-backend/mail"
+	"fiber-backend/models"
+	"fiber-backend/repository"
+	"fiber-backend/utils"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrVerificationTokenInvalid is returned for verification tokens that are
+	// unknown, expired, replaced by a newer one or already used
+	ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	// ErrEmailAlreadyVerified is returned when asking to verify an address twice
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	// ErrEmailUnverified is returned when a user who hasn't verified their email
+	// uses a feature that requires it
+	ErrEmailUnverified = errors.New("email is not verified")
+)
+
+// EmailSettings are how AuthService emails users and what users can't do until
+// they confirm their address
+type EmailSettings struct {
+	Mailer mail.Sender
+	// VerifyURL is the frontend page verification links open, with ?token= added
+	VerifyURL string
+	VerifyTTL time.Duration
+	// Restricted lists the config.Feature* names unverified users are kept from
+	Restricted []string
+	// ResetURL is the frontend page password reset links open, with ?token= added
+	ResetURL string
+	ResetTTL time.Duration
+}
+
+// sendVerification emails the user a link to verify their address. Links sent
+// before stop working.
+func (s *AuthService) sendVerification(ctx context.Context, user *models.User) error {
+	token, err := s.issueEmailToken(ctx, user.ID, models.TokenVerifyEmail, s.emails.VerifyTTL)
+	if err != nil {
+		return err
+	}
+	return s.sendLink(ctx, user, s.emails.VerifyURL, token, "emails.verify_subject", "emails.verify_body")
+}
+
+// sendLink emails the user a link to page with the token in ?token=, in their
+// language or else the request's. bodyKey takes the link as its argument.
+func (s *AuthService) sendLink(ctx context.Context, user *models.User, page, token, subjectKey, bodyKey string) error {
+	link, err := url.Parse(page)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	language := user.Language
+	if language == "" {
+		language = i18n.FromContext(ctx)
+	}
+	return s.emails.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: i18n.T(language, subjectKey),
+		Text:    i18n.T(language, i18n.Key(bodyKey, link.String())),
+	})
+}
+
+// issueEmailToken stores a new token for purpose in place of the user's older
+// ones and returns it. Only its hash is kept.
+func (s *AuthService) issueEmailToken(ctx context.Context, userID uint, purpose string, ttl time.Duration) (string, error) {
+	token := utils.GenerateRandomToken()
+	now := time.Now()
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.EmailTokens().DeleteExpired(ctx, now); err != nil {
+			return err
+		}
+		if err := tx.EmailTokens().DeleteByUser(ctx, userID, purpose); err != nil {
+			return err
+		}
+		record := models.EmailToken{TokenHash: hashToken(token), UserID: userID, Purpose: purpose, ExpiresAt: now.Add(ttl)}
+		return tx.EmailTokens().Create(ctx, &record)
+	})
+	return token, err
+}
+
+// VerifyEmail marks the address of the user a verification token was sent to
+// as verified and returns the user
+func (s *AuthService) VerifyEmail(ctx context.Context, token, ip string) (*models.User, error) {
+	record, err := s.store.EmailTokens().Consume(ctx, models.TokenVerifyEmail, hashToken(token), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().FindByID(ctx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified() {
+		if err := s.markEmailVerified(ctx, user, ip, "link"); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ResendVerification emails the user a new verification link, or fails with
+// ErrEmailAlreadyVerified
+func (s *AuthService) ResendVerification(ctx context.Context, userID uint) error {
+	user, err := s.store.Users().FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// CheckEmailVerified returns ErrEmailUnverified when feature is restricted to
+// verified users and the user isn't one
+func (s *AuthService) CheckEmailVerified(ctx context.Context, userID uint, feature string) error {
+	if !slices.Contains(s.emails.Restricted, feature) {
+		return nil
+	}
+	user, err := s.store.Users().FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerified() {
+		return ErrEmailUnverified
+	}
+	return nil
+}
+
+// markEmailVerified records that the user owns their address; how says whether
+// through a link or which provider vouched for it
+func (s *AuthService) markEmailVerified(ctx context.Context, user *models.User, ip, how string) error {
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.store.Users().Update(ctx, user); err != nil {
+		return err
+	}
+	utils.LogInfoContext(ctx, "Verified the email of user %d by %s", user.ID, how)
+	s.audit(ctx, models.AuditEmailVerified, &user.ID, user.Email, ip, how)
+	return nil
+}
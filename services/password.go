@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/models"
+	"fiber-backend/ratelimit"
+	"fiber-backend/repository"
+	"fiber-backend/utils"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrResetTokenInvalid is returned for password reset tokens that are
+	// unknown, expired, replaced by a newer one or already used
+	ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+	// ErrPasswordIncorrect is returned when the current password given to change it is wrong
+	ErrPasswordIncorrect = errors.New("current password is incorrect")
+	// ErrNoPassword is returned when changing the password of a user who has
+	// none; they set one instead
+	ErrNoPassword = errors.New("user has no password to change")
+)
+
+// RequestPasswordReset emails the user with the address a link to reset their
+// password. Unknown addresses are only audited and mail failures only logged,
+// so callers answer the same whether or not the address is registered. The
+// mailer is expected to queue the message rather than wait for the mail server,
+// which would give registered addresses away by how long they take.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email, ip string) error {
+	account := ratelimit.Account(email)
+	user, err := s.store.Users().FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Sweeping expired tokens stands in for issuing one, so both answers
+		// take about as long
+		if err := s.store.EmailTokens().DeleteExpired(ctx, time.Now()); err != nil {
+			return err
+		}
+		s.audit(ctx, models.AuditPasswordResetRequested, nil, account, ip, "unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.issueEmailToken(ctx, user.ID, models.TokenResetPassword, s.emails.ResetTTL)
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, models.AuditPasswordResetRequested, &user.ID, user.Email, ip, "")
+	if err := s.sendLink(ctx, user, s.emails.ResetURL, token, "emails.reset_subject", "emails.reset_body"); err != nil {
+		utils.LogErrorContext(ctx, "Failed to send the password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets the password of the user a reset token was sent to and
+// signs them out everywhere
+func (s *AuthService) ResetPassword(ctx context.Context, token, password, ip string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return err
+	}
+
+	var user *models.User
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		record, err := tx.EmailTokens().Consume(ctx, models.TokenResetPassword, hashToken(token), time.Now())
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		if err := replacePassword(ctx, tx, record.UserID, string(hashedPassword)); err != nil {
+			return err
+		}
+		user, err = tx.Users().FindByID(ctx, record.UserID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	utils.LogInfoContext(ctx, "Reset the password of user %d", user.ID)
+	s.audit(ctx, models.AuditPasswordReset, &user.ID, user.Email, ip, "")
+	return nil
+}
+
+// ChangePassword replaces the user's password after checking the current one,
+// signs them out everywhere and returns a token for the new session. A wrong
+// current password counts toward locking the account like a failed login, and
+// while it is locked a *ratelimit.LockedError is returned.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uint, current, password, ip string) (*models.User, string, error) {
+	user, err := s.store.Users().FindByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.HasPassword() {
+		return nil, "", ErrNoPassword
+	}
+	account := ratelimit.Account(user.Email)
+	if err := s.lockout.Check(ctx, account); err != nil {
+		return nil, "", err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)); err != nil {
+		err = s.loginFailed(ctx, &user.ID, account, ip, "wrong current password")
+		if errors.Is(err, ErrInvalidCredentials) {
+			return nil, "", ErrPasswordIncorrect
+		}
+		return nil, "", err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := replacePassword(ctx, tx, userID, string(hashedPassword)); err != nil {
+			return err
+		}
+		user, err = tx.Users().FindByID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	utils.LogInfoContext(ctx, "Changed the password of user %d", user.ID)
+	s.audit(ctx, models.AuditPasswordChanged, &user.ID, user.Email, ip, "")
+
+	token, err := s.tokens.Generate(user)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// SessionValid reports whether tokens issued to the user for version still
+// work: the user exists and hasn't changed their password since
+func (s *AuthService) SessionValid(ctx context.Context, userID, version uint) (bool, error) {
+	user, err := s.store.Users().FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.SessionVersion == version, nil
+}
+
+// replacePassword stores the new password hash and revokes the user's
+// sessions: the access tokens issued before stop working, and the login codes
+// and reset tokens that could still be traded for a session are dropped
+func replacePassword(ctx context.Context, tx repository.Store, userID uint, passwordHash string) error {
+	if err := tx.Users().UpdatePassword(ctx, userID, passwordHash); err != nil {
+		return err
+	}
+	if err := tx.Users().RevokeSessions(ctx, userID); err != nil {
+		return err
+	}
+	if err := tx.LoginCodes().DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	return tx.EmailTokens().DeleteByUser(ctx, userID, models.TokenResetPassword)
+}
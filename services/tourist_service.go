@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fiber-backend/metrics"
+	"fiber-backend/models"
+	"fiber-backend/repository"
+	"time"
+)
+
+// ErrDriverUnavailable is returned when booking a driver who is already taken
+var ErrDriverUnavailable = errors.New("driver is not available")
+
+type TouristService struct {
+	store repository.Store
+}
+
+func NewTouristService(store repository.Store) *TouristService {
+	return &TouristService{store: store}
+}
+
+// CreateProfile creates a new tourist profile
+func (s *TouristService) CreateProfile(ctx context.Context, tourist *models.Tourist) error {
+	return s.store.Tourists().Create(ctx, tourist)
+}
+
+// GetProfileByUserID retrieves the tourist profile of a user
+func (s *TouristService) GetProfileByUserID(ctx context.Context, userID uint) (*models.Tourist, error) {
+	return s.store.Tourists().FindByUserID(ctx, userID)
+}
+
+// UpdateProfile saves a tourist profile that was read and then modified.
+// Returns repository.ErrVersionConflict if the profile changed since it was read.
+func (s *TouristService) UpdateProfile(ctx context.Context, tourist *models.Tourist) error {
+	return s.store.Tourists().Update(ctx, tourist)
+}
+
+// BookDriver books the available driver booking.DriverID for the tourist and
+// marks the driver as taken
+func (s *TouristService) BookDriver(ctx context.Context, tourist *models.Tourist, booking *models.Booking) (*models.Booking, error) {
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		driver, err := tx.Drivers().FindByID(ctx, booking.DriverID)
+		if err != nil {
+			return err
+		}
+		if !driver.IsAvailable {
+			return ErrDriverUnavailable
+		}
+
+		// Fails with ErrVersionConflict if someone else booked or changed the driver meanwhile
+		driver.IsAvailable = false
+		if err := tx.Drivers().Update(ctx, driver); err != nil {
+			return err
+		}
+
+		booking.TouristID = tourist.ID
+		booking.Status = "pending"
+		booking.BookedAt = time.Now()
+		return tx.Bookings().Create(ctx, booking)
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.BookingsCreated.Inc()
+
+	// Load the driver information for the response
+	return s.store.Bookings().FindByID(ctx, booking.ID)
+}
+
+// RequestDriver records a tourist's request for any driver
+func (s *TouristService) RequestDriver(ctx context.Context, request *models.TouristRequest) error {
+	return s.store.TouristRequests().Create(ctx, request)
+}
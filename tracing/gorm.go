@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin records a client span for every statement, parented to the
+// context passed with db.WithContext. Register it with db.Use.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	register := func(operation string, before, after func(name string, fn func(*gorm.DB)) error) error {
+		if err := before("tracing:before_"+operation, func(tx *gorm.DB) { startSpan(tx, operation) }); err != nil {
+			return err
+		}
+		return after("tracing:after_"+operation, endSpan)
+	}
+
+	return errors.Join(
+		register("create", callback.Create().Before("gorm:create").Register, callback.Create().After("gorm:create").Register),
+		register("query", callback.Query().Before("gorm:query").Register, callback.Query().After("gorm:query").Register),
+		register("update", callback.Update().Before("gorm:update").Register, callback.Update().After("gorm:update").Register),
+		register("delete", callback.Delete().Before("gorm:delete").Register, callback.Delete().After("gorm:delete").Register),
+		register("row", callback.Row().Before("gorm:row").Register, callback.Row().After("gorm:row").Register),
+		register("raw", callback.Raw().Before("gorm:raw").Register, callback.Raw().After("gorm:raw").Register),
+	)
+}
+
+func startSpan(tx *gorm.DB, operation string) {
+	ctx := tx.Statement.Context
+	if ctx == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		// Only trace queries that belong to a request or another span
+		return
+	}
+
+	name := "db." + operation
+	if tx.Statement.Table != "" {
+		name += " " + tx.Statement.Table
+	}
+	_, span := Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(tx.Dialector.Name()),
+			semconv.DBOperation(operation),
+			semconv.DBSQLTable(tx.Statement.Table),
+		),
+	)
+	tx.InstanceSet(spanKey, span)
+}
+
+func endSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// The statement is recorded without its bound values, which may be personal data
+	span.SetAttributes(
+		semconv.DBStatement(tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+}
@@ -1,22 +1,24 @@
 package utils
 
 import (
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/i18n"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-// ErrorHandler is a custom error handler for the Fiber app
+// ErrorHandler renders every error returned by a handler as an apperror.Response.
+// Server-side failures are logged with their cause, which clients never see.
 func ErrorHandler(c *fiber.Ctx, err error) error {
-	// Default 500 status code
-	code := fiber.StatusInternalServerError
-
-	// Check if it's a Fiber error
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
+	appErr := apperror.From(err)
+	if appErr.Status >= fiber.StatusInternalServerError {
+		LogErrorContext(c.UserContext(), "%s %s failed: %v", c.Method(), c.Path(), err)
+	}
+	if errors.Is(appErr, apperror.RequestTimeout) {
+		c.Set(fiber.HeaderRetryAfter, "1")
 	}
 
-	// Return JSON error
-	return c.Status(code).JSON(fiber.Map{
-		"error": err.Error(),
-		"code":  code,
-	})
+	requestID, _ := c.Locals("requestID").(string)
+	return c.Status(appErr.Status).JSON(appErr.Response(i18n.FromContext(c.UserContext()), requestID))
 }
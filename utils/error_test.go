@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fiber-backend/apperror"
+	"fiber-backend/i18n"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorHandlerResponses(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{apperror.TouristNotFound, fiber.StatusNotFound, "TOURIST_NOT_FOUND"},
+		{apperror.Internal.Wrap(errors.New("pq: connection refused")), fiber.StatusInternalServerError, "INTERNAL_ERROR"},
+		{fiber.ErrNotFound, fiber.StatusNotFound, "ROUTE_NOT_FOUND"},
+		{fiber.ErrTooManyRequests, fiber.StatusTooManyRequests, "REQUEST_FAILED"},
+		{fmt.Errorf("query drivers: %w", context.Canceled), apperror.StatusClientClosedRequest, "REQUEST_CANCELLED"},
+		{fmt.Errorf("query drivers: %w", context.DeadlineExceeded), fiber.StatusServiceUnavailable, "REQUEST_TIMEOUT"},
+		{errors.New("pq: password authentication failed"), fiber.StatusInternalServerError, "INTERNAL_ERROR"},
+	}
+
+	for _, tc := range cases {
+		app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+		app.Get("/", func(c *fiber.Ctx) error {
+			c.Locals("requestID", "req-1")
+			return tc.err
+		})
+
+		res, err := app.Test(httptest.NewRequest("GET", "/", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body apperror.Response
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.status || body.Status != tc.status || body.Code != tc.code {
+			t.Errorf("%v: got %d %+v, want %d %s", tc.err, res.StatusCode, body, tc.status, tc.code)
+		}
+		if body.RequestID != "req-1" || body.Error == "" || body.MessageKey == "" {
+			t.Errorf("%v: incomplete response %+v", tc.err, body)
+		}
+		if body.Code == "INTERNAL_ERROR" && body.Error != apperror.Internal.Message(i18n.Default) {
+			t.Errorf("internal details leaked to the client: %q", body.Error)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fiber-backend/apperror"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VersionETag formats a row version as a strong ETag value
+func VersionETag(version uint) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// IfMatchSatisfied reports whether the request's If-Match header allows an update
+// of a resource at the given version. A missing header is accepted so older
+// clients keep working. Tags are compared strongly, as RFC 9110 requires for
+// If-Match, so weak ones (W/"1") never match.
+func IfMatchSatisfied(c *fiber.Ctx, version uint) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return true
+	}
+
+	current := VersionETag(version)
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == current {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionConflict answers a stale update with 409 Conflict, the current ETag and
+// the current state of the resource so the client can merge and retry
+func VersionConflict(c *fiber.Ctx, version uint, current interface{}) error {
+	c.Set(fiber.HeaderETag, VersionETag(version))
+	return apperror.VersionConflict.WithCurrent(current)
+}
@@ -1,30 +1,59 @@
 package utils
 
 import (
+	"errors"
 	"fiber-backend/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateJWT(user *models.User) (string, error) {
+// JWTManager signs and verifies the API's access tokens. It is built once at
+// startup from the validated configuration.
+type JWTManager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewJWTManager fails when the secret is empty so a misconfigured server never starts
+func NewJWTManager(secret string, ttl time.Duration) (*JWTManager, error) {
+	if secret == "" {
+		return nil, errors.New("JWT secret is empty")
+	}
+	return &JWTManager{secret: []byte(secret), ttl: ttl}, nil
+}
+
+// TTL is how long tokens stay valid
+func (m *JWTManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Generate creates a signed token for the user. It carries the user's language
+// so responses can be localized without a database lookup, and their session
+// version so it stops working once the password changes.
+func (m *JWTManager) Generate(user *models.User) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
 		"name":    user.Name,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"sv":      user.SessionVersion,
+		"exp":     time.Now().Add(m.ttl).Unix(),
+	}
+	if user.Language != "" {
+		claims["lang"] = user.Language
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(GetJWTSecret())
+	return token.SignedString(m.secret)
 }
 
-func ValidateJWT(tokenString string) (*jwt.Token, error) {
+// Validate parses the token and checks its signature and expiry
+func (m *JWTManager) Validate(tokenString string) (*jwt.Token, error) {
 	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return GetJWTSecret(), nil
+		return m.secret, nil
 	})
 }
 
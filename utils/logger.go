@@ -1,48 +1,151 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"time"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+// LogOptions configures the process-wide logger
+type LogOptions struct {
+	Level      string // debug, info, warn or error
+	Format     string // json or text
+	Dir        string // directory for rotated log files, empty to log to stdout only
+	MaxSizeMB  int    // rotate the current file once it reaches this size
+	MaxAgeDays int    // delete rotated files older than this
+
+	// Output replaces stdout, mainly so tests can inspect what is logged
+	Output io.Writer
+}
+
 var (
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
+	logLevel = new(slog.LevelVar)
+	logger   = newLogger(os.Stdout, "json")
 )
 
-func init() {
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Fatal("Failed to create logs directory:", err)
+func newLogger(w io.Writer, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// SetupLogging points the logger at stdout and, when opts.Dir is set, at a
+// rotating log file. The returned closer flushes and closes that file.
+func SetupLogging(opts LogOptions) (io.Closer, error) {
+	if err := SetLogLevel(opts.Level); err != nil {
+		return nil, err
 	}
 
-	// Create or open log file
-	logFile, err := os.OpenFile(
-		fmt.Sprintf("logs/app-%s.log", time.Now().Format("2006-01-02")),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		0644,
-	)
-	if err != nil {
-		log.Fatal("Failed to open log file:", err)
+	var out io.Writer = os.Stdout
+	if opts.Output != nil {
+		out = opts.Output
+	}
+	var closer io.Closer = nopCloser{}
+	if opts.Dir != "" {
+		file, err := NewRotatingFile(opts.Dir, "app", int64(opts.MaxSizeMB)*1024*1024, opts.MaxAgeDays)
+		if err != nil {
+			return nil, err
+		}
+		out = io.MultiWriter(out, file)
+		closer = file
 	}
 
-	// Initialize loggers
-	InfoLogger = log.New(logFile, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(logFile, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logger = newLogger(out, opts.Format)
+	slog.SetDefault(logger)
+	return closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Logger returns the process-wide structured logger
+func Logger() *slog.Logger {
+	return logger
+}
+
+// SetLogLevel changes the minimum level logged, effective immediately
+func SetLogLevel(name string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// LogLevel returns the current minimum level, e.g. "info"
+func LogLevel() string {
+	return strings.ToLower(logLevel.Level().String())
 }
 
 // LogInfo logs information messages
 func LogInfo(format string, v ...interface{}) {
-	message := fmt.Sprintf(format, v...)
-	InfoLogger.Println(message)
-	fmt.Printf("INFO: %s\n", message)
+	logger.Info(fmt.Sprintf(format, v...))
 }
 
 // LogError logs error messages
 func LogError(format string, v ...interface{}) {
-	message := fmt.Sprintf(format, v...)
-	ErrorLogger.Println(message)
-	fmt.Printf("ERROR: %s\n", message)
+	logger.Error(fmt.Sprintf(format, v...))
+}
+
+// LogInfoContext logs an information message tagged with the request in ctx
+func LogInfoContext(ctx context.Context, format string, v ...interface{}) {
+	logger.InfoContext(ctx, fmt.Sprintf(format, v...))
+}
+
+// LogErrorContext logs an error message tagged with the request in ctx
+func LogErrorContext(ctx context.Context, format string, v ...interface{}) {
+	logger.ErrorContext(ctx, fmt.Sprintf(format, v...))
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose log lines carry the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFrom returns the request ID stored in ctx, if any
+func RequestIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler adds the request and trace IDs from the record's context to every line
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFrom(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if ctx != nil {
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
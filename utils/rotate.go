@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.Writer over <dir>/<prefix>-<date>.log that starts a
+// new file every day and whenever the current one reaches maxSize bytes
+// (<prefix>-<date>.1.log, .2.log, ...). Files older than maxAgeDays are
+// deleted on rotation.
+type RotatingFile struct {
+	mu         sync.Mutex
+	dir        string
+	prefix     string
+	maxSize    int64
+	maxAgeDays int
+
+	file  *os.File
+	day   string
+	index int
+	size  int64
+	now   func() time.Time
+}
+
+// NewRotatingFile opens today's log file, creating dir if needed. A maxSize or
+// maxAgeDays of zero disables size-based rotation or retention.
+func NewRotatingFile(dir, prefix string, maxSize int64, maxAgeDays int) (*RotatingFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create log directory: %w", err)
+	}
+	r := &RotatingFile{dir: dir, prefix: prefix, maxSize: maxSize, maxAgeDays: maxAgeDays, now: time.Now}
+	if err := r.rotate(0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.now().Format("2006-01-02") != r.day {
+		if err := r.rotate(0); err != nil {
+			return 0, err
+		}
+	} else if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(r.index + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// rotate opens the first file for today, from startIndex on, that still has room
+func (r *RotatingFile) rotate(startIndex int) error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	r.day = r.now().Format("2006-01-02")
+	for index := startIndex; ; index++ {
+		name := fmt.Sprintf("%s-%s.log", r.prefix, r.day)
+		if index > 0 {
+			name = fmt.Sprintf("%s-%s.%d.log", r.prefix, r.day, index)
+		}
+		path := filepath.Join(r.dir, name)
+
+		info, err := os.Stat(path)
+		if err == nil && r.maxSize > 0 && info.Size() >= r.maxSize {
+			continue
+		}
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		r.file = file
+		r.index = index
+		r.size = 0
+		if info != nil {
+			r.size = info.Size()
+		}
+		break
+	}
+
+	r.removeExpired()
+	return nil
+}
+
+func (r *RotatingFile) removeExpired() {
+	if r.maxAgeDays <= 0 {
+		return
+	}
+	cutoff := r.now().AddDate(0, 0, -r.maxAgeDays).Format("2006-01-02")
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		rest, ok := strings.CutPrefix(name, r.prefix+"-")
+		if !ok || !strings.HasSuffix(name, ".log") || len(rest) < len("2006-01-02") {
+			continue
+		}
+		day := rest[:len("2006-01-02")]
+		if _, err := time.Parse("2006-01-02", day); err == nil && day < cutoff {
+			os.Remove(filepath.Join(r.dir, name))
+		}
+	}
+}
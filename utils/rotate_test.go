@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesBySizeAndDay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	r, err := NewRotatingFile(dir, "app", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.now = func() time.Time { return now }
+
+	r.Write([]byte("12345678\n"))
+	r.Write([]byte("next file\n"))
+	now = now.AddDate(0, 0, 1)
+	r.Write([]byte("new day\n"))
+
+	today := time.Now().Format("2006-01-02")
+	want := []string{"app-2025-06-01.log", "app-2025-06-01.1.log", "app-2025-06-02.log", "app-" + today + ".log"}
+	if got := logFiles(t, dir); !equal(got, sorted(want)) {
+		t.Fatalf("files = %v, want %v", got, sorted(want))
+	}
+}
+
+func TestRotatingFileDeletesExpiredFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app-2020-01-01.log", "app-2020-01-01.1.log", "other.log"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0644)
+	}
+
+	r, err := NewRotatingFile(dir, "app", 0, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	want := []string{"app-" + time.Now().Format("2006-01-02") + ".log", "other.log"}
+	if got := logFiles(t, dir); !equal(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+}
+
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return sorted(names)
+}
+
+func sorted(names []string) []string {
+	sort.Strings(names)
+	return names
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
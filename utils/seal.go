@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSealInvalid is returned for values that were not sealed by this Sealer or were altered
+	ErrSealInvalid = errors.New("sealed value is invalid")
+	// ErrSealExpired is returned for values past their lifetime
+	ErrSealExpired = errors.New("sealed value has expired")
+)
+
+// Sealer encrypts and authenticates short-lived values handed to clients, such
+// as OAuth state kept in a cookie, so they can neither read nor alter them.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer derives a key from secret for one purpose, so values sealed for
+// one purpose can't be replayed for another
+func NewSealer(secret, purpose string) (*Sealer, error) {
+	if secret == "" {
+		return nil, errors.New("sealer secret is empty")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+type sealed struct {
+	Value   json.RawMessage `json:"v"`
+	Expires int64           `json:"exp"`
+}
+
+// Seal encodes v as JSON and encrypts it with a lifetime of ttl
+func (s *Sealer) Seal(v interface{}, ttl time.Duration) (string, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(sealed{Value: value, Expires: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// Open decrypts text into v. It fails with ErrSealInvalid or ErrSealExpired.
+func (s *Sealer) Open(text string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return ErrSealInvalid
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return ErrSealInvalid
+	}
+	var envelope sealed
+	if err := json.Unmarshal(plaintext, &envelope); err != nil {
+		return ErrSealInvalid
+	}
+	if time.Now().Unix() >= envelope.Expires {
+		return ErrSealExpired
+	}
+	if err := json.Unmarshal(envelope.Value, v); err != nil {
+		return ErrSealInvalid
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSealerRoundTripsAndRejectsTampering(t *testing.T) {
+	type flow struct {
+		State string `json:"state"`
+	}
+	sealer, err := NewSealer("secret", "oauth-flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := sealer.Seal(flow{State: "abc"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opened flow
+	if err := sealer.Open(text, &opened); err != nil || opened.State != "abc" {
+		t.Fatalf("Open = %+v, %v", opened, err)
+	}
+
+	tampered := []byte(text)
+	tampered[len(tampered)/2] ^= 'A' ^ 'B'
+	other, _ := NewSealer("secret", "another-purpose")
+	rotated, _ := NewSealer("new-secret", "oauth-flow")
+	for name, open := range map[string]func() error{
+		"tampered":      func() error { return sealer.Open(string(tampered), &opened) },
+		"other purpose": func() error { return other.Open(text, &opened) },
+		"other secret":  func() error { return rotated.Open(text, &opened) },
+		"garbage":       func() error { return sealer.Open("not base64!", &opened) },
+	} {
+		if err := open(); !errors.Is(err, ErrSealInvalid) {
+			t.Errorf("%s: got %v, want ErrSealInvalid", name, err)
+		}
+	}
+
+	expired, _ := sealer.Seal(flow{State: "abc"}, -time.Second)
+	if err := sealer.Open(expired, &opened); !errors.Is(err, ErrSealExpired) {
+		t.Fatalf("expired value: got %v", err)
+	}
+}
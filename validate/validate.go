@@ -0,0 +1,320 @@
+// Package validate checks request payloads against the rules in their
+// `validate` struct tags, e.g.
+//
+//	Email string `json:"email" validate:"required,email,max=255"`
+//
+// Problems are reported per field, keyed by the field's JSON name (nested
+// fields as "tourist.arrival_date"), as i18n message keys.
+//
+// Rules:
+//
+//	required     present and not blank (nil pointers, "", 0 and zero times fail;
+//	             a pointer to false or 0 is present)
+//	notblank     when present, not blank; for optional pointer fields
+//	email        a plain address such as ana@example.com
+//	password     at least 8 characters, at most 72 bytes, not only letters or only digits
+//	min=N, max=N length of strings in characters, or value of numbers
+//	oneof=a b c  one of the listed values
+//	date         YYYY-MM-DD
+//	datetime     YYYY-MM-DDTHH:MM or RFC 3339
+//	url          an absolute http(s) URL
+//	language     a language the API has messages for
+//	after=field  a date or time later than the sibling field with that JSON name
+//
+// Every rule but required and notblank accepts empty values; combine them
+// with required for mandatory fields. Rules tags can't express go in a
+// Check method (see Checker).
+package validate
+
+import (
+	"fiber-backend/i18n"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Problems maps field names to the message key of their first problem
+type Problems map[string]string
+
+// Add records a problem unless the field already has one
+func (p Problems) Add(field, key string) {
+	if _, ok := p[field]; !ok {
+		p[field] = key
+	}
+}
+
+// Checker is implemented by payloads with rules that involve several fields.
+// Check runs after the tag rules.
+type Checker interface {
+	Check(problems Problems)
+}
+
+// Struct validates v, a struct or a pointer to one, and returns its problems
+// or nil when there are none
+func Struct(v interface{}) Problems {
+	problems := Problems{}
+	walk(reflect.ValueOf(v), "", problems)
+	if checker, ok := v.(Checker); ok {
+		checker.Check(problems)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+func walk(value reflect.Value, prefix string, problems Problems) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + jsonName(field)
+		fieldValue := value.Field(i)
+
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if key := check(value, fieldValue, tag); key != "" {
+				problems.Add(name, key)
+				continue
+			}
+		}
+
+		// Nested payloads are validated field by field
+		inner := fieldValue
+		if inner.Kind() == reflect.Pointer && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct && inner.Type() != reflect.TypeOf(time.Time{}) {
+			walk(inner, name+".", problems)
+		}
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// check applies the rules in tag to one field and returns the first problem
+func check(parent, value reflect.Value, tag string) string {
+	present, pointer := true, value.Kind() == reflect.Pointer
+	if pointer {
+		present = !value.IsNil()
+		if present {
+			value = value.Elem()
+		}
+	}
+	empty := !present || isEmpty(value)
+	// Fields are pointers so that false and 0 can be told from missing
+	if _, number := toInt(value); pointer && present && (value.Kind() == reflect.Bool || number) {
+		empty = false
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if empty {
+				return "validation.required"
+			}
+			continue
+		case "notblank":
+			if present && isEmpty(value) {
+				return "validation.not_empty"
+			}
+			continue
+		}
+		if empty {
+			continue
+		}
+		if key := apply(parent, value, name, arg); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Struct:
+		if t, ok := value.Interface().(time.Time); ok {
+			return t.IsZero()
+		}
+		return false
+	default:
+		return value.IsZero()
+	}
+}
+
+func apply(parent, value reflect.Value, rule, arg string) string {
+	text := strings.TrimSpace(fmt.Sprint(value.Interface()))
+
+	switch rule {
+	case "email":
+		if !isEmail(text) {
+			return "validation.email"
+		}
+	case "password":
+		if !isStrongPassword(value.String()) {
+			return "validation.password_weak"
+		}
+	case "min", "max":
+		limit, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s=%s", rule, arg))
+		}
+		if value.Kind() == reflect.String {
+			length := int64(utf8.RuneCountInString(text))
+			if rule == "min" && length < limit {
+				return i18n.Key("validation.min_length", limit)
+			}
+			if rule == "max" && length > limit {
+				return i18n.Key("validation.max_length", limit)
+			}
+			return ""
+		}
+		number, ok := toInt(value)
+		if rule == "min" && ok && number < limit {
+			return i18n.Key("validation.min", limit)
+		}
+		if rule == "max" && ok && number > limit {
+			return i18n.Key("validation.max", limit)
+		}
+	case "oneof":
+		for _, option := range strings.Fields(arg) {
+			if text == option {
+				return ""
+			}
+		}
+		return i18n.Key("validation.one_of", strings.Join(strings.Fields(arg), ", "))
+	case "date":
+		if _, ok := parseTime(text, true); !ok {
+			return "validation.date_format"
+		}
+	case "datetime":
+		if _, ok := parseTime(text, false); !ok {
+			return "validation.datetime_format"
+		}
+	case "url":
+		if u, err := url.Parse(text); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "validation.url"
+		}
+	case "language":
+		if i18n.Supported(text) == "" {
+			return "validation.unsupported_language"
+		}
+	case "after":
+		other, ok := sibling(parent, arg)
+		if !ok {
+			panic(fmt.Sprintf("validate: after=%s names no field of %s", arg, parent.Type()))
+		}
+		current, currentOK := asTime(value)
+		previous, previousOK := asTime(other)
+		// A missing or malformed bound is reported on its own field
+		if currentOK && previousOK && !current.After(previous) {
+			return i18n.Key("validation.after", arg)
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+func isEmail(text string) bool {
+	address, err := mail.ParseAddress(text)
+	if err != nil || address.Address != text || address.Name != "" {
+		return false
+	}
+	_, domain, _ := strings.Cut(text, "@")
+	return strings.Contains(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+func isStrongPassword(password string) bool {
+	if utf8.RuneCountInString(password) < 8 || len(password) > 72 { // bcrypt ignores bytes past 72
+		return false
+	}
+	var letters, others bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letters = true
+		} else {
+			others = true
+		}
+	}
+	return letters && others
+}
+
+func toInt(value reflect.Value) (int64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), true
+	}
+	return 0, false
+}
+
+func parseTime(text string, dateOnly bool) (time.Time, bool) {
+	layouts := []string{"2006-01-02T15:04", time.RFC3339}
+	if dateOnly {
+		layouts = []string{"2006-01-02"}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func asTime(value reflect.Value) (time.Time, bool) {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return time.Time{}, false
+		}
+		value = value.Elem()
+	}
+	if t, ok := value.Interface().(time.Time); ok {
+		return t, !t.IsZero()
+	}
+	if value.Kind() != reflect.String {
+		return time.Time{}, false
+	}
+	text := strings.TrimSpace(value.String())
+	if t, ok := parseTime(text, true); ok {
+		return t, true
+	}
+	return parseTime(text, false)
+}
+
+// sibling finds the field of parent with the given JSON name
+func sibling(parent reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < parent.NumField(); i++ {
+		if jsonName(parent.Type().Field(i)) == name {
+			return parent.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stay struct {
+	Arrival   string `json:"arrival" validate:"required,date"`
+	Departure string `json:"departure" validate:"required,date,after=arrival"`
+}
+
+type signup struct {
+	Email    string  `json:"email" validate:"required,email,max=20"`
+	Password string  `json:"password" validate:"required,password"`
+	Nickname *string `json:"nickname" validate:"notblank,max=5"`
+	Age      int     `json:"age" validate:"min=18,max=120"`
+	Plan     string  `json:"plan" validate:"oneof=free pro"`
+	Site     string  `json:"site" validate:"url"`
+	Language string  `json:"language" validate:"language"`
+	Stay     *stay   `json:"stay"`
+	Start    time.Time
+	End      time.Time `validate:"after=Start"`
+}
+
+func (s *signup) Check(problems Problems) {
+	if s.Plan == "pro" && s.Site == "" {
+		problems.Add("site", "validation.required")
+	}
+}
+
+func valid() signup {
+	return signup{Email: "ana@example.com", Password: "correct horse", Age: 30}
+}
+
+func TestValidPayloadsHaveNoProblems(t *testing.T) {
+	s := valid()
+	nickname := "ana"
+	s.Nickname = &nickname
+	s.Plan, s.Site, s.Language = "pro", "https://ana.example.com", "pt-BR"
+	s.Stay = &stay{Arrival: "2025-07-01", Departure: "2025-07-02"}
+	s.Start, s.End = time.Now(), time.Now().Add(time.Hour)
+	if problems := Struct(&s); problems != nil {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+}
+
+func TestRulesReportOneProblemPerField(t *testing.T) {
+	blank := "  "
+	cases := []struct {
+		edit func(*signup)
+		want Problems
+	}{
+		{func(s *signup) { s.Email, s.Password = " ", "" }, Problems{"email": "validation.required", "password": "validation.required"}},
+		{func(s *signup) { s.Email = "ana@localhost" }, Problems{"email": "validation.email"}},
+		{func(s *signup) { s.Email = "Ana <ana@example.com>" }, Problems{"email": "validation.email"}},
+		{func(s *signup) { s.Email = "someone.long@example.com" }, Problems{"email": "validation.max_length:20"}},
+		{func(s *signup) { s.Password = "abcdefgh" }, Problems{"password": "validation.password_weak"}},
+		{func(s *signup) { s.Password = "12345678" }, Problems{"password": "validation.password_weak"}},
+		{func(s *signup) { s.Password = "a1" }, Problems{"password": "validation.password_weak"}},
+		{func(s *signup) { s.Password = strings.Repeat("a1", 37) }, Problems{"password": "validation.password_weak"}},
+		{func(s *signup) { s.Nickname = &blank }, Problems{"nickname": "validation.not_empty"}},
+		{func(s *signup) { n := "ñandúes"; s.Nickname = &n }, Problems{"nickname": "validation.max_length:5"}},
+		{func(s *signup) { s.Age = 12 }, Problems{"age": "validation.min:18"}},
+		{func(s *signup) { s.Plan = "gold" }, Problems{"plan": "validation.one_of:free, pro"}},
+		{func(s *signup) { s.Plan = "pro" }, Problems{"site": "validation.required"}},
+		{func(s *signup) { s.Site = "javascript:alert(1)" }, Problems{"site": "validation.url"}},
+		{func(s *signup) { s.Language = "tlh" }, Problems{"language": "validation.unsupported_language"}},
+		{func(s *signup) { s.Stay = &stay{} }, Problems{"stay.arrival": "validation.required", "stay.departure": "validation.required"}},
+		{func(s *signup) { s.Stay = &stay{Arrival: "2025-07-01", Departure: "2025-7-2"} }, Problems{"stay.departure": "validation.date_format"}},
+		{func(s *signup) { s.Stay = &stay{Arrival: "2025-07-01", Departure: "2025-07-01"} }, Problems{"stay.departure": "validation.after:arrival"}},
+		{func(s *signup) { s.Start, s.End = time.Now(), time.Now().Add(-time.Hour) }, Problems{"End": "validation.after:Start"}},
+	}
+
+	for i, tc := range cases {
+		s := valid()
+		tc.edit(&s)
+		if got := Struct(&s); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("case %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestRequiredPointersAcceptFalseAndZero(t *testing.T) {
+	type toggle struct {
+		On    *bool `json:"on" validate:"required"`
+		Count *int  `json:"count" validate:"required,min=0"`
+	}
+	off, zero := false, 0
+	if problems := Struct(&toggle{On: &off, Count: &zero}); problems != nil {
+		t.Fatalf("false and 0 were sent, got %v", problems)
+	}
+	want := Problems{"on": "validation.required", "count": "validation.required"}
+	if problems := Struct(&toggle{}); !reflect.DeepEqual(problems, want) {
+		t.Fatalf("got %v, want %v", problems, want)
+	}
+}
+
+func TestUnknownRulesPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a typo in a validate tag should not be ignored")
+		}
+	}()
+	Struct(&struct {
+		Name string `validate:"requird"`
+	}{Name: "x"})
+}